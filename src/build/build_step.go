@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -35,6 +36,9 @@ var log = logging.Log
 // Type that indicates that we're stopping the build of a target in a nonfatal way.
 var errStop = fmt.Errorf("stopping build")
 
+// envFileName is the file we write a failed build's environment into when --keep_sandbox is passed.
+const envFileName = ".plz-env"
+
 // httpClient is the shared http client that we use for fetching remote files.
 var httpClient *retryablehttp.Client
 var httpClientOnce sync.Once
@@ -135,7 +139,7 @@ func prepareOnly(state *core.BuildState, target *core.BuildTarget) error {
 	if err := prepareDirectories(target); err != nil {
 		return err
 	}
-	if err := prepareSources(state, state.Graph, target); err != nil {
+	if err := prepareSources(state, state.Graph, target, target.TmpDir()); err != nil {
 		return err
 	}
 	// This is important to catch errors here where we will recover the panic, rather
@@ -202,12 +206,20 @@ func buildTarget(state *core.BuildState, target *core.BuildTarget, runRemotely b
 		metadata, err = state.RemoteClient.Build(target)
 		if err != nil {
 			return err
+		} else if (metadata.Cached && state.ReplayBuildWarnings) || (!metadata.Cached && state.ShowBuildWarnings) {
+			printBuildWarnings(state, target, metadata)
 		}
 	} else {
 		// Wait if another process is currently building this target
 		state.LogBuildResult(target, core.TargetBuilding, "Acquiring target lock...")
 		file := core.AcquireExclusiveFileLock(target.BuildLockFile())
 		defer core.ReleaseFileLock(file)
+		// Validation runs on whatever outputs we end up with, however we got them.
+		defer func() {
+			if err == nil {
+				err = validateOutputs(state, target)
+			}
+		}()
 		state.LogBuildResult(target, core.TargetBuilding, "Preparing...")
 
 		// Ensure we have downloaded any previous dependencies if that's relevant.
@@ -311,7 +323,7 @@ func buildTarget(state *core.BuildState, target *core.BuildTarget, runRemotely b
 			return err
 		}
 		state.LogBuildResult(target, core.TargetBuilding, "Preparing...")
-		if err := prepareSources(state, state.Graph, target); err != nil {
+		if err := prepareSources(state, state.Graph, target, target.TmpDir()); err != nil {
 			return fmt.Errorf("Error preparing sources for %s: %s", target.Label, err)
 		}
 
@@ -319,6 +331,14 @@ func buildTarget(state *core.BuildState, target *core.BuildTarget, runRemotely b
 		metadata, err = build(state, target, cacheKey)
 		if err != nil {
 			return err
+		} else if state.ShowBuildWarnings {
+			printBuildWarnings(state, target, metadata)
+		}
+		if state.Reproduce && !target.IsRemoteFile && !target.IsTextFile {
+			state.LogBuildResult(target, core.TargetBuilding, "Checking reproducibility...")
+			if err := checkReproducible(state, target, cacheKey); err != nil {
+				return err
+			}
 		}
 
 		// Add optional outputs to target metadata
@@ -432,6 +452,11 @@ func addOutDirOutsFromMetadata(target *core.BuildTarget, md *core.BuildMetadata)
 }
 
 func retrieveFromCache(cache core.Cache, target *core.BuildTarget, cacheKey []byte, files []string) *core.BuildMetadata {
+	if target.Stamp {
+		return nil // Stamped targets embed the current revision etc, so can't be retrieved by their content hash.
+	} else if !target.Cacheable() {
+		return nil
+	}
 	files = append(files, target.TargetBuildMetadataFileName())
 	if ok := cache.Retrieve(target, cacheKey, files); ok {
 		md, err := loadTargetMetadata(target)
@@ -445,6 +470,9 @@ func retrieveFromCache(cache core.Cache, target *core.BuildTarget, cacheKey []by
 }
 
 func storeInCache(cache core.Cache, target *core.BuildTarget, key []byte, files []string) {
+	if target.Stamp || !target.Cacheable() {
+		return
+	}
 	files = append(files, target.TargetBuildMetadataFileName())
 	cache.Store(target, key, files)
 }
@@ -486,6 +514,9 @@ func retrieveArtifacts(state *core.BuildState, target *core.BuildTarget, oldOutp
 			target.SetState(core.Unchanged)
 			state.LogBuildResult(target, core.TargetCached, "Cached (unchanged)")
 		}
+		if state.ReplayBuildWarnings {
+			printBuildWarnings(state, target, md)
+		}
 		buildLinks(state, target)
 
 		// If we could've potentially pulled from the http cache, we need to write the xattrs back as they will be
@@ -504,21 +535,88 @@ func retrieveArtifacts(state *core.BuildState, target *core.BuildTarget, oldOutp
 }
 
 // runBuildCommand runs the actual command to build a target.
-// On success it returns the stdout of the target, otherwise an error.
-func runBuildCommand(state *core.BuildState, target *core.BuildTarget, command string, inputHash []byte) ([]byte, error) {
+// On success it returns the stdout and stderr of the target, otherwise an error.
+func runBuildCommand(state *core.BuildState, target *core.BuildTarget, command string, inputHash []byte) ([]byte, []byte, error) {
 	if target.IsRemoteFile {
-		return nil, fetchRemoteFile(state, target)
+		return nil, nil, fetchRemoteFile(state, target)
 	}
 	if target.IsTextFile {
-		return nil, buildTextFile(state, target)
+		return nil, nil, buildTextFile(state, target)
+	}
+	return runBuildCommandIn(state, target, command, inputHash, target.TmpDir(), false)
+}
+
+// runBuildCommandIn runs the build command for a target in the given directory.
+// If shuffleEnv is true the order of its environment variables is randomised.
+func runBuildCommandIn(state *core.BuildState, target *core.BuildTarget, command string, inputHash []byte, dir string, shuffleEnv bool) ([]byte, []byte, error) {
+	env := core.StampedBuildEnvironment(state, target, inputHash, filepath.Join(core.RepoRoot, dir), target.Stamp).ToSlice()
+	if shuffleEnv {
+		rand.Shuffle(len(env), func(i, j int) { env[i], env[j] = env[j], env[i] })
 	}
-	env := core.StampedBuildEnvironment(state, target, inputHash, filepath.Join(core.RepoRoot, target.TmpDir()), target.Stamp).ToSlice()
 	log.Debug("Building target %s\nENVIRONMENT:\n%s\n%s", target.Label, env, command)
-	out, combined, err := state.ProcessExecutor.ExecWithTimeoutShell(target, target.TmpDir(), env, target.BuildTimeout, state.ShowAllOutput, false, process.NewSandboxConfig(target.Sandbox, target.Sandbox), command)
+	out, stderr, combined, err := state.ProcessExecutor.ExecWithTimeoutShellStderr(target, dir, env, target.BuildTimeout, state.ShowAllOutput, false, process.NewSandboxConfig(target.Sandbox && !target.Network, target.Sandbox), command)
 	if err != nil {
-		return nil, fmt.Errorf("Error building target %s: %s\n%s", target.Label, err, combined)
+		if state.KeepSandbox {
+			keepWorkdir(target, dir, env)
+		}
+		return nil, nil, fmt.Errorf("Error building target %s: %s\n%s", target.Label, err, combined)
+	}
+	return out, stderr, nil
+}
+
+// printBuildWarnings prints anything a target's successful build action wrote to stderr, which is
+// otherwise discarded. Usually this consists of compiler warnings.
+func printBuildWarnings(state *core.BuildState, target *core.BuildTarget, metadata *core.BuildMetadata) {
+	if output := bytes.TrimSpace(metadata.Stderr); len(output) > 0 && !state.ShowAllOutput {
+		log.Warning("Output from building %s:\n%s", target.Label, output)
+	}
+}
+
+// keepWorkdir records the environment of a failed build in its working directory (which is left
+// in place on failure anyway) so the user can go and reproduce it.
+func keepWorkdir(target *core.BuildTarget, dir string, env []string) {
+	if err := writeEnvFile(filepath.Join(dir, envFileName), env); err != nil {
+		log.Warning("Failed to write environment file for %s: %s", target.Label, err)
+	}
+	log.Warning("Working directory for %s kept at %s; run `source %s` there to recreate its environment", target.Label, dir, envFileName)
+}
+
+// writeEnvFile writes the given environment variables to a file as shell export statements.
+func writeEnvFile(filename string, env []string) error {
+	var buf strings.Builder
+	for _, e := range env {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			fmt.Fprintf(&buf, "export %s='%s'\n", k, strings.ReplaceAll(v, "'", `'\''`))
+		}
+	}
+	return os.WriteFile(filename, []byte(buf.String()), 0644)
+}
+
+// validateOutputs runs the validation command for a target against its outputs, if it has one.
+// It isn't re-run if it has already succeeded for the same command & outputs.
+func validateOutputs(state *core.BuildState, target *core.BuildTarget) error {
+	if target.Validation == "" {
+		return nil
+	}
+	h := state.PathHasher.NewHash()
+	h.Write([]byte(target.Validation))
+	h.Write(outputHashOrNil(target, target.FullOutputs(), state.PathHasher, state.PathHasher.NewHash))
+	hash := h.Sum(nil)
+	md, err := loadTargetMetadata(target)
+	if err == nil && bytes.Equal(md.ValidationHash, hash) {
+		log.Debug("Not re-running validation for %s, nothing's changed", target.Label)
+		return nil
+	}
+	state.LogBuildResult(target, core.TargetBuilding, "Validating...")
+	env := core.ValidationEnvironment(state, target).ToSlice()
+	log.Debug("Validating target %s\nENVIRONMENT:\n%s\n%s", target.Label, env, target.Validation)
+	if _, combined, err := state.ProcessExecutor.ExecWithTimeoutShell(target, target.OutDir(), env, target.BuildTimeout, state.ShowAllOutput, false, process.NewSandboxConfig(target.Sandbox && !target.Network, target.Sandbox), target.Validation); err != nil {
+		return fmt.Errorf("Validation failed for %s: %s\n%s", target.Label, err, combined)
+	} else if md == nil {
+		return nil // Nowhere to record it; it'll be validated again next time.
 	}
-	return out, nil
+	md.ValidationHash = hash
+	return StoreTargetMetadata(target, md)
 }
 
 // buildTextFile runs the build action for text_file() rules
@@ -538,15 +636,15 @@ func buildTextFile(state *core.BuildState, target *core.BuildTarget) error {
 }
 
 // prepareOutputDirectories creates any directories the target has declared it will output into as a nicety
-func prepareOutputDirectories(target *core.BuildTarget) error {
+func prepareOutputDirectories(target *core.BuildTarget, tmpDir string) error {
 	for _, dir := range target.OutputDirectories {
-		if err := prepareParentDirs(target, dir.Dir()); err != nil {
+		if err := prepareParentDirs(tmpDir, dir.Dir()); err != nil {
 			return err
 		}
 	}
 
 	for _, out := range target.Outputs() {
-		if err := prepareParentDirs(target, out); err != nil {
+		if err := prepareParentDirs(tmpDir, out); err != nil {
 			return err
 		}
 	}
@@ -555,9 +653,9 @@ func prepareOutputDirectories(target *core.BuildTarget) error {
 
 // prepareParentDirs will create any parent directories of an output i.e. for the output foo/bar/baz it will create
 // foo and foo/bar
-func prepareParentDirs(target *core.BuildTarget, out string) error {
+func prepareParentDirs(tmpDir, out string) error {
 	if dir := filepath.Dir(out); dir != "." {
-		outPath := filepath.Join(target.TmpDir(), dir)
+		outPath := filepath.Join(tmpDir, dir)
 		if !core.PathExists(outPath) {
 			if err := os.MkdirAll(outPath, core.DirPermissions); err != nil {
 				return err
@@ -572,7 +670,7 @@ func prepareDirectories(target *core.BuildTarget) error {
 	if err := prepareDirectory(target.TmpDir(), true); err != nil {
 		return err
 	}
-	if err := prepareOutputDirectories(target); err != nil {
+	if err := prepareOutputDirectories(target, target.TmpDir()); err != nil {
 		return err
 	}
 	return prepareDirectory(target.OutDir(), false)
@@ -591,15 +689,15 @@ func prepareDirectory(directory string, remove bool) error {
 	return err
 }
 
-// Symlinks the source files of this rule into its temp directory.
-func prepareSources(state *core.BuildState, graph *core.BuildGraph, target *core.BuildTarget) error {
+// Symlinks the source files of this rule into the given temp directory (normally its own temp directory).
+func prepareSources(state *core.BuildState, graph *core.BuildGraph, target *core.BuildTarget, tmpDir string) error {
 	for src, tmp := range core.IterSources(state, graph, target, false) {
-		if err := core.PrepareSource(src, tmp); err != nil {
+		if err := core.PrepareSource(src, filepath.Join(tmpDir, strings.TrimPrefix(tmp, target.TmpDir()))); err != nil {
 			return err
 		}
 	}
 	if target.Stamp {
-		if err := fs.WriteFile(bytes.NewReader(core.StampFile(state.Config, target)), filepath.Join(target.TmpDir(), target.StampFileName()), 0644); err != nil {
+		if err := fs.WriteFile(bytes.NewReader(core.StampFile(state.Config, target)), filepath.Join(tmpDir, target.StampFileName()), 0644); err != nil {
 			return err
 		}
 	}
@@ -917,6 +1015,9 @@ func outputHash(target *core.BuildTarget, outputs []string, hasher *fs.PathHashe
 func checkRuleHashes(state *core.BuildState, target *core.BuildTarget, hash []byte) error {
 	if len(target.Hashes) == 0 {
 		return nil // nothing to check
+	} else if target.Stamp {
+		log.Debug("Not verifying output hashes of %s since it is stamped", target.Label)
+		return nil // outputs depend on the current revision so will not be consistent
 	}
 	outputs := target.FullOutputs()
 	hashes := target.UnprefixedHashes()
@@ -1211,7 +1312,7 @@ func build(state *core.BuildState, target *core.BuildTarget, inputHash []byte) (
 	if err != nil {
 		return nil, err
 	} else if workerCmd == "" {
-		metadata.Stdout, err = runBuildCommand(state, target, localCmd, inputHash)
+		metadata.Stdout, metadata.Stderr, err = runBuildCommand(state, target, localCmd, inputHash)
 		return metadata, err
 	}
 	return nil, fmt.Errorf("Persistent workers are no longer supported, found worker command: %s", workerCmd)
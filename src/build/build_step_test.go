@@ -13,6 +13,7 @@ import (
 	"io"
 	iofs "io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -43,6 +44,76 @@ func TestFailedBuildTarget(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestBuildOutputIsStoredInMetadata(t *testing.T) {
+	state, target := newState("//package1:target1w")
+	target.Command = "echo hello && echo 'warning: unused variable' >&2 && touch $OUT"
+	target.AddOutput("file1w")
+	err := buildTarget(state, target, false)
+	require.NoError(t, err)
+	md, err := loadTargetMetadata(target)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(md.Stdout))
+	assert.Equal(t, "warning: unused variable\n", string(md.Stderr))
+}
+
+func TestValidation(t *testing.T) {
+	state, target := newState("//package1:target1b")
+	target.AddOutput("file1b")
+	target.Validation = "grep -q 'output of' $OUT"
+	err := buildTarget(state, target, false)
+	assert.NoError(t, err)
+	assert.Equal(t, core.Built, target.State())
+	md, err := loadTargetMetadata(target)
+	require.NoError(t, err)
+	assert.NotEmpty(t, md.ValidationHash)
+	// Changing the validation command doesn't rebuild the target but does re-validate it.
+	target.Validation = "grep -q wibble $OUTS"
+	err = buildTarget(state, target, false)
+	assert.Error(t, err)
+	assert.Equal(t, core.Reused, target.State())
+}
+
+func TestKeepSandbox(t *testing.T) {
+	state, target := newState("//package1:target1c")
+	target.AddOutput("file1c")
+	target.Command = "false"
+	state.KeepSandbox = true
+	err := buildTarget(state, target, false)
+	assert.Error(t, err)
+	b, err := os.ReadFile(filepath.Join(target.TmpDir(), ".plz-env"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "export PKG='package1'\n")
+	assert.Contains(t, string(b), "export OUTS='file1c'\n")
+}
+
+func TestReproducible(t *testing.T) {
+	state, target := newState("//package1:reproducible")
+	target.AddOutput("file1")
+	target.Command = "echo -n $PKG > $OUT"
+	state.Reproduce = true
+	assert.NoError(t, buildTarget(state, target, false))
+	assert.Equal(t, core.Built, target.State())
+}
+
+func TestNotReproducible(t *testing.T) {
+	state, target := newState("//package1:not_reproducible")
+	target.AddOutput("file1")
+	target.Command = "echo -n $TMP_DIR > $OUT"
+	state.Reproduce = true
+	err := buildTarget(state, target, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "//package1:not_reproducible is not reproducible")
+	assert.Contains(t, err.Error(), "differs between builds")
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".plz-env")
+	assert.NoError(t, writeEnvFile(filename, []string{"A=b", "C=it's=here"}))
+	out, err := exec.Command("bash", "-c", "source "+filename+" && echo \"$A $C\"").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "b it's=here\n", string(out))
+}
+
 func TestBuildTargetWhichNeedsRebuilding(t *testing.T) {
 	// The output file for this target already exists, but it should still get rebuilt
 	// because there's no rule hash file.
@@ -200,6 +271,58 @@ func TestCacheRetrieval(t *testing.T) {
 	assert.Equal(t, core.Cached, target.State())
 }
 
+func TestStampedTargetSkipsCache(t *testing.T) {
+	// Stamped targets shouldn't be retrieved from the cache, since their outputs depend on the revision.
+	state, target := newState("//package1:target8")
+	target.AddOutput("file8")
+	target.Command = "echo -n $SCM_REVISION > $OUT"
+	target.Stamp = true
+	state.Cache = cache
+	err := buildTarget(state, target, false)
+	assert.NoError(t, err)
+	assert.Equal(t, core.Built, target.State())
+}
+
+func TestStampedTargetIsReused(t *testing.T) {
+	// The revision isn't part of the rule hash, so a stamped target whose rule hash matches is reused
+	// rather than rebuilt (as for remote builds, which look up the unstamped action first).
+	state, target := newState("//package1:stamped")
+	target.AddOutput("stamped")
+	target.Command = "echo -n $SCM_REVISION > $OUT"
+	target.Stamp = true
+	StoreTargetMetadata(target, new(core.BuildMetadata))
+	assert.NoError(t, writeRuleHash(state, target))
+	err := buildTarget(state, target, false)
+	assert.NoError(t, err)
+	assert.Equal(t, core.Reused, target.State())
+}
+
+func TestNetworkTargetSkipsCache(t *testing.T) {
+	// Targets with network access shouldn't be retrieved from the cache, since their outputs might change.
+	state, target := newState("//package1:target8")
+	target.AddOutput("file8")
+	target.Command = "echo -n 'downloaded' > $OUT"
+	target.Network = true
+	state.Cache = cache
+	err := buildTarget(state, target, false)
+	assert.NoError(t, err)
+	assert.Equal(t, core.Built, target.State())
+}
+
+func TestNetworkTargetWithHashesUsesCache(t *testing.T) {
+	// Unless they declare hashes, in which case their outputs are known.
+	state, target := newState("//package1:target8")
+	target.AddOutput("file8")
+	target.Command = "false" // Will fail if we try to build it.
+	target.Network = true
+	target.Hashes = []string{"wibble"}
+	state.Cache = cache
+	state.VerifyHashes = false
+	err := buildTarget(state, target, false)
+	assert.NoError(t, err)
+	assert.Equal(t, core.Cached, target.State())
+}
+
 func TestPostBuildFunctionAndCache(t *testing.T) {
 	// Test the often subtle and quick to anger interaction of post-build function and cache.
 	// In this case when it fails to retrieve the post-build output it should still call the function after building.
@@ -372,6 +495,12 @@ func TestCheckRuleHashes(t *testing.T) {
 	target.Hashes = []string{"37d6ae61eb7aba324b4633ef518a5a2e88feac81a0f65a67f9de40b55fe91277"}
 	err = checkRuleHashes(state, target, b)
 	assert.NoError(t, err)
+
+	// Stamped targets accept any hash, since their outputs vary with the revision
+	target.Hashes = []string{"630bff40cc8d5329e6176779493281ddb3e0add3"}
+	target.Stamp = true
+	err = checkRuleHashes(state, target, b)
+	assert.NoError(t, err)
 }
 
 func TestHashCheckers(t *testing.T) {
@@ -43,6 +43,7 @@ var KnownFields = map[string]bool{
 	"namedOutputs":                true,
 	"Licences":                    true,
 	"Sandbox":                     true,
+	"Network":                     true,
 	"Tools":                       true,
 	"namedTools":                  true,
 	"Secrets":                     true,
@@ -94,6 +95,8 @@ var KnownFields = map[string]bool{
 	// hash because they don't affect the actual output of the target.
 	"Subrepo":                true,
 	"AddedPostBuild":         true,
+	"RuleName":               true,
+	"Validation":             true, // Tracked separately so changing it only re-runs the validation.
 	"BuildTimeout":           true,
 	"state":                  true,
 	"completedRuns":          true,
@@ -0,0 +1,95 @@
+package build
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/fs"
+)
+
+// reproduceDirSuffix is appended to a target's temp directory to give the directory it's rebuilt in.
+const reproduceDirSuffix = "._reproduce"
+
+// checkReproducible builds a target a second time in a different directory, with its environment
+// in a different order, and checks that it produces exactly the same outputs as it did the first time.
+// It must be called after the target has been built but before its outputs are moved out of its temp dir.
+func checkReproducible(state *core.BuildState, target *core.BuildTarget, inputHash []byte) error {
+	_, _, command, err := core.WorkerCommandAndArgs(state, target)
+	if err != nil {
+		return err
+	}
+	dir := target.TmpDir() + reproduceDirSuffix
+	if err := prepareDirectory(dir, true); err != nil {
+		return err
+	} else if err := prepareOutputDirectories(target, dir); err != nil {
+		return err
+	} else if err := prepareSources(state, state.Graph, target, dir); err != nil {
+		return fmt.Errorf("Error preparing sources for %s: %s", target.Label, err)
+	} else if _, _, err := runBuildCommandIn(state, target, command, inputHash, dir, true); err != nil {
+		return err
+	}
+	outs := target.Outputs()
+	for _, out := range target.OutputDirectories {
+		outs = append(outs, out.Dir())
+	}
+	for _, out := range outs {
+		if err := compareOutputs(filepath.Join(target.TmpDir(), out), filepath.Join(dir, out)); err != nil {
+			return fmt.Errorf("%s is not reproducible: %w", target.Label, err)
+		}
+	}
+	if state.CleanWorkdirs {
+		if err := fs.RemoveAll(dir); err != nil {
+			log.Warning("Failed to remove temporary directory for %s: %s", target.Label, err)
+		}
+	}
+	return nil
+}
+
+// compareOutputs compares an output from two builds of a target (which may be a directory),
+// returning an error describing the first difference it finds.
+func compareOutputs(first, second string) error {
+	return fs.Walk(first, func(name string, isDir bool) error {
+		if isDir {
+			return nil
+		}
+		other := filepath.Join(second, strings.TrimPrefix(name, first))
+		a, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(other)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s was not created on the second build", name)
+		} else if err != nil {
+			return err
+		} else if !bytes.Equal(a, b) {
+			return fmt.Errorf("%s differs between builds:\n%s", name, describeDifference(name, other, a, b))
+		}
+		return nil
+	})
+}
+
+// describeDifference describes how two files differ. It uses diffoscope if it's available,
+// otherwise it falls back to a hex dump of the region around the first difference.
+func describeDifference(first, second string, a, b []byte) string {
+	if path, err := exec.LookPath("diffoscope"); err == nil {
+		// diffoscope exits with 1 when the files differ, so we only care whether it produced anything.
+		if out, _ := exec.Command(path, first, second).Output(); len(out) > 0 {
+			return string(out)
+		}
+	}
+	const context = 64
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	start := max(i-i%16-context/2, 0)
+	return fmt.Sprintf("First difference at byte %d (sizes %d and %d)\nFirst build:\n%sSecond build:\n%s",
+		i, len(a), len(b), hex.Dump(a[start:min(start+context, len(a))]), hex.Dump(b[start:min(start+context, len(b))]))
+}
@@ -5,12 +5,14 @@ package cache
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
@@ -23,6 +25,7 @@ import (
 type httpCache struct {
 	url      string
 	writable bool
+	cas      bool
 	client   *retryablehttp.Client
 
 	requestLimiter limiter
@@ -49,6 +52,12 @@ func (cache *httpCache) Store(target *core.BuildTarget, key []byte, files []stri
 		cache.requestLimiter.acquire()
 		defer cache.requestLimiter.release()
 
+		if cache.cas {
+			if err := cache.storeCAS(target, key, files); err != nil {
+				log.Warning("Failed to store files in HTTP cache: %s", err)
+			}
+			return
+		}
 		r, w := io.Pipe()
 		go cache.write(w, target, files)
 		req, err := retryablehttp.NewRequest(http.MethodPut, cache.makeURL(key), r)
@@ -64,14 +73,87 @@ func (cache *httpCache) Store(target *core.BuildTarget, key []byte, files []stri
 	}
 }
 
+// storeCAS stores the artifacts under the SHA-256 hash of their content, skipping the upload if
+// they're already there, and then stores that hash under the key.
+func (cache *httpCache) storeCAS(target *core.BuildTarget, key []byte, files []string) error {
+	f, err := os.CreateTemp("", "plz_http_cache_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	writeArtifacts(io.MultiWriter(f, h), target, files)
+	digest := hex.EncodeToString(h.Sum(nil))
+	casURL := cache.makeCASURL(digest)
+	if exists, err := cache.exists(casURL); err != nil {
+		return err
+	} else if exists {
+		log.Debug("%s: artifacts already exist in HTTP cache as %s", target.Label, digest)
+	} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	} else if err := cache.put(casURL, f); err != nil {
+		return err
+	}
+	return cache.put(cache.makeURL(key), strings.NewReader(digest))
+}
+
+// exists returns true if the given URL exists on the remote.
+func (cache *httpCache) exists(url string) (bool, error) {
+	req, err := retryablehttp.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := cache.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return true, nil
+}
+
+// put uploads the given content to the remote.
+func (cache *httpCache) put(url string, body io.Reader) error {
+	req, err := retryablehttp.NewRequest(http.MethodPut, url, body)
+	if err != nil {
+		return err
+	}
+	resp, err := cache.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, string(b))
+	}
+	return nil
+}
+
 // makeURL returns the remote URL for a key.
 func (cache *httpCache) makeURL(key []byte) string {
 	return cache.url + "/" + hex.EncodeToString(key)
 }
 
+// makeCASURL returns the remote URL for content with the given hex-encoded SHA-256 hash.
+func (cache *httpCache) makeCASURL(digest string) string {
+	return cache.url + "/cas/" + digest
+}
+
 // write writes a series of files into the given Writer.
 func (cache *httpCache) write(w io.WriteCloser, target *core.BuildTarget, files []string) {
 	defer w.Close()
+	writeArtifacts(w, target, files)
+}
+
+// writeArtifacts writes a series of files into the given Writer as a gzipped tarball.
+func writeArtifacts(w io.Writer, target *core.BuildTarget, files []string) {
 	gzw := gzip.NewWriter(w)
 	defer gzw.Close()
 	tw := tar.NewWriter(gzw)
@@ -137,22 +219,71 @@ func (cache *httpCache) Retrieve(target *core.BuildTarget, key []byte, _ []strin
 }
 
 func (cache *httpCache) retrieve(key []byte) (bool, error) {
-	req, err := retryablehttp.NewRequest(http.MethodGet, cache.makeURL(key), nil)
+	body, err := cache.get(cache.makeURL(key))
+	if body == nil || err != nil {
+		return false, err
+	}
+	defer body.Close()
+	if !cache.cas {
+		return readArtifacts(body)
+	}
+	// The key refers to the hash of the artifacts, which we then have to fetch separately.
+	b, err := io.ReadAll(io.LimitReader(body, 2*sha256.Size+1))
 	if err != nil {
 		return false, err
 	}
-	resp, err := cache.client.Do(req)
+	digest := string(b)
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != 2*sha256.Size {
+		return false, fmt.Errorf("invalid content hash %q", digest)
+	}
+	content, err := cache.get(cache.makeCASURL(digest))
+	if content == nil || err != nil {
+		return false, err
+	}
+	defer content.Close()
+	// Download it to a temporary file first so nothing is extracted until we've verified it.
+	f, err := os.CreateTemp("", "plz_http_cache_")
 	if err != nil {
 		return false, err
 	}
-	defer resp.Body.Close()
+	defer os.Remove(f.Name())
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), content); err != nil {
+		return false, err
+	} else if actual := hex.EncodeToString(h.Sum(nil)); actual != digest {
+		return false, fmt.Errorf("content hash mismatch; expected %s, was %s", digest, actual)
+	} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return readArtifacts(f)
+}
+
+// get fetches the given URL. It returns a nil body if it doesn't exist, in which case the caller
+// should not close it.
+func (cache *httpCache) get(url string) (io.ReadCloser, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cache.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	if resp.StatusCode == http.StatusNotFound {
-		return false, nil // doesn't exist - not an error
+		resp.Body.Close()
+		return nil, nil // doesn't exist - not an error
 	} else if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		b, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("%s", string(b))
+		return nil, fmt.Errorf("%s", string(b))
 	}
-	gzr, err := gzip.NewReader(resp.Body)
+	return resp.Body, nil
+}
+
+// readArtifacts reads a gzipped tarball of artifacts, as written by writeArtifacts.
+func readArtifacts(r io.Reader) (bool, error) {
+	gzr, err := gzip.NewReader(r)
 	if err != nil {
 		return false, err
 	}
@@ -227,6 +358,7 @@ func newHTTPCache(config *core.Configuration) *httpCache {
 	return &httpCache{
 		url:      config.Cache.HTTPURL.String(),
 		writable: config.Cache.HTTPWriteable,
+		cas:      config.Cache.HTTPCAS,
 		client: &retryablehttp.Client{
 			HTTPClient: &http.Client{
 				Timeout: time.Duration(config.Cache.HTTPTimeout),
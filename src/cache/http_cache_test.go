@@ -1,6 +1,11 @@
 package cache
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"net"
 	"net/http"
@@ -20,12 +25,15 @@ func init() {
 		log.Fatalf("%s", err)
 	}
 	go func() {
-		http.Serve(lis, &testServer{
-			data: map[string][]byte{},
-		})
+		http.Serve(lis, server)
 	}()
 }
 
+var server = &testServer{
+	data: map[string][]byte{},
+	puts: map[string]int{},
+}
+
 func TestStoreAndRetrieveHTTP(t *testing.T) {
 	target := core.NewBuildTarget(core.NewBuildLabel("pkg/name", "label_name"))
 	target.AddOutput("testfile2")
@@ -49,14 +57,57 @@ func TestStoreAndRetrieveHTTP(t *testing.T) {
 	assert.Equal(t, b, b2)
 }
 
+func TestStoreAndRetrieveHTTPCAS(t *testing.T) {
+	target := core.NewBuildTarget(core.NewBuildLabel("pkg/name", "label_name"))
+	target.AddOutput("testfile2")
+	config := core.DefaultConfiguration()
+	config.Cache.HTTPURL = "http://127.0.0.1:8989"
+	config.Cache.HTTPWriteable = true
+	config.Cache.HTTPCAS = true
+	cache := newHTTPCache(config)
+
+	// The same artifacts under two different keys are only stored once.
+	cache.Store(target, []byte("cas_key_1"), target.Outputs())
+	cache.Store(target, []byte("cas_key_2"), target.Outputs())
+	digest := string(server.data["/"+hex.EncodeToString([]byte("cas_key_1"))])
+	assert.Equal(t, digest, string(server.data["/"+hex.EncodeToString([]byte("cas_key_2"))]))
+	content := server.data["/cas/"+digest]
+	assert.NotEmpty(t, content)
+	assert.Equal(t, 1, server.puts["/cas/"+digest])
+	h := sha256.Sum256(content)
+	assert.Equal(t, hex.EncodeToString(h[:]), digest)
+
+	assert.True(t, cache.Retrieve(target, []byte("cas_key_2"), nil))
+	assert.False(t, cache.Retrieve(target, []byte("cas_key_3"), nil))
+
+	// Corrupted content isn't accepted.
+	server.data["/cas/"+digest] = append([]byte{}, content[:len(content)-1]...)
+	assert.False(t, cache.Retrieve(target, []byte("cas_key_1"), nil))
+	// Nor is anything extracted from a valid archive that doesn't match its hash.
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "corrupted", Typeflag: tar.TypeReg, Mode: 0644, Size: 5}))
+	_, err := tw.Write([]byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gzw.Close())
+	server.data["/cas/"+digest] = buf.Bytes()
+	assert.False(t, cache.Retrieve(target, []byte("cas_key_1"), nil))
+	assert.NoFileExists(t, "corrupted")
+	server.data["/cas/"+digest] = content
+}
+
 type testServer struct {
 	data map[string][]byte
+	puts map[string]int
 }
 
 func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPut {
 		b, _ := io.ReadAll(r.Body)
 		s.data[r.URL.Path] = b
+		s.puts[r.URL.Path]++
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
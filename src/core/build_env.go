@@ -8,8 +8,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/thought-machine/please/src/fs"
 	"github.com/thought-machine/please/src/scm"
@@ -185,7 +187,37 @@ func TestEnvironment(state *BuildState, target *BuildTarget, testDir string, run
 	if len(state.TestArgs) > 0 {
 		env["TESTS"] = strings.Join(state.TestArgs, " ")
 	}
-	return withUserProvidedEnv(target, env)
+	env = withUserProvidedEnv(target, env)
+	for k, v := range state.TestEnv {
+		env[k] = v
+	}
+	return env
+}
+
+// ReadEnvFile reads a file of environment variables in .env format, i.e. KEY=value on each line.
+// Blank lines and lines beginning with # are ignored, and values may optionally be quoted.
+func ReadEnvFile(filename string) (BuildEnv, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	env := BuildEnv{}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if k = strings.TrimSpace(k); !found || k == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value, got %s", filename, i+1, line)
+		}
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		env[k] = v
+	}
+	return env, nil
 }
 
 // RunEnvironment creates the environment variables for a `plz run --env`.
@@ -202,6 +234,21 @@ func RunEnvironment(state *BuildState, target *BuildTarget, inTmpDir bool) Build
 	return withUserProvidedEnv(target, env)
 }
 
+// ValidationEnvironment creates the environment variables for a target's validation command,
+// which runs in its output directory.
+func ValidationEnvironment(state *BuildState, target *BuildTarget) BuildEnv {
+	env := TargetEnvironment(state, target)
+
+	outEnv := target.Outputs()
+	env["OUTS"] = strings.Join(outEnv, " ")
+	// The OUT variable is only available on rules that have a single output.
+	if len(outEnv) == 1 {
+		env["OUT"] = resolveOut(outEnv[0], ".", false)
+	}
+
+	return withUserProvidedEnv(target, env)
+}
+
 // ExecEnvironment creates the environment variables for a `plz exec`.
 func ExecEnvironment(state *BuildState, target *BuildTarget, execDir string) BuildEnv {
 	env := RuntimeEnvironment(state, target, true, true)
@@ -319,8 +366,8 @@ var stampEnvOnce sync.Once
 func initStampEnv() {
 	repoScm := scm.NewFallback(RepoRoot)
 	var wg sync.WaitGroup
-	var revision, commitDate, describe string
-	wg.Add(2)
+	var revision, commitDate, describe, branch, timestamp string
+	wg.Add(3)
 	go func() {
 		revision = repoScm.CurrentRevIdentifier(true)
 		describe = repoScm.DescribeIdentifier(revision)
@@ -328,13 +375,30 @@ func initStampEnv() {
 	}()
 	go func() {
 		commitDate = repoScm.CurrentRevDate("20060102")
+		// This is the time of the commit rather than of the build, so it's reproducible and doesn't
+		// change the hash of remote build actions every time.
+		if t, err := time.Parse(time.RFC3339, repoScm.CurrentRevDate(time.RFC3339)); err == nil {
+			timestamp = strconv.FormatInt(t.Unix(), 10)
+		}
+		wg.Done()
+	}()
+	go func() {
+		// This gives us the branch name if we're on one, or the commit hash otherwise.
+		branch = repoScm.CurrentRevIdentifier(false)
 		wg.Done()
 	}()
 	wg.Wait()
+	if branch == revision {
+		branch = "" // Detached HEAD, there isn't a branch.
+	}
 	stampEnv = BuildEnv{
 		"SCM_COMMIT_DATE": commitDate,
 		"SCM_REVISION":    revision,
 		"SCM_DESCRIBE":    describe,
+		"GIT_COMMIT":      revision,
+		"GIT_BRANCH":      branch,
+		"GIT_DESCRIBE":    describe,
+		"BUILD_TIMESTAMP": timestamp,
 	}
 }
 
@@ -363,6 +427,16 @@ func toolPaths(state *BuildState, tools []BuildInput, abs bool) []string {
 	return ret
 }
 
+// ToolPaths returns the absolute paths to the outputs of the given tools.
+func ToolPaths(state *BuildState, tools []BuildInput) []string {
+	ret := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		// A tool that's a build target with multiple outputs gives us a space-separated list.
+		ret = append(ret, strings.Fields(toolPath(state, tool, true))...)
+	}
+	return ret
+}
+
 // ReplaceEnvironment is a function suitable for passing to os.Expand to replace environment
 // variables from this BuildEnv.
 func (env BuildEnv) ReplaceEnvironment(s string) string {
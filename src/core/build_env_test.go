@@ -2,6 +2,7 @@ package core
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -199,3 +200,28 @@ func TestDeduplicateEnvVars(t *testing.T) {
 	env := TestEnvironment(state, target, "/path/to/runtime/dir", 1)
 	assert.Equal(t, env["COVERAGE"], "wibble")
 }
+
+func TestReadEnvFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.env")
+	err := os.WriteFile(filename, []byte(`# Database config
+DATABASE_URL=postgres://localhost:5432/test
+
+export API_KEY = "abc=123"
+EMPTY=
+QUOTED='single'
+`), 0644)
+	assert.NoError(t, err)
+	env, err := ReadEnvFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, BuildEnv{
+		"DATABASE_URL": "postgres://localhost:5432/test",
+		"API_KEY":      "abc=123",
+		"EMPTY":        "",
+		"QUOTED":       "single",
+	}, env)
+
+	err = os.WriteFile(filename, []byte("NOT_AN_ASSIGNMENT\n"), 0644)
+	assert.NoError(t, err)
+	_, err = ReadEnvFile(filename)
+	assert.Error(t, err)
+}
@@ -200,6 +200,9 @@ type BuildTarget struct {
 	Env map[string]string `name:"env"`
 	// The content of text_file() rules
 	FileContent string `name:"content"`
+	// A shell command that's run against the outputs after the target builds. If it fails the target
+	// fails. Deliberately not part of the rule hash so changing it doesn't rebuild the target.
+	Validation string `name:"validation"`
 	// Represents the state of this build target (see below)
 	state int32 `print:"false"`
 	// If true, the target is needed for a subinclude and therefore we will have to make sure its
@@ -216,6 +219,8 @@ type BuildTarget struct {
 	TestOnly bool `name:"test_only"`
 	// True if the build action is sandboxed.
 	Sandbox bool
+	// True if the build action keeps network access even when it's sandboxed.
+	Network bool
 	// True if this target needs access to its transitive dependencies to build.
 	// This would be false for most 'normal' genrules but true for eg. compiler steps
 	// that need to build in everything.
@@ -241,6 +246,9 @@ type BuildTarget struct {
 	IsTextFile bool `print:"false"`
 	// Marks that the target was added in a post-build function.
 	AddedPostBuild bool `print:"false"`
+	// The name of the rule that created this target (e.g. go_library, genrule), i.e. the
+	// outermost function called from the BUILD file. This is build_rule if it was called directly.
+	RuleName string `print:"false"`
 	// If true, the interactive progress display will try to infer the target's progress
 	// via some heuristics on its output.
 	showProgress atomic.Bool `name:"progress"`
@@ -271,6 +279,8 @@ type BuildMetadata struct {
 	Test bool
 	// True if the results were retrieved from a cache, false if we ran the full build action.
 	Cached bool
+	// Hash of the validation command & outputs the last time the target passed validation.
+	ValidationHash []byte
 	// VersionTag is an integer representing the version of this cache object. If this doesn't match the
 	// expected version above, Please will not use this cached metadata.
 	VersionTag int
@@ -447,6 +457,12 @@ func (target *BuildTarget) IsTest() bool {
 	return target.Test != nil
 }
 
+// Cacheable returns false for targets that use the network to build without declaring hashes for
+// their outputs; those could be anything, so aren't safe to share via the cache.
+func (target *BuildTarget) Cacheable() bool {
+	return !target.Network || len(target.Hashes) > 0
+}
+
 // CompleteRun completes a run and returns true if this was the last run
 func (target *BuildTarget) CompleteRun(state *BuildState) bool {
 	target.mutex.Lock()
@@ -59,6 +59,12 @@ const DefaultPleaseLocation = "~/.please"
 // DefaultPath is the default location please looks for programs in
 var DefaultPath = []string{"/usr/local/bin", "/usr/bin", "/bin"}
 
+// DefaultPluginRepos are the default URL templates that plugins are downloaded from.
+var DefaultPluginRepos = []string{
+	"https://github.com/{owner}/{plugin}/archive/{revision}.zip",
+	"https://github.com/{owner}/{plugin}-rules/archive/{revision}.zip",
+}
+
 // readConfigFileOnly reads a single config file into the config struct
 func readConfigFileOnly(fs iofs.FS, config *Configuration, filename string, quiet bool) error {
 	log.Debug("Attempting to read config from %s...", filename)
@@ -212,10 +218,7 @@ func ReadConfigFiles(fs iofs.FS, filenames []string, profiles []string) (*Config
 
 	// Set default values for slices. These add rather than overwriting so we can't set
 	// them upfront as we would with other config values.
-	setDefault(&config.Please.PluginRepo,
-		"https://github.com/{owner}/{plugin}/archive/{revision}.zip",
-		"https://github.com/{owner}/{plugin}-rules/archive/{revision}.zip",
-	)
+	setDefault(&config.Please.PluginRepo, slices.Clone(DefaultPluginRepos)...)
 	if usingBazelWorkspace {
 		setDefault(&config.Parse.BuildFileName, "BUILD.bazel", "BUILD", "BUILD.plz")
 	} else {
@@ -410,6 +413,7 @@ func DefaultConfiguration() *Configuration {
 	config.Remote.UploadDirs = true
 	config.Remote.CacheDuration = cli.Duration(10000 * 24 * time.Hour) // Effectively forever.
 	config.Remote.Shell = "bash"
+	config.Remote.KeepaliveTimeout = cli.Duration(20 * time.Second)
 	config.Go.GoTool = "go"
 	config.Go.CgoCCTool = "gcc"
 	config.Go.DelveTool = "dlv"
@@ -526,6 +530,7 @@ type Configuration struct {
 		UpdateGitignore      bool         `help:"Whether to automatically update the nearest gitignore with generated sources"`
 		ParallelDownloads    int          `help:"Max number of remote_file downloads to run in parallel."`
 		ArcatTool            string       `help:"Defines the tool used to concatenate files which we use in various build rules. Defaults to Arcat." var:"ARCAT_TOOL"`
+		PreTargets           []BuildLabel `help:"Targets to build before any others when running plz build, test etc. These are added to any passed with --pre on the command line.\nThis is useful for targets that generate code other targets depend on, which must exist before those can be built." example:"//tools/codegen:all"`
 	} `help:"A config section describing general settings related to building targets in Please.\nSince Please is by nature about building things, this only has the most generic properties; most of the more esoteric properties are configured in their own sections."`
 	BuildConfig map[string]string `help:"A section of arbitrary key-value properties that are made available in the BUILD language. These are often useful for writing custom rules that need some configurable property.\n\n[buildconfig]\nandroid-tools-version = 23.0.2\n\nFor example, the above can be accessed as CONFIG.ANDROID_TOOLS_VERSION."`
 	BuildEnv    map[string]string `help:"A set of extra environment variables to define for build rules. For example:\n\n[buildenv]\nsecret-passphrase = 12345\n\nThis would become SECRET_PASSPHRASE for any rules. These can be useful for passing secrets into custom rules; any variables containing SECRET or PASSWORD won't be logged.\n\nIt's also useful if you'd like internal tools to honour some external variable."`
@@ -541,6 +546,7 @@ type Configuration struct {
 		HTTPTimeout                cli.Duration `help:"Timeout for operations contacting the HTTP cache, in seconds."`
 		HTTPConcurrentRequestLimit int          `help:"The maximum amount of concurrent requests that can be open. Default 20."`
 		HTTPRetry                  int          `help:"The maximum number of retries before a request will give up, if a request is retryable"`
+		HTTPCAS                    bool         `help:"Stores artifacts in the HTTP cache under the SHA-256 hash of their content, at cas/<hash>, with the target's key referring to that hash. Identical artifacts are then only uploaded and stored once; before uploading we check whether they already exist with a HEAD request.\nThe server must support HEAD requests; http_cache does, and verifies the hashes with --cas_mode."`
 		StoreCommand               string       `help:"Use a custom command to store cache entries."`
 		RetrieveCommand            string       `help:"Use a custom command to retrieve cache entries."`
 	} `help:"Please has several built-in caches that can be configured in its config file.\n\nThe simplest one is the directory cache which by default is written into the .plz-cache directory. This allows for fast retrieval of code that has been built before (for example, when swapping Git branches).\n\nThere is also a remote RPC cache which allows using a centralised server to store artifacts. A typical pattern here is to have your CI system write artifacts into it and give developers read-only access so they can reuse its work.\n\nFinally there's a HTTP cache which is very similar, but a little obsolete now since the RPC cache outperforms it and has some extra features. Otherwise the two have similar semantics and share quite a bit of implementation.\n\nPlease has server implementations for both the RPC and HTTP caches."`
@@ -560,29 +566,36 @@ type Configuration struct {
 		ExcludeableTargets []BuildLabel `help:"If set, only targets that match these wildcards will be allowed to opt out of the sandbox"`
 	} `help:"A config section describing settings relating to sandboxing of build actions."`
 	Remote struct {
-		URL                     string       `help:"URL for the remote server."`
-		CASURL                  string       `help:"URL for the CAS service, if it is different to the main one."`
-		AssetURL                string       `help:"URL for the remote asset server, if it is different to the main one."`
-		NumExecutors            int          `help:"Maximum number of remote executors to use simultaneously."`
-		Instance                string       `help:"Remote instance name to request; depending on the server this may be required."`
-		Name                    string       `help:"A name for this worker instance. This is attached to artifacts uploaded to remote storage." example:"agent-001"`
-		DisplayURL              string       `help:"A URL to browse the remote server with (e.g. using buildbarn-browser). Only used when printing hashes."`
-		TokenFile               string       `help:"A file containing a token that is attached to outgoing RPCs to authenticate them. This is somewhat bespoke; we are still investigating further options for authentication."`
-		Timeout                 cli.Duration `help:"Timeout for connections made to the remote server."`
-		Secure                  bool         `help:"Whether to use TLS for communication or not."`
-		VerifyOutputs           bool         `help:"Whether to verify all outputs are present after a cached remote execution action. Depending on your server implementation, you may require this to ensure files are really present."`
-		UploadDirs              bool         `help:"Uploads individual directory blobs after build actions. This might not be necessary with some servers, but if you aren't sure, you should leave it on."`
-		OptionalOutputsRequired bool         `help:"Requires that any optional outputs of build actions (optional test outputs, coverage when not opted out of) are produced. By default this is a non-fatal failure, but the actions may not cache remotely."`
-		Shell                   string       `help:"Path to the shell to use to execute actions in. Default is 'bash' which will be looked up by the server."`
-		Platform                []string     `help:"Platform properties to request from remote workers, in the format key=value."`
-		CacheDuration           cli.Duration `help:"Length of time before we re-check locally cached build actions. Default is unlimited."`
-		BuildID                 string       `help:"ID of the build action that's being run, to attach to remote requests. If not set then one is automatically generated."`
+		URL                          string       `help:"URL for the remote server."`
+		CASURL                       string       `help:"URL for the CAS service, if it is different to the main one."`
+		AssetURL                     string       `help:"URL for the remote asset server, if it is different to the main one."`
+		NumExecutors                 int          `help:"Maximum number of remote executors to use simultaneously."`
+		Instance                     string       `help:"Remote instance name to request; depending on the server this may be required."`
+		Name                         string       `help:"A name for this worker instance. This is attached to artifacts uploaded to remote storage." example:"agent-001"`
+		DisplayURL                   string       `help:"A URL to browse the remote server with (e.g. using buildbarn-browser). Only used when printing hashes."`
+		TokenFile                    string       `help:"A file containing a token that is attached to outgoing RPCs to authenticate them. This is somewhat bespoke; we are still investigating further options for authentication."`
+		OIDCTokenURL                 cli.URL      `help:"URL of an OIDC authorization server's token endpoint. If set, short-lived tokens are fetched from it using the client credentials flow and attached to outgoing RPCs; they're cached and refreshed shortly before they expire. Can't be combined with TokenFile." example:"https://auth.example.com/token"`
+		OIDCClientCredentials        string       `help:"A file containing the client ID and secret to authenticate to OIDCTokenURL with, in the form client_id:client_secret."`
+		Timeout                      cli.Duration `help:"Timeout for connections made to the remote server."`
+		KeepaliveTime                cli.Duration `help:"Interval after which the client pings the remote server if it hasn't seen any activity on the connection, to stop it being dropped by intermediate proxies or load balancers. Off by default. The server's keepalive enforcement policy must allow pings this frequently, otherwise it will close the connection with a too_many_pings error; grpc-go servers only allow one every 5 minutes unless their keepalive.EnforcementPolicy sets a lower MinTime."`
+		KeepaliveTimeout             cli.Duration `help:"Length of time to wait for a response to a keepalive ping before the connection is considered dead."`
+		KeepalivePermitWithoutStream bool         `help:"Whether to send keepalive pings even when there are no active RPCs on the connection. The server's keepalive.EnforcementPolicy must also set PermitWithoutStream for this to be allowed."`
+		Secure                       bool         `help:"Whether to use TLS for communication or not."`
+		VerifyOutputs                bool         `help:"Whether to verify all outputs are present after a cached remote execution action. Depending on your server implementation, you may require this to ensure files are really present."`
+		UploadDirs                   bool         `help:"Uploads individual directory blobs after build actions. This might not be necessary with some servers, but if you aren't sure, you should leave it on."`
+		OptionalOutputsRequired      bool         `help:"Requires that any optional outputs of build actions (optional test outputs, coverage when not opted out of) are produced. By default this is a non-fatal failure, but the actions may not cache remotely."`
+		Shell                        string       `help:"Path to the shell to use to execute actions in. Default is 'bash' which will be looked up by the server."`
+		Platform                     []string     `help:"Platform properties to request from remote workers, in the format key=value."`
+		CacheDuration                cli.Duration `help:"Length of time before we re-check locally cached build actions. Default is unlimited."`
+		BuildID                      string       `help:"ID of the build action that's being run, to attach to remote requests. If not set then one is automatically generated."`
 	} `help:"Settings related to remote execution & caching using the Google remote execution APIs. This section is still experimental and subject to change."`
 	Size  map[string]*Size `help:"Named sizes of targets; these are the definitions of what can be passed to the 'size' argument."`
 	Cover struct {
-		FileExtension    []string `help:"Extensions of files to consider for coverage.\nDefaults to .go, .py, .java, .tsx, .ts, .js, .cc, .h, and .c"`
-		ExcludeExtension []string `help:"Extensions of files to exclude from coverage.\nTypically this is for generated code; the default is to exclude protobuf extensions like .pb.go, _pb2.py, etc."`
-		ExcludeGlob      []string `help:"Exclude glob patterns from coverage.\nTypically this is for generated code and it is useful when there is no other discrimination possible."`
+		FileExtension           []string `help:"Extensions of files to consider for coverage.\nDefaults to .go, .py, .java, .tsx, .ts, .js, .cc, .h, and .c"`
+		ExcludeExtension        []string `help:"Extensions of files to exclude from coverage.\nTypically this is for generated code; the default is to exclude protobuf extensions like .pb.go, _pb2.py, etc."`
+		ExcludeGlob             []string `help:"Exclude glob patterns from coverage.\nTypically this is for generated code and it is useful when there is no other discrimination possible."`
+		MinLineCoverage         int      `help:"Minimum percentage of lines that must be covered in every file. plz cover fails if any file falls below this.\nDefaults to 0, which disables the check." example:"80"`
+		MinLineCoverageExcludes []string `help:"Glob patterns of files that are exempt from MinLineCoverage." example:"**/testdata/**"`
 	} `help:"Configuration relating to coverage reports."`
 	Gc struct {
 		Keep      []BuildLabel `help:"Marks targets that gc should always keep. Can include meta-targets such as //test/... and //docs:all."`
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+)
+
+// ConfigSchemaURL is where the JSON schema for the configuration is published.
+const ConfigSchemaURL = "https://please.build/plzconfig.schema.json"
+
+// A JSONSchema is the subset of JSON Schema that we need to describe the configuration.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// ConfigSchema returns a JSON schema describing the configuration, as output by `plz query config --json`.
+// It's generated from the field types and their help & options tags.
+func ConfigSchema() *JSONSchema {
+	schema := structSchema(reflect.TypeOf(Configuration{}))
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.ID = ConfigSchemaURL
+	schema.Title = "Please configuration"
+	schema.Properties["$schema"] = &JSONSchema{Type: "string"}
+	return schema
+}
+
+// structSchema returns the schema for a config section (or a struct within one).
+func structSchema(t reflect.Type) *JSONSchema {
+	schema := &JSONSchema{
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		} else if field.Tag.Get("gcfg") == "extra_values" {
+			// These are flattened into the struct itself.
+			schema.AdditionalProperties = typeSchema(field.Type.Elem(), "")
+			continue
+		}
+		s := typeSchema(field.Type, field.Tag.Get("options"))
+		s.Description = strings.ReplaceAll(field.Tag.Get("help"), "\\n", "\n")
+		schema.Properties[strings.ToLower(field.Name)] = s
+	}
+	return schema
+}
+
+// typeSchema returns the schema for a single value of the given type.
+// options is the comma-separated list of permitted values, if there is one.
+func typeSchema(t reflect.Type, options string) *JSONSchema {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		// These are given as strings in the config file, but some come out in their underlying form in JSON.
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return &JSONSchema{Type: []string{"integer", "string"}}
+		}
+		return &JSONSchema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), options)
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice:
+		// Unset slices & maps are output as null.
+		return &JSONSchema{Type: []string{"array", "null"}, Items: typeSchema(t.Elem(), options)}
+	case reflect.Map:
+		return &JSONSchema{Type: []string{"object", "null"}, AdditionalProperties: typeSchema(t.Elem(), "")}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	schema := &JSONSchema{Type: "string"}
+	if options != "" {
+		schema.Enum = strings.Split(options, ",")
+	}
+	return schema
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/please-build/gcfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSchema(t *testing.T) {
+	schema := ConfigSchema()
+	assert.Equal(t, ConfigSchemaURL, schema.ID)
+	build := schema.Properties["build"]
+	require.NotNil(t, build)
+	assert.Equal(t, "object", build.Type)
+	assert.Equal(t, "boolean", build.Properties["xattrs"].Type)
+	assert.Equal(t, []string{"integer", "string"}, build.Properties["timeout"].Type)
+	assert.Equal(t, []string{"sha1", "sha256", "blake3", "xxhash", "crc32", "crc64"}, build.Properties["hashfunction"].Enum)
+	assert.Equal(t, "string", schema.Properties["buildenv"].AdditionalProperties.(*JSONSchema).Type)
+	assert.NotEmpty(t, build.Properties["path"].Description)
+}
+
+func TestConfigSchemaMatchesConfig(t *testing.T) {
+	config := DefaultConfiguration()
+	config.Plugin = map[string]*Plugin{
+		"go": {Target: ParseBuildLabel("//plugins:go", ""), ExtraValues: map[string][]string{"gotool": {"go"}}},
+	}
+	data, err := gcfg.RawJSON(config)
+	require.NoError(t, err)
+	var v interface{}
+	require.NoError(t, json.Unmarshal(data, &v))
+	// Round-trip the schema through JSON so we validate what we'd actually output.
+	data, err = json.Marshal(ConfigSchema())
+	require.NoError(t, err)
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &schema))
+	assert.NoError(t, validateSchema("", schema, v))
+}
+
+// validateSchema is a minimal validator for the subset of JSON schema that ConfigSchema generates.
+func validateSchema(path string, schema map[string]interface{}, v interface{}) error {
+	if !schemaTypeMatches(schema["type"], v) {
+		return fmt.Errorf("%s: %v does not match type %v", path, v, schema["type"])
+	}
+	if enum, present := schema["enum"]; present {
+		if !containsValue(enum.([]interface{}), v) {
+			return fmt.Errorf("%s: %v is not one of %v", path, v, enum)
+		}
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		for k, val := range v {
+			if prop, present := props[k]; present {
+				if err := validateSchema(path+"."+k, prop.(map[string]interface{}), val); err != nil {
+					return err
+				}
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				if err := validateSchema(path+"."+k, additional, val); err != nil {
+					return err
+				}
+			} else if schema["additionalProperties"] == false {
+				return fmt.Errorf("%s: unexpected property %s", path, k)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			if err := validateSchema(fmt.Sprintf("%s[%d]", path, i), schema["items"].(map[string]interface{}), val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func schemaTypeMatches(typ interface{}, v interface{}) bool {
+	if types, ok := typ.([]interface{}); ok {
+		for _, t := range types {
+			if schemaTypeMatches(t, v) {
+				return true
+			}
+		}
+		return false
+	}
+	switch v := v.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == float64(int64(v)))
+	case string:
+		return typ == "string"
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	}
+	return false
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -202,6 +202,17 @@ func TestReadByteSizes(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestReadPreTargets(t *testing.T) {
+	config, err := ReadConfigFiles(fs.HostFS, []string{"src/core/test_data/pretargets_good.plzconfig"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []BuildLabel{
+		ParseBuildLabel("//tools/codegen:all", ""),
+		ParseBuildLabel("//src/proto:protos", ""),
+	}, config.Build.PreTargets)
+	_, err = ReadConfigFiles(fs.HostFS, []string{"src/core/test_data/pretargets_bad.plzconfig"}, nil)
+	assert.Error(t, err)
+}
+
 func TestCompletions(t *testing.T) {
 	config := DefaultConfiguration()
 	completions := config.Completions("python.pip")
@@ -169,6 +169,8 @@ type BuildState struct {
 	TargetHasher TargetHasher
 	// Arguments to tests.
 	TestArgs []string
+	// Extra environment variables to set for tests. They don't contribute to the test's hash.
+	TestEnv BuildEnv
 	// Labels of targets that we will include / exclude
 	Include, Exclude []string
 	// Actual targets to exclude from discovery
@@ -183,6 +185,8 @@ type BuildState struct {
 	Coverage TestCoverage
 	// True if we want to keep going on build failures and not exit early on the first error encountered
 	KeepGoing bool
+	// With KeepGoing, the number of targets that can fail before we stop anyway. 0 means no limit.
+	MaxErrors int
 	// True if we require rule hashes to be correctly verified (usually the case).
 	VerifyHashes bool
 	// True if tests should calculate coverage metrics
@@ -210,14 +214,24 @@ type BuildState struct {
 	TestSequentially bool
 	// True to clean working directories after successful builds.
 	CleanWorkdirs bool
+	// True to record the environment of failed builds in their working directories.
+	KeepSandbox bool
+	// True to build targets twice and check that their outputs are the same.
+	Reproduce bool
 	// True if we're forcing a rebuild of the original targets.
 	ForceRebuild bool
 	// True if we're forcing to rerun tests of the targets.
 	ForceRerun bool
 	// True to always show test output, even on success.
 	ShowTestOutput bool
+	// True to show test output only for tests that fail or are flaky.
+	ShowTestOutputOnFailure bool
 	// True to print all output of all tasks to stderr.
 	ShowAllOutput bool
+	// True to print the output of build actions that succeed (which is usually compiler warnings).
+	ShowBuildWarnings bool
+	// True to print the output of build actions again when their outputs are retrieved from the cache.
+	ReplayBuildWarnings bool
 	// Port specified when debugging a target in server mode.
 	DebugPort int
 	// True to attach a debugger on test failure.
@@ -226,6 +240,8 @@ type BuildState struct {
 	XattrsSupported bool
 	// Number of times to run each test target. 1 == once each, plus flakes if necessary.
 	NumTestRuns uint16
+	// Number of shards to split tests between, and which one of them we're running. Zero if not sharding.
+	TestShardCount, TestShardIndex int
 	// Experimental directories
 	experimentalLabels []BuildLabel
 	// Various items for tracking progress.
@@ -478,7 +494,17 @@ func (state *BuildState) ShouldInclude(target *BuildTarget) bool {
 			return false
 		}
 	}
-	return target.ShouldInclude(state.Include, state.Exclude)
+	return state.inTestShard(target) && target.ShouldInclude(state.Include, state.Exclude)
+}
+
+// inTestShard returns true if the given target is in the test shard we're running.
+// Non-test targets are always in it. Shards are assigned by hashing the label, so they're stable
+// regardless of which other targets are being tested.
+func (state *BuildState) inTestShard(target *BuildTarget) bool {
+	if state.TestShardCount <= 1 || !target.IsTest() {
+		return true
+	}
+	return int(crc32.ChecksumIEEE([]byte(target.Label.String()))%uint32(state.TestShardCount)) == state.TestShardIndex
 }
 
 // AddOriginalTarget adds one of the original targets and enqueues it for parsing / building.
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"sort"
 	"strings"
 	"testing"
 
@@ -48,6 +49,36 @@ func TestExpandOriginalTestLabels(t *testing.T) {
 	assert.Equal(t, state.ExpandOriginalLabels(), BuildLabels{{PackageName: "src/core", Name: "target1_test"}})
 }
 
+func TestExpandOriginalTestLabelsSharded(t *testing.T) {
+	const shards = 3
+	all := BuildLabels{}
+	for i := 0; i < shards; i++ {
+		state := NewDefaultBuildState()
+		state.AddOriginalTarget(BuildLabel{PackageName: "src/core", Name: "all"}, true)
+		state.NeedTests = true
+		state.TestShardCount = shards
+		state.TestShardIndex = i
+		for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			addTarget(state, "//src/core:"+name+"_test")
+		}
+		labels := state.ExpandOriginalLabels()
+		assert.Less(t, len(labels), 8, "each shard should only have some of the tests")
+		all = append(all, labels...)
+	}
+	// Every test should be in exactly one shard.
+	sort.Sort(all)
+	assert.Equal(t, BuildLabels{
+		{PackageName: "src/core", Name: "a_test"},
+		{PackageName: "src/core", Name: "b_test"},
+		{PackageName: "src/core", Name: "c_test"},
+		{PackageName: "src/core", Name: "d_test"},
+		{PackageName: "src/core", Name: "e_test"},
+		{PackageName: "src/core", Name: "f_test"},
+		{PackageName: "src/core", Name: "g_test"},
+		{PackageName: "src/core", Name: "h_test"},
+	}, all)
+}
+
 func TestExpandVisibleOriginalTargets(t *testing.T) {
 	state := NewDefaultBuildState()
 	state.AddOriginalTarget(BuildLabel{PackageName: "src/core", Name: "all"}, true)
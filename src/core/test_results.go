@@ -262,6 +262,9 @@ var lineCoverageOutput = [...]rune{'N', 'X', 'U', 'C'} // Corresponds to orderin
 type TestCoverage struct {
 	Tests map[BuildLabel]map[string][]LineCoverage
 	Files map[string][]LineCoverage
+	// BranchCoverage records finer-grained hit counts than Files, in source order.
+	// Currently this is only recorded for Go, where they're the counts of each basic block.
+	BranchCoverage map[string][]int
 }
 
 // Aggregate aggregates results from another coverage object into this one.
@@ -282,6 +285,22 @@ func (coverage *TestCoverage) Aggregate(cov *TestCoverage) {
 	for filename, c := range cov.Files {
 		coverage.Files[filename] = MergeCoverageLines(coverage.Files[filename], c)
 	}
+	if len(cov.BranchCoverage) > 0 && coverage.BranchCoverage == nil {
+		coverage.BranchCoverage = map[string][]int{}
+	}
+	for filename, c := range cov.BranchCoverage {
+		coverage.BranchCoverage[filename] = mergeBranchCoverage(coverage.BranchCoverage[filename], c)
+	}
+}
+
+// mergeBranchCoverage merges two sets of branch hit counts together by summing them.
+func mergeBranchCoverage(existing, coverage []int) []int {
+	ret := make([]int, max(len(existing), len(coverage)))
+	copy(ret, existing)
+	for i, count := range coverage {
+		ret[i] += count
+	}
+	return ret
 }
 
 // MergeCoverageLines merges two sets of coverage results together, taking
@@ -316,8 +335,9 @@ func (coverage *TestCoverage) OrderedFiles() []string {
 // NewTestCoverage constructs and returns a new TestCoverage instance.
 func NewTestCoverage() *TestCoverage {
 	return &TestCoverage{
-		Tests: map[BuildLabel]map[string][]LineCoverage{},
-		Files: map[string][]LineCoverage{},
+		Tests:          map[BuildLabel]map[string][]LineCoverage{},
+		Files:          map[string][]LineCoverage{},
+		BranchCoverage: map[string][]int{},
 	}
 }
 
@@ -57,6 +57,13 @@ func TestMergeCoverageLines8(t *testing.T) {
 	assert.Equal(t, empty, coverage)
 }
 
+func TestAggregateBranchCoverage(t *testing.T) {
+	coverage := TestCoverage{}
+	coverage.Aggregate(&TestCoverage{BranchCoverage: map[string][]int{"a.go": {1, 0}}})
+	coverage.Aggregate(&TestCoverage{BranchCoverage: map[string][]int{"a.go": {1, 2, 1}, "b.go": {0}}})
+	assert.Equal(t, map[string][]int{"a.go": {2, 2, 1}, "b.go": {0}}, coverage.BranchCoverage)
+}
+
 func TestAdd(t *testing.T) {
 	duration10 := time.Duration(10)
 	duration20 := time.Duration(20)
@@ -1,6 +1,8 @@
 package debug
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -34,5 +36,9 @@ func Debug(state *core.BuildState, label core.BuildLabel, args, env []string, sh
 	shareNetwork = shareNetwork || state.DebugPort != 0 || !targetSandbox
 	shareMount = shareMount || !targetSandbox
 
+	if state.DebugPort != 0 {
+		// The debugger runs headless in this case, so let the user know where to point their IDE.
+		fmt.Fprintf(os.Stderr, "Debugging %s, attach your debugger to localhost:%d\n", target.Label, state.DebugPort)
+	}
 	return exec.Exec(state, core.AnnotatedOutputLabel{BuildLabel: label}, dir, env, cmd, nil, state.DebugPort == 0, process.NewSandboxConfig(!shareNetwork, !shareMount))
 }
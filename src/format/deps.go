@@ -0,0 +1,197 @@
+package format
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+// unusedDepsRules are the rules that we know how to analyse for unused dependencies.
+var unusedDepsRules = map[string]bool{
+	"go_library": true,
+	"go_binary":  true,
+}
+
+// goLibraryRules are the rules whose targets can be imported by Go code, and hence be unused deps.
+var goLibraryRules = map[string]bool{
+	"go_library": true,
+	"go_module":  true,
+}
+
+// RemoveUnusedDeps removes dependencies from go_library and go_binary targets in the given BUILD files
+// that aren't imported by any of the target's sources.
+// This is a heuristic; it only considers dependencies on go_library and go_module targets within this
+// repo, and leaves anything it can't be sure about (e.g. targets with generated sources) alone.
+// Entries with a trailing '# keep' comment are never removed.
+// The files aren't written; their new contents are added to the given edits, for Format to write.
+// If dryRun is true the deps that would be removed are printed instead.
+// The returned bool is true if any deps were (or would be) removed.
+func RemoveUnusedDeps(state *core.BuildState, filenames []string, dryRun bool, edits Edits) (bool, error) {
+	changed := false
+	for _, filename := range filenames {
+		c, err := removeUnusedDeps(state, filename, dryRun, edits)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || c
+	}
+	return changed, nil
+}
+
+func removeUnusedDeps(state *core.BuildState, filename string, dryRun bool, edits Edits) (bool, error) {
+	f, err := parseBuildFile(filename, edits)
+	if err != nil {
+		return false, err
+	}
+	pkgName := packageName(filename)
+	changed := false
+	for _, rule := range f.Rules("") {
+		if !unusedDepsRules[rule.Kind()] {
+			continue
+		}
+		deps, ok := rule.Attr("deps").(*build.ListExpr)
+		if !ok {
+			continue
+		}
+		imports, ok := goImports(pkgName, rule.Attr("srcs"))
+		if !ok {
+			log.Debug("Can't determine imports of %s:%s, not checking its deps", pkgName, rule.Name())
+			continue
+		}
+		kept := make([]build.Expr, 0, len(deps.List))
+		for _, dep := range deps.List {
+			if isUnusedDep(state, pkgName, dep, imports, edits) {
+				changed = true
+				if dryRun {
+					fmt.Printf("%s: would remove %s from //%s:%s\n", filename, dep.(*build.StringExpr).Value, pkgName, rule.Name())
+					kept = append(kept, dep)
+				} else {
+					log.Notice("Removing unused dependency %s from //%s:%s", dep.(*build.StringExpr).Value, pkgName, rule.Name())
+				}
+			} else {
+				kept = append(kept, dep)
+			}
+		}
+		deps.List = kept
+	}
+	if changed && !dryRun {
+		edits[filename] = build.Format(f)
+	}
+	return changed, nil
+}
+
+// packageName returns the package name for a BUILD file.
+func packageName(filename string) string {
+	dir := filepath.Dir(filename)
+	if filepath.IsAbs(dir) {
+		if rel, err := filepath.Rel(core.RepoRoot, dir); err == nil {
+			dir = rel
+		}
+	}
+	if dir == "." {
+		return ""
+	}
+	return filepath.ToSlash(dir)
+}
+
+// goImports returns the set of import paths of the given srcs expression.
+// The second return value is false if they can't be determined (e.g. because some of the sources are
+// generated by other rules, or aren't a simple list).
+func goImports(pkgName string, srcs build.Expr) (map[string]bool, bool) {
+	list, ok := srcs.(*build.ListExpr)
+	if !ok {
+		return nil, false
+	}
+	imports := map[string]bool{}
+	fset := token.NewFileSet()
+	for _, src := range list.List {
+		str, ok := src.(*build.StringExpr)
+		if !ok || core.LooksLikeABuildLabel(str.Value) {
+			return nil, false
+		} else if !strings.HasSuffix(str.Value, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(pkgName, str.Value), nil, parser.ImportsOnly)
+		if err != nil {
+			return nil, false
+		}
+		for _, imp := range f.Imports {
+			if path, err := strconv.Unquote(imp.Path.Value); err == nil {
+				imports[path] = true
+			}
+		}
+	}
+	return imports, true
+}
+
+// isUnusedDep returns true if the given dependency is a Go library within this repo that isn't imported.
+func isUnusedDep(state *core.BuildState, pkgName string, dep build.Expr, imports map[string]bool, edits Edits) bool {
+	str, ok := dep.(*build.StringExpr)
+	if !ok || hasKeepComment(dep) {
+		return false
+	}
+	label, err := core.TryParseBuildLabel(str.Value, pkgName, "")
+	if err != nil || label.Subrepo != "" {
+		return false
+	}
+	target := state.Graph.Target(label)
+	if target == nil || target.Subrepo != nil || !goLibraryRules[target.RuleName] {
+		return false
+	}
+	if target.RuleName == "go_module" {
+		// Anything under the module's path could come from it; if we don't know that, we can't tell.
+		module := ruleAttr(state, label, "module", edits)
+		if module == "" {
+			return false
+		}
+		for imp := range imports {
+			if imp == module || strings.HasPrefix(imp, module+"/") {
+				return false
+			}
+		}
+		return true
+	} else if importPath := ruleAttr(state, label, "import_path", edits); importPath != "" {
+		return !imports[importPath]
+	}
+	importSuffix := label.PackageName
+	if label.Name != filepath.Base(label.PackageName) {
+		importSuffix = filepath.Join(importSuffix, label.Name)
+	}
+	for imp := range imports {
+		if imp == importSuffix || strings.HasSuffix(imp, "/"+importSuffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleAttr returns the value of a string argument to the rule that defines the given target,
+// or the empty string if it's not set (or can't be found).
+func ruleAttr(state *core.BuildState, label core.BuildLabel, attr string, edits Edits) string {
+	pkg := state.Graph.PackageByLabel(label)
+	if pkg == nil {
+		return ""
+	}
+	f, err := parseBuildFile(pkg.Filename, edits)
+	if err != nil {
+		return ""
+	}
+	for _, rule := range f.Rules("") {
+		if rule.Name() == label.Name {
+			return rule.AttrString(attr)
+		}
+	}
+	return ""
+}
+
+// hasKeepComment returns true if the given expression has a trailing '# keep' comment.
+func hasKeepComment(expr build.Expr) bool {
+	return hasComment(expr, "keep")
+}
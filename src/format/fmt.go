@@ -21,13 +21,29 @@ import (
 
 var log = logging.Log
 
+// Edits are new contents of BUILD files that have been changed in memory but not yet written, keyed by
+// filename. Format treats them as changes in the same way as any reformatting.
+type Edits map[string][]byte
+
+// read returns the contents of the given file, with any edits applied.
+func (edits Edits) read(filename string) ([]byte, error) {
+	if contents, present := edits[filename]; present {
+		return contents, nil
+	}
+	return os.ReadFile(filename)
+}
+
 // Format reformats the given BUILD files to their canonical version.
 // It either prints the reformatted versions to stdout or rewrites the files in-place.
 // If no files are given then all BUILD files under the repo root are discovered.
+// Any edits given replace the contents of those files, and any rule migrations given are applied,
+// before the files are formatted.
+// List entries with a trailing '# keep' comment are left where they are, unless stripKeepComments
+// is true in which case those comments are removed (and the entries formatted as normal).
 // The returned bool is true if any changes were needed.
-func Format(config *core.Configuration, filenames []string, rewrite, quiet bool) (bool, error) {
+func Format(config *core.Configuration, filenames []string, rewrite, quiet bool, migrations []RuleMigration, stripKeepComments bool, edits Edits) (bool, error) {
 	if len(filenames) == 0 {
-		return formatAll(plz.FindAllBuildFiles(config, core.RepoRoot, ""), config.Please.NumThreads, rewrite, quiet)
+		return formatAll(plz.FindAllBuildFiles(config, core.RepoRoot, ""), config.Please.NumThreads, rewrite, quiet, migrations, stripKeepComments, edits)
 	}
 	ch := make(chan string)
 	go func() {
@@ -36,17 +52,17 @@ func Format(config *core.Configuration, filenames []string, rewrite, quiet bool)
 		}
 		close(ch)
 	}()
-	return formatAll(ch, config.Please.NumThreads, rewrite, quiet)
+	return formatAll(ch, config.Please.NumThreads, rewrite, quiet, migrations, stripKeepComments, edits)
 }
 
-func formatAll(filenames <-chan string, parallelism int, rewrite, quiet bool) (bool, error) {
+func formatAll(filenames <-chan string, parallelism int, rewrite, quiet bool, migrations []RuleMigration, stripKeep bool, edits Edits) (bool, error) {
 	var changed int64
 	var g errgroup.Group
 	g.SetLimit(parallelism)
 	for filename := range filenames {
 		filename := filename
 		g.Go(func() error {
-			c, err := format(filename, rewrite, quiet)
+			c, err := format(filename, rewrite, quiet, migrations, stripKeep, edits)
 			if c {
 				atomic.AddInt64(&changed, 1)
 			}
@@ -57,17 +73,29 @@ func formatAll(filenames <-chan string, parallelism int, rewrite, quiet bool) (b
 	return changed > 0, err
 }
 
-func format(filename string, rewrite, quiet bool) (bool, error) {
+func format(filename string, rewrite, quiet bool, migrations []RuleMigration, stripKeep bool, edits Edits) (bool, error) {
 	before, err := os.ReadFile(filename)
 	if err != nil {
 		return true, err
 	}
-	f, err := build.ParseBuild(filename, before)
+	contents, err := edits.read(filename)
 	if err != nil {
 		return true, err
 	}
+	f, err := build.ParseBuild(filename, contents)
+	if err != nil {
+		return true, err
+	}
+	migrate(f, migrations)
 	simplify(f)
-	after := build.Format(f)
+	if stripKeep {
+		stripKeepComments(f)
+	}
+	kept := removeKeptEntries(f)
+	build.Rewrite(f)
+	sortLabelsFirst(f)
+	restoreKeptEntries(kept)
+	after := build.FormatWithoutRewriting(f)
 	if bytes.Equal(before, after) {
 		log.Debug("%s is already in canonical format", filename)
 		return false, nil
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/please-build/buildtools/build"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -23,12 +24,12 @@ func TestFormat(t *testing.T) {
 				before := filepath.Join(testDir, test+".before.build")
 				after := filepath.Join(testDir, test+".after.build")
 
-				changed, err := Format(core.DefaultConfiguration(), []string{before}, false, true)
+				changed, err := Format(core.DefaultConfiguration(), []string{before}, false, true, nil, false, nil)
 				assert.NoError(t, err)
 				assert.True(t, changed)
 
 				// N.B. this rewrites the file; be careful if you're adding more tests here.
-				changed, err = Format(core.DefaultConfiguration(), []string{before}, true, false)
+				changed, err = Format(core.DefaultConfiguration(), []string{before}, true, false, nil, false, nil)
 				assert.NoError(t, err)
 				assert.True(t, changed)
 
@@ -41,3 +42,161 @@ func TestFormat(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoveUnusedDeps(t *testing.T) {
+	const dir = "src/format/test_data/unused_deps"
+	filename := filepath.Join(dir, "deps.build")
+	state := core.NewDefaultBuildState()
+	pkg := core.NewPackage(dir)
+	pkg.Filename = filename
+	state.Graph.AddPackage(pkg)
+	for label, rule := range map[string]string{
+		"//" + dir + ":custom":     "go_library",
+		"//" + dir + ":gen":        "genrule",
+		"//" + dir + ":unused":     "go_library",
+		"//" + dir + "/kept:kept":  "go_library",
+		"//" + dir + "/used:used":  "go_library",
+		"//third_party/go:unknown": "go_module",
+	} {
+		target := core.NewBuildTarget(core.ParseBuildLabel(label, ""))
+		target.RuleName = rule
+		state.Graph.AddTarget(target)
+	}
+	contents, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	edits := Edits{}
+	changed, err := RemoveUnusedDeps(state, []string{filename}, true, edits)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Empty(t, edits, "dry run should not modify the file")
+
+	changed, err = RemoveUnusedDeps(state, []string{filename}, false, edits)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	after, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, string(contents), string(after), "the file should not be modified")
+	after = edits[filename]
+	assert.NotContains(t, string(after), `":unused"`)
+	assert.Contains(t, string(after), `":custom"`, "should be kept since its import_path is imported")
+	assert.Contains(t, string(after), `":gen"`, "should be kept since it's not a Go library")
+	assert.Contains(t, string(after), `"//src/format/test_data/unused_deps/kept",  # keep`)
+	assert.Contains(t, string(after), `"//src/format/test_data/unused_deps/used"`)
+	assert.Contains(t, string(after), `"//third_party/go:unknown"`)
+}
+
+func TestExpandWildcardDeps(t *testing.T) {
+	const dir = "src/format/test_data/imports"
+	state := core.NewDefaultBuildState()
+	for _, label := range []string{"//" + dir + "/lib:a", "//" + dir + "/lib:b", "//" + dir + "/lib:_a#hidden", "//" + dir + "/lib:private", "//" + dir + "/lib/sub:c"} {
+		target := core.NewBuildTarget(core.ParseBuildLabel(label, ""))
+		if target.Label.Name != "private" {
+			target.Visibility = core.WholeGraph
+		}
+		state.Graph.AddTarget(target)
+	}
+	filename := filepath.Join(dir, "wildcard.build")
+	contents, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	labels, err := WildcardDeps(core.DefaultConfiguration(), []string{filename}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []core.BuildLabel{{PackageName: dir + "/lib", Name: "all"}}, labels)
+
+	edits := Edits{}
+	changed, err := ExpandWildcardDeps(state, []string{filename}, edits)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	after, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, string(contents), string(after), "the file should not be modified")
+	after = edits[filename]
+	assert.Equal(t, `go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    deps = [
+        "//src/format/test_data/imports/lib:a",
+        "//src/format/test_data/imports/lib:b",
+        "//src/format/test_data/imports/other/...",  # no-imports
+        "//third_party/go:errors",
+    ],
+)
+`, string(after))
+
+	// Linting should report the edit as a change, without writing it.
+	changed, err = Format(core.DefaultConfiguration(), []string{filename}, false, true, nil, false, edits)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	after, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, string(contents), string(after))
+}
+
+func TestMigrateRules(t *testing.T) {
+	f, err := build.ParseBuild("BUILD", []byte(`python_binary(
+    name = "bin",
+    main = "main.py",
+    deps = [":lib"],
+)
+
+genrule(
+    name = "gen",
+    cmd = "echo python_binary > $OUT",
+    outs = ["out.txt"],
+)
+`))
+	require.NoError(t, err)
+	migrate(f, []RuleMigration{{From: "python_binary", To: "python3_binary", Args: map[string]string{"main": "src"}}})
+	assert.Equal(t, `python3_binary(
+    name = "bin",
+    src = "main.py",
+    deps = [":lib"],
+)
+
+genrule(
+    name = "gen",
+    outs = ["out.txt"],
+    cmd = "echo python_binary > $OUT",
+)
+`, string(build.Format(f)))
+}
+
+func TestParseRuleMigrations(t *testing.T) {
+	mapFile := filepath.Join(t.TempDir(), "map.json")
+	require.NoError(t, os.WriteFile(mapFile, []byte(`{"python_binary": {"main": "src"}}`), 0644))
+	migrations, err := ParseRuleMigrations([]string{"python_binary=python3_binary", "go_bin=go_binary"}, mapFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []RuleMigration{
+		{From: "python_binary", To: "python3_binary", Args: map[string]string{"main": "src"}},
+		{From: "go_bin", To: "go_binary"},
+	}, migrations)
+
+	_, err = ParseRuleMigrations([]string{"python_binary"}, "")
+	assert.Error(t, err)
+	_, err = ParseRuleMigrations([]string{"go_bin=go_binary"}, mapFile)
+	assert.Error(t, err)
+}
+
+func TestStripKeepComments(t *testing.T) {
+	f, err := build.ParseBuild("BUILD", []byte(`go_library(
+    name = "lib",
+    deps = [
+        "//src/core",
+        "//third_party/go:zzz",  # keep
+        "//src/cli",  # needed for init
+    ],
+)
+`))
+	require.NoError(t, err)
+	stripKeepComments(f)
+	assert.Equal(t, `go_library(
+    name = "lib",
+    deps = [
+        "//src/cli",  # needed for init
+        "//src/core",
+        "//third_party/go:zzz",
+    ],
+)
+`, string(build.Format(f)))
+}
@@ -0,0 +1,178 @@
+package format
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/plz"
+)
+
+// WildcardDeps returns the packages matched by wildcard labels (e.g. //pkg/... or //pkg:all) used in
+// the deps of rules in the given BUILD files, which need to be parsed before ExpandWildcardDeps is called.
+// The packages of those BUILD files are never returned; wildcards aren't valid deps, so they can't be
+// parsed until they've been expanded.
+func WildcardDeps(config *core.Configuration, filenames []string, edits Edits) ([]core.BuildLabel, error) {
+	var wildcards []core.BuildLabel
+	skip := map[string]bool{}
+	for _, filename := range filenames {
+		f, err := parseBuildFile(filename, edits)
+		if err != nil {
+			return nil, err
+		}
+		pkgName := packageName(filename)
+		for _, rule := range f.Rules("") {
+			forEachWildcardDep(pkgName, rule, func(_ *build.StringExpr, label core.BuildLabel) {
+				wildcards = append(wildcards, label)
+				skip[pkgName] = true
+			})
+		}
+	}
+	pkgs := map[string]bool{}
+	for _, label := range wildcards {
+		if !label.IsAllSubpackages() {
+			pkgs[label.PackageName] = true
+			continue
+		}
+		dir := label.PackageName
+		if dir == "" {
+			dir = "."
+		}
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		for file := range plz.FindAllBuildFiles(config, dir, "") {
+			pkgs[packageName(file)] = true
+		}
+	}
+	labels := make([]core.BuildLabel, 0, len(pkgs))
+	for pkg := range pkgs {
+		if !skip[pkg] {
+			labels = append(labels, core.BuildLabel{PackageName: pkg, Name: "all"})
+		}
+	}
+	slices.SortFunc(labels, core.BuildLabel.Compare)
+	return labels, nil
+}
+
+// ExpandWildcardDeps replaces wildcard labels in the deps of rules in the given BUILD files with
+// the targets that they match (only direct matches; nothing transitive). The expanded labels are
+// sorted, which groups them by package. Hidden targets, those in the rule's own package and those not
+// visible to the rule are omitted. Entries with a trailing '# no-imports' comment are left alone.
+// The files aren't written; their new contents are added to the given edits, for Format to write.
+// The returned bool is true if any files were changed.
+func ExpandWildcardDeps(state *core.BuildState, filenames []string, edits Edits) (bool, error) {
+	changed := false
+	for _, filename := range filenames {
+		c, err := expandWildcardDeps(state, filename, edits)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || c
+	}
+	return changed, nil
+}
+
+func expandWildcardDeps(state *core.BuildState, filename string, edits Edits) (bool, error) {
+	f, err := parseBuildFile(filename, edits)
+	if err != nil {
+		return false, err
+	}
+	pkgName := packageName(filename)
+	pkgLabel := core.BuildLabel{PackageName: pkgName, Name: "all"}
+	targets := state.Graph.AllTargets()
+	changed := false
+	for _, rule := range f.Rules("") {
+		expansions := map[*build.StringExpr][]build.Expr{}
+		ruleLabel := core.BuildLabel{PackageName: pkgName, Name: rule.Name()}
+		forEachWildcardDep(pkgName, rule, func(str *build.StringExpr, label core.BuildLabel) {
+			var matches core.BuildLabels
+			for _, target := range targets {
+				if label.Includes(target.Label) && !target.Label.IsHidden() && target.Label.PackageName != pkgName && ruleLabel.CanSee(state, target) {
+					matches = append(matches, target.Label)
+				}
+			}
+			slices.SortFunc(matches, core.BuildLabel.Compare)
+			exprs := make([]build.Expr, len(matches))
+			for i, match := range matches {
+				exprs[i] = &build.StringExpr{Value: match.ShortString(pkgLabel)}
+			}
+			log.Notice("Expanding %s in //%s:%s to %d targets", str.Value, pkgName, rule.Name(), len(exprs))
+			expansions[str] = exprs
+		})
+		if len(expansions) == 0 {
+			continue
+		}
+		changed = true
+		deps := rule.Attr("deps").(*build.ListExpr)
+		existing := map[string]bool{}
+		for _, dep := range deps.List {
+			if str, ok := dep.(*build.StringExpr); ok {
+				existing[str.Value] = true
+			}
+		}
+		list := make([]build.Expr, 0, len(deps.List))
+		for _, dep := range deps.List {
+			str, ok := dep.(*build.StringExpr)
+			if exprs, present := expansions[str]; ok && present {
+				for _, expr := range exprs {
+					if s := expr.(*build.StringExpr).Value; !existing[s] {
+						existing[s] = true
+						list = append(list, expr)
+					}
+				}
+			} else {
+				list = append(list, dep)
+			}
+		}
+		deps.List = list
+	}
+	if changed {
+		edits[filename] = build.Format(f)
+	}
+	return changed, nil
+}
+
+// forEachWildcardDep calls the given function for each wildcard label in the deps of a rule.
+func forEachWildcardDep(pkgName string, rule *build.Rule, f func(*build.StringExpr, core.BuildLabel)) {
+	deps, ok := rule.Attr("deps").(*build.ListExpr)
+	if !ok {
+		return
+	}
+	for _, dep := range deps.List {
+		str, ok := dep.(*build.StringExpr)
+		if !ok || hasComment(dep, "no-imports") {
+			continue
+		}
+		// :all in the rule's own package can't be expanded since we can't parse that package.
+		if label, err := core.TryParseBuildLabel(str.Value, pkgName, ""); err == nil && label.IsPseudoTarget() && label.Subrepo == "" && (label.IsAllSubpackages() || label.PackageName != pkgName) {
+			f(str, label)
+		}
+	}
+}
+
+func parseBuildFile(filename string, edits Edits) (*build.File, error) {
+	b, err := edits.read(filename)
+	if err != nil {
+		return nil, err
+	}
+	return build.ParseBuild(filename, b)
+}
+
+// hasComment returns true if the given expression has a trailing comment with the given text.
+func hasComment(expr build.Expr, text string) bool {
+	for _, comment := range expr.Comment().Suffix {
+		if isComment(comment, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// isComment returns true if the given comment consists of the given text.
+func isComment(comment build.Comment, text string) bool {
+	return strings.TrimSpace(strings.TrimPrefix(comment.Token, "#")) == text
+}
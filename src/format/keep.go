@@ -0,0 +1,55 @@
+package format
+
+import (
+	"slices"
+
+	"github.com/please-build/buildtools/build"
+)
+
+// A keptEntry is a list entry with a '# keep' comment, and its original index in the list.
+type keptEntry struct {
+	index int
+	expr  build.Expr
+}
+
+// removeKeptEntries removes all list entries with a trailing '# keep' comment from the given file,
+// so they aren't moved or deduplicated when it's rewritten. restoreKeptEntries puts them back afterwards.
+func removeKeptEntries(f *build.File) map[*build.ListExpr][]keptEntry {
+	kept := map[*build.ListExpr][]keptEntry{}
+	build.Walk(f, func(expr build.Expr, stack []build.Expr) {
+		list, ok := expr.(*build.ListExpr)
+		if !ok {
+			return
+		}
+		for i, elem := range list.List {
+			if hasKeepComment(elem) {
+				kept[list] = append(kept[list], keptEntry{index: i, expr: elem})
+			}
+		}
+		if entries := kept[list]; len(entries) > 0 {
+			list.List = slices.DeleteFunc(list.List, hasKeepComment)
+		}
+	})
+	return kept
+}
+
+// restoreKeptEntries reinserts entries removed by removeKeptEntries at their original positions.
+func restoreKeptEntries(kept map[*build.ListExpr][]keptEntry) {
+	for list, entries := range kept {
+		for _, entry := range entries {
+			list.List = slices.Insert(list.List, min(entry.index, len(list.List)), entry.expr)
+		}
+	}
+}
+
+// stripKeepComments removes all '# keep' comments from the given file.
+func stripKeepComments(f *build.File) {
+	build.Walk(f, func(expr build.Expr, stack []build.Expr) {
+		if hasKeepComment(expr) {
+			comments := expr.Comment()
+			comments.Suffix = slices.DeleteFunc(comments.Suffix, func(c build.Comment) bool {
+				return isComment(c, "keep")
+			})
+		}
+	})
+}
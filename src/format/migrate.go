@@ -0,0 +1,82 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+)
+
+// A RuleMigration renames calls to one rule to another, optionally renaming some of its arguments as well.
+type RuleMigration struct {
+	From, To string
+	// Args maps old argument names to new ones.
+	Args map[string]string
+}
+
+// ParseRuleMigrations parses a set of migrations given as from=to pairs.
+// If mapFile is given, it's a JSON file mapping the names of rules being migrated from to
+// a mapping of old to new argument names, for example {"python_binary": {"main": "src"}}.
+func ParseRuleMigrations(rules []string, mapFile string) ([]RuleMigration, error) {
+	argMaps := map[string]map[string]string{}
+	if mapFile != "" {
+		b, err := os.ReadFile(mapFile)
+		if err != nil {
+			return nil, err
+		} else if err := json.Unmarshal(b, &argMaps); err != nil {
+			return nil, fmt.Errorf("Invalid rule migration map %s: %w", mapFile, err)
+		}
+	}
+	migrations := make([]RuleMigration, len(rules))
+	for i, rule := range rules {
+		from, to, found := strings.Cut(rule, "=")
+		if !found || from == "" || to == "" {
+			return nil, fmt.Errorf("Invalid rule migration %s, must be in the form from=to", rule)
+		}
+		migrations[i] = RuleMigration{From: from, To: to, Args: argMaps[from]}
+		delete(argMaps, from)
+	}
+	for from := range argMaps {
+		return nil, fmt.Errorf("Rule migration map contains %s, which isn't being migrated", from)
+	}
+	return migrations, nil
+}
+
+// migrate applies the given rule migrations to a file.
+// Only the function name and argument names of calls are changed, so strings that happen to
+// contain the rule's name are left alone.
+func migrate(f *build.File, migrations []RuleMigration) {
+	if len(migrations) == 0 {
+		return
+	}
+	byName := make(map[string]RuleMigration, len(migrations))
+	for _, m := range migrations {
+		byName[m.From] = m
+	}
+	build.Walk(f, func(expr build.Expr, stack []build.Expr) {
+		call, ok := expr.(*build.CallExpr)
+		if !ok {
+			return
+		}
+		ident, ok := call.X.(*build.Ident)
+		if !ok {
+			return
+		}
+		m, present := byName[ident.Name]
+		if !present {
+			return
+		}
+		ident.Name = m.To
+		for _, arg := range call.List {
+			if assign, ok := arg.(*build.AssignExpr); ok {
+				if name, ok := assign.LHS.(*build.Ident); ok {
+					if to, present := m.Args[name.Name]; present {
+						name.Name = to
+					}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package format
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/please-build/buildtools/tables"
+)
+
+// sortLabelsFirst reorders the string lists that buildifier has just sorted so that build labels
+// come before plain filenames, with absolute (//) labels before relative (:) ones.
+// Buildifier's own ordering is the other way round; it's kept within each of those groups.
+// Lists that buildifier leaves alone (e.g. ones with a comment on its own line) are left alone here too.
+func sortLabelsFirst(f *build.File) {
+	build.Walk(f, func(expr build.Expr, stack []build.Expr) {
+		call, ok := expr.(*build.CallExpr)
+		if !ok || hasBeforeComment(call, "buildifier: leave-alone") {
+			return
+		}
+		rule := ""
+		if ident, ok := call.X.(*build.Ident); ok {
+			rule = ident.Name
+		}
+		for _, arg := range call.List {
+			assign, ok := arg.(*build.AssignExpr)
+			if !ok || hasBeforeComment(assign, "buildifier: leave-alone") || hasBeforeComment(assign, "do not sort") {
+				continue
+			}
+			key, ok := assign.LHS.(*build.Ident)
+			if !ok || !tables.IsSortableListArg[key.Name] || tables.SortableDenylist[rule+"."+key.Name] {
+				continue
+			}
+			if list, ok := assign.RHS.(*build.ListExpr); ok && !hasLineComments(list) {
+				sortList(list)
+			}
+		}
+	})
+}
+
+// sortList stably sorts each run of consecutive strings in the given list by labelOrder.
+func sortList(list *build.ListExpr) {
+	for i := 0; i < len(list.List); {
+		j := i
+		for j < len(list.List) {
+			if _, ok := list.List[j].(*build.StringExpr); !ok {
+				break
+			}
+			j++
+		}
+		slices.SortStableFunc(list.List[i:j], func(a, b build.Expr) int {
+			return labelOrder(a.(*build.StringExpr).Value) - labelOrder(b.(*build.StringExpr).Value)
+		})
+		i = j + 1
+	}
+}
+
+// labelOrder returns the group a list entry sorts into: absolute labels, then relative ones, then everything else.
+func labelOrder(s string) int {
+	if strings.HasPrefix(s, "//") || strings.HasPrefix(s, "@") {
+		return 0
+	} else if strings.HasPrefix(s, ":") {
+		return 1
+	}
+	return 2
+}
+
+// hasLineComments returns true if the given list or any of its entries has a comment on its own line.
+func hasLineComments(list *build.ListExpr) bool {
+	if len(list.Comment().Before) > 0 || len(list.Comment().After) > 0 || len(list.End.Before) > 0 {
+		return true
+	}
+	for _, elem := range list.List {
+		if len(elem.Comment().Before) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBeforeComment returns true if the given expression is preceded by a comment containing the given text.
+func hasBeforeComment(expr build.Expr, text string) bool {
+	for _, comment := range expr.Comment().Before {
+		if strings.Contains(strings.ToLower(comment.Token), text) {
+			return true
+		}
+	}
+	return false
+}
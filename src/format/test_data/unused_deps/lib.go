@@ -0,0 +1,3 @@
+package unused_deps
+
+import _ "github.com/thought-machine/please/src/format/test_data/unused_deps/used"
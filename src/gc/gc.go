@@ -12,6 +12,8 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/dustin/go-humanize"
+
 	"github.com/thought-machine/please/src/cli"
 	"github.com/thought-machine/please/src/cli/logging"
 	"github.com/thought-machine/please/src/core"
@@ -38,7 +40,15 @@ func GarbageCollect(state *core.BuildState, filter, targets, keepTargets []core.
 				fmt.Printf("  %s\n", src)
 			}
 		}
+		var size uint64
+		files := 0
+		if !targetsOnly {
+			size, files = totalSize(srcs)
+		}
 		if dryRun {
+			if files > 0 {
+				fmt.Fprintf(os.Stderr, "Would free %s from %d files\n", humanize.Bytes(size), files)
+			}
 			return
 		} else if !noPrompt && !cli.PromptYN("Remove these targets / files?", false) {
 			os.Exit(1)
@@ -64,6 +74,9 @@ func GarbageCollect(state *core.BuildState, filter, targets, keepTargets []core.
 			}
 		}
 		fmt.Fprintf(os.Stderr, "Garbage collected!\n")
+		if files > 0 {
+			fmt.Fprintf(os.Stderr, "Freed %s from %d files\n", humanize.Bytes(size), files)
+		}
 	} else {
 		fmt.Fprintf(os.Stderr, "Nothing to remove\n")
 	}
@@ -73,7 +86,8 @@ func GarbageCollect(state *core.BuildState, filter, targets, keepTargets []core.
 func targetsToRemove(graph *core.BuildGraph, filter, targets, targetsToKeep []core.BuildLabel, keepLabels []string, includeTests bool) (core.BuildLabels, []string) {
 	keepTargets := targetMap{}
 	for _, target := range graph.AllTargets() {
-		if (target.IsBinary && (!target.IsTest() || includeTests)) || target.HasAnyLabel(keepLabels) || anyInclude(targetsToKeep, target.Label) || target.Label.Subrepo != "" {
+		// Targets outside the filter won't be removed, so anything they depend on must stay too.
+		if (target.IsBinary && (!target.IsTest() || includeTests)) || target.HasAnyLabel(keepLabels) || anyInclude(targetsToKeep, target.Label) || target.Label.Subrepo != "" || !isIncluded(target, filter) {
 			log.Debug("GC root: %s", target.Label)
 			addTarget(graph, keepTargets, target)
 		}
@@ -249,6 +263,19 @@ func RewriteFile(state *core.BuildState, filename string, targets []string) erro
 	return os.WriteFile(filename, bytes.Join(lines2, []byte{'\n'}), 0664)
 }
 
+// totalSize returns the total size of the given files and how many of them exist.
+func totalSize(filenames []string) (uint64, int) {
+	var size uint64
+	files := 0
+	for _, filename := range filenames {
+		if info, err := os.Lstat(filename); err == nil {
+			size += uint64(info.Size())
+			files++
+		}
+	}
+	return size, files
+}
+
 // removeTargets rewrites the given set of targets out of their BUILD files.
 func removeTargets(state *core.BuildState, labels core.BuildLabels) error {
 	byPackage := map[*core.Package][]string{}
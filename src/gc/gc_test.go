@@ -42,6 +42,30 @@ func TestTargetsToRemoveFiltered(t *testing.T) {
 	}, labels)
 }
 
+func TestTargetsToRemoveFilteredKeepsDependenciesOfOtherTargets(t *testing.T) {
+	graph := createGraph()
+	createTarget(graph, "//src/query:query", "//src/cli:cli")
+	labels, _ := targetsToRemove(graph, nil, nil, nil, nil, false)
+	assert.EqualValues(t, []core.BuildLabel{
+		bl("//src/cli:cli"),
+		bl("//src/parse:parse"),
+		bl("//src/query:query"),
+	}, labels)
+	// //src/query:query isn't being collected, so it still needs //src/cli:cli.
+	labels, _ = targetsToRemove(graph, []core.BuildLabel{bl("//src/cli/...")}, nil, nil, nil, false)
+	assert.Empty(t, labels)
+}
+
+func TestTotalSize(t *testing.T) {
+	size, files := totalSize([]string{
+		"src/gc/test_data/before.build",
+		"src/gc/test_data/after.build",
+		"src/gc/test_data/doesnt_exist.build",
+	})
+	assert.EqualValues(t, 8143, size)
+	assert.Equal(t, 2, files)
+}
+
 func createGraph() *core.BuildGraph {
 	graph := core.NewGraph()
 	createTarget(graph, "//src/core:core")
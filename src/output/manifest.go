@@ -0,0 +1,79 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/fs"
+)
+
+// manifestVersion is the version of the manifest format. It should be incremented on any incompatible change.
+const manifestVersion = 1
+
+// A manifest describes what happened to each target during a build, for consumption by CI systems
+// that need to decide what to do next.
+type manifest struct {
+	Version int `json:"version"`
+	// Targets that were actually built and whose outputs changed.
+	Built []string `json:"built"`
+	// Targets that were built but whose outputs were the same as before.
+	Unchanged []string `json:"unchanged"`
+	// Targets that weren't built because they were retrieved from the cache or reused from a previous build.
+	Cached []string `json:"cached"`
+	// Targets that failed to build, or whose dependencies did.
+	Failed []string `json:"failed"`
+	// Local source files of the targets that were built or unchanged, i.e. the ones that may have caused them to be rebuilt.
+	Sources []string `json:"sources"`
+}
+
+// WriteManifest writes a JSON manifest to the given file describing which targets were built and
+// which were retrieved from the cache.
+func WriteManifest(state *core.BuildState, filename string) error {
+	b, err := json.MarshalIndent(buildManifest(state.Graph), "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(bytes.NewReader(b), filename, 0644)
+}
+
+func buildManifest(graph *core.BuildGraph) *manifest {
+	m := &manifest{
+		Version:   manifestVersion,
+		Built:     []string{},
+		Unchanged: []string{},
+		Cached:    []string{},
+		Failed:    []string{},
+		Sources:   []string{},
+	}
+	sources := map[string]bool{}
+	for _, target := range graph.AllTargets() {
+		switch target.State() {
+		case core.Built, core.BuiltRemotely:
+			m.Built = append(m.Built, target.Label.String())
+		case core.Unchanged:
+			m.Unchanged = append(m.Unchanged, target.Label.String())
+		case core.Cached, core.Reused, core.ReusedRemotely:
+			m.Cached = append(m.Cached, target.Label.String())
+			continue
+		case core.DependencyFailed, core.Failed:
+			m.Failed = append(m.Failed, target.Label.String())
+			continue
+		default:
+			continue
+		}
+		for _, src := range target.AllLocalSourcePaths() {
+			if !sources[src] {
+				sources[src] = true
+				m.Sources = append(m.Sources, src)
+			}
+		}
+	}
+	slices.Sort(m.Built)
+	slices.Sort(m.Unchanged)
+	slices.Sort(m.Cached)
+	slices.Sort(m.Failed)
+	slices.Sort(m.Sources)
+	return m
+}
@@ -0,0 +1,38 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+func TestBuildManifest(t *testing.T) {
+	graph := core.NewGraph()
+	addTarget := func(label string, state core.BuildTargetState, srcs ...string) {
+		target := core.NewBuildTarget(core.ParseBuildLabel(label, ""))
+		for _, src := range srcs {
+			target.AddSource(core.FileLabel{File: src, Package: target.Label.PackageName})
+		}
+		target.SetState(state)
+		graph.AddTarget(target)
+	}
+	addTarget("//src/core:core", core.Built, "core.go", "state.go")
+	addTarget("//src/fs:fs", core.Cached, "fs.go")
+	addTarget("//src/cli:cli", core.Unchanged, "cli.go")
+	addTarget("//src/output:output", core.Failed, "print.go")
+	addTarget("//src/test:test", core.DependencyFailed)
+	addTarget("//src/remote:remote", core.BuiltRemotely)
+	addTarget("//src/build:build", core.Reused)
+	addTarget("//src/query:query", core.Inactive, "query.go")
+
+	assert.Equal(t, &manifest{
+		Version:   1,
+		Built:     []string{"//src/core:core", "//src/remote:remote"},
+		Unchanged: []string{"//src/cli:cli"},
+		Cached:    []string{"//src/build:build", "//src/fs:fs"},
+		Failed:    []string{"//src/output:output", "//src/test:test"},
+		Sources:   []string{"src/cli/cli.go", "src/core/core.go", "src/core/state.go"},
+	}, buildManifest(graph))
+}
@@ -27,7 +27,7 @@ const testDurationGranularity = time.Millisecond
 
 // MonitorState monitors the build while it's running and prints output until the results
 // channel of state has completed.
-func MonitorState(state *core.BuildState, plainOutput, detailedTests, streamTestResults, shell, shellRun bool, traceFile string) {
+func MonitorState(state *core.BuildState, plainOutput, detailedTests, streamTestResults, shell, shellRun bool, traceFile, timingsFile string) {
 	initPrintf(state.Config)
 
 	if len(state.Config.Please.Motd) != 0 {
@@ -40,12 +40,21 @@ func MonitorState(state *core.BuildState, plainOutput, detailedTests, streamTest
 		tw = newTraceWriter(traceFile)
 		defer tw.Close()
 	}
+	var timings *timingsWriter
+	if timingsFile != "" {
+		timings = newTimingsWriter(timingsFile, state.StartTime)
+		defer func() {
+			if err := timings.Close(); err != nil {
+				log.Errorf("Failed to write build timings: %s", err)
+			}
+		}()
+	}
 
 	displayer := setupDisplayer(state, plainOutput)
 	t := time.NewTicker(displayer.Frequency())
 	defer t.Stop()
 	results := state.Results()
-	bt := newBuildingTargets(state, plainOutput)
+	bt := newBuildingTargets(state, plainOutput, streamTestResults)
 	displayer.Update(bt.Targets())
 loop:
 	for {
@@ -54,9 +63,13 @@ loop:
 			if !ok || (state.DebugFailingTests && result.Status == core.TargetTesting) {
 				break loop
 			}
-			if threadID := bt.ProcessResult(result); tw != nil && !result.Status.IsParse() {
+			threadID := bt.ProcessResult(result)
+			if tw != nil && !result.Status.IsParse() {
 				tw.AddTrace(threadID, result, result.Status.IsActive())
 			}
+			if timings != nil {
+				timings.AddResult(threadID, result)
+			}
 			if streamTestResults && (result.Status == core.TargetTested || result.Status == core.TargetTestFailed) {
 				os.Stdout.Write(test.SerialiseResultsToXML(state.Graph.TargetOrDie(result.Label), false, state.Config.Test.StoreTestOutputOnSuccess))
 				os.Stdout.Write([]byte{'\n'})
@@ -88,7 +101,7 @@ loop:
 		if state.PrepareOnly || shell {
 			printTempDirs(state, duration, shell, shellRun)
 		} else if state.NeedTests { // Got to the test phase, report their results.
-			printTestResults(state, bt.FailedTargets, duration, detailedTests)
+			printTestResults(state, bt.FailedTargets, duration, detailedTests, bt.streamFailures)
 		} else if state.NeedHashesOnly {
 			printHashes(state, duration)
 		} else if !state.NeedRun { // Must be plz build or similar, report build outputs.
@@ -152,7 +165,9 @@ func yesNo(b bool) string {
 	return "no"
 }
 
-func printTestResults(state *core.BuildState, failedTargets map[core.BuildLabel]error, duration time.Duration, detailed bool) {
+// printTestResults prints the results of all the tests that ran. If outputShown is true, the output
+// of failed tests has already been printed as they finished so it isn't repeated.
+func printTestResults(state *core.BuildState, failedTargets map[core.BuildLabel]error, duration time.Duration, detailed, outputShown bool) {
 	if len(failedTargets) > 0 {
 		targets := make(core.BuildLabels, 0, len(failedTargets))
 		for t := range failedTargets {
@@ -202,6 +217,9 @@ func printTestResults(state *core.BuildState, failedTargets map[core.BuildLabel]
 							printf("%s\n", failure.Message)
 						}
 						printf("%s\n", failure.Traceback)
+						if outputShown {
+							continue
+						}
 						if len(execution.Stdout) > 0 {
 							printf("${BOLD_RED}Standard output${RESET}:\n%s\n", execution.Stdout)
 						}
@@ -257,6 +275,17 @@ func printTestResults(state *core.BuildState, failedTargets map[core.BuildLabel]
 						showExecutionOutput(result.Executions[0])
 					}
 				}
+			} else if state.ShowTestOutputOnFailure && !outputShown {
+				// Outright failures have already had their output shown above, so this is just the flaky ones.
+				for _, result := range results.TestCases {
+					if result.Success() != nil && hasFailed(result) {
+						printf("    %s\n", formatTestCase(result, result.Name, detailed))
+						for run, execution := range result.Executions {
+							printf("        RUN %d: %s\n", run+1, formatTestExecution(execution, detailed))
+							showExecutionOutput(execution)
+						}
+					}
+				}
 			}
 		} else if results.TimedOut {
 			printf("${RED}%s${RESET} ${WHITE_ON_RED}Timed out${RESET}\n", target.Label)
@@ -266,6 +295,11 @@ func printTestResults(state *core.BuildState, failedTargets map[core.BuildLabel]
 	printf("${BOLD_WHITE}Total time: %s real, %s compute.${RESET}\n", duration, aggregate.Duration.Round(durationGranularity))
 }
 
+// hasFailed returns true if any execution of the given test case failed or errored.
+func hasFailed(testCase core.TestCase) bool {
+	return len(testCase.Failures()) > 0 || len(testCase.Errors()) > 0
+}
+
 func showExecutionOutput(execution core.TestExecution) {
 	if execution.Stdout != "" && execution.Stderr != "" {
 		printf("StdOut:\n%s\nStdErr:\n%s\n", execution.Stdout, execution.Stderr)
@@ -438,6 +472,23 @@ func printTempDirs(state *core.BuildState, duration time.Duration, shell, shellR
 		env["CMD"] = cmd
 		fmt.Printf("  %s: %s\n", label, dir)
 		fmt.Printf("    Command: %s\n", cmd)
+		var toolDir string
+		if shell {
+			tools := target.AllTools()
+			if state.NeedTests {
+				tools = target.AllTestTools()
+			}
+			toolDir = filepath.Join(dir, ".plz-shell-tools")
+			if !filepath.IsAbs(toolDir) {
+				toolDir = filepath.Join(core.RepoRoot, toolDir)
+			}
+			if err := linkShellTools(toolDir, core.ToolPaths(state, tools)); err != nil {
+				log.Warning("Failed to set up tools for shell: %s", err)
+			} else {
+				env["PATH"] = toolDir + ":" + env["PATH"]
+			}
+			env["PS1"] = fmt.Sprintf("[plz %s] \\w $ ", label)
+		}
 		if !shell {
 			// This isn't very useful if we're opening a shell (since then the vars will be set anyway)
 			fmt.Printf("   Expanded: %s\n", os.Expand(cmd, env.ReplaceEnvironment))
@@ -457,8 +508,51 @@ func printTempDirs(state *core.BuildState, duration time.Duration, shell, shellR
 			// TODO(jpoole): Read the docs. Attaching stdin and out doesn't seem to work with this.
 			cmd.SysProcAttr.Setpgid = false
 			cmd.Run() // Ignore errors, it will typically end by the user killing it somehow.
+			if err := os.RemoveAll(toolDir); err != nil {
+				log.Warning("Failed to remove %s: %s", toolDir, err)
+			}
+		}
+	}
+}
+
+// linkShellTools creates a directory containing symlinks to each of the given tools, so they
+// can be invoked by name from a shell. Tools that are directories (e.g. toolchains) have their bin
+// directory linked in instead, if they have one.
+func linkShellTools(dir string, tools []string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	} else if err := os.MkdirAll(dir, core.DirPermissions); err != nil {
+		return err
+	}
+	for _, tool := range tools {
+		if info, err := os.Stat(tool); err != nil {
+			log.Warning("Can't find tool %s: %s", tool, err)
+			continue
+		} else if info.IsDir() {
+			if err := linkDirContents(filepath.Join(tool, "bin"), dir); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := os.Symlink(tool, filepath.Join(dir, filepath.Base(tool))); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkDirContents symlinks every file in the from directory into the to directory.
+func linkDirContents(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Symlink(filepath.Join(from, entry.Name()), filepath.Join(to, entry.Name())); err != nil && !os.IsExist(err) {
+			return err
 		}
 	}
+	return nil
 }
 
 func buildResult(target *core.BuildTarget) []string {
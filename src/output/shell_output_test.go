@@ -2,9 +2,14 @@ package output
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thought-machine/please/src/core"
 )
 
 func TestColouriseError(t *testing.T) {
@@ -13,6 +18,16 @@ func TestColouriseError(t *testing.T) {
 	assert.EqualValues(t, expected, colouriseError(err))
 }
 
+func TestHasFailed(t *testing.T) {
+	pass := core.TestExecution{}
+	fail := core.TestExecution{Failure: &core.TestResultFailure{}}
+	errored := core.TestExecution{Error: &core.TestResultFailure{}}
+	assert.False(t, hasFailed(core.TestCase{Executions: []core.TestExecution{pass}}))
+	assert.True(t, hasFailed(core.TestCase{Executions: []core.TestExecution{fail}}))
+	assert.True(t, hasFailed(core.TestCase{Executions: []core.TestExecution{errored}}))
+	assert.True(t, hasFailed(core.TestCase{Executions: []core.TestExecution{fail, pass}}), "flaky tests count as failed")
+}
+
 func TestShouldInclude(t *testing.T) {
 	testCases := []struct {
 		testName        string
@@ -58,3 +73,25 @@ func TestShouldInclude(t *testing.T) {
 		})
 	}
 }
+
+func TestLinkShellTools(t *testing.T) {
+	src := t.TempDir()
+	tool := filepath.Join(src, "mytool")
+	require.NoError(t, os.WriteFile(tool, nil, 0755))
+	toolchain := filepath.Join(src, "toolchain")
+	require.NoError(t, os.MkdirAll(filepath.Join(toolchain, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(toolchain, "bin", "go"), nil, 0755))
+
+	dir := filepath.Join(t.TempDir(), ".plz-shell-tools")
+	err := linkShellTools(dir, []string{tool, toolchain, filepath.Join(src, "missing")})
+	require.NoError(t, err)
+
+	dest, err := os.Readlink(filepath.Join(dir, "mytool"))
+	assert.NoError(t, err)
+	assert.Equal(t, tool, dest)
+	dest, err = os.Readlink(filepath.Join(dir, "go"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(toolchain, "bin", "go"), dest)
+	_, err = os.Lstat(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+}
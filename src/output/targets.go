@@ -32,6 +32,7 @@ type buildingTargetKey struct {
 // Collects all the currently building targets.
 type buildingTargets struct {
 	plain           bool
+	streamFailures  bool
 	anyRemote       bool
 	state           *core.BuildState
 	targets         []buildingTarget
@@ -42,10 +43,11 @@ type buildingTargets struct {
 	FailedNonTests  []core.BuildLabel
 }
 
-func newBuildingTargets(state *core.BuildState, plainOutput bool) *buildingTargets {
+func newBuildingTargets(state *core.BuildState, plainOutput, streamTestResults bool) *buildingTargets {
 	n := state.Config.Please.NumThreads + state.Config.NumRemoteExecutors()
 	return &buildingTargets{
 		plain:           plainOutput,
+		streamFailures:  streamTestResults && state.ShowTestOutputOnFailure && !state.ShowTestOutput,
 		anyRemote:       state.Config.NumRemoteExecutors() > 0,
 		state:           state,
 		targets:         make([]buildingTarget, n),
@@ -99,21 +101,22 @@ func (bt *buildingTargets) handleOutput(result *core.BuildResult) {
 		bt.FailedTargets[label] = result.Err
 		// Don't stop here after test failure, aggregate them for later.
 		if result.Status != core.TargetTestFailed {
+			bt.FailedNonTests = append(bt.FailedNonTests, label)
 			// Reset colour so the entire compiler error output doesn't appear red.
 			log.Errorf("%s failed:\x1b[0m\n%s", label, shortError(result.Err))
 			// TODO(rgodden): make sure we close off any pending targets when their package fails to parse e.g. because
 			// 	a subrepo failed to build.
 			if !bt.state.KeepGoing || result.Status == core.ParseFailed {
 				bt.state.Stop()
+			} else if maxErrors := bt.state.MaxErrors; maxErrors > 0 && len(bt.FailedNonTests) == maxErrors {
+				log.Errorf("Stopping after %d targets failed", maxErrors)
+				bt.state.Stop()
 			}
 		} else if msg := shortError(result.Err); msg != "" {
 			log.Errorf("%s failed: %s", result.Label, msg)
 		} else {
 			log.Errorf("%s failed", label)
 		}
-		if result.Status != core.TargetTestFailed {
-			bt.FailedNonTests = append(bt.FailedNonTests, label)
-		}
 	} else if result.Status == core.TargetBuildStopped {
 		bt.FailedTargets[label] = nil
 	} else if bt.plain && bt.state.ShowTestOutput && result.Status == core.TargetTested {
@@ -125,6 +128,19 @@ func (bt *buildingTargets) handleOutput(result *core.BuildResult) {
 			}
 		}
 	}
+	if bt.streamFailures && (result.Status == core.TargetTested || result.Status == core.TargetTestFailed) {
+		// Print output of anything that failed straight away so it's available as soon as possible.
+		if results := bt.state.Graph.TargetOrDie(label).Test.Results; results != nil {
+			for _, testCase := range results.TestCases {
+				if hasFailed(testCase) {
+					printf("Finished test %s:\n", testCase.Name)
+					for _, testExecution := range testCase.Executions {
+						showExecutionOutput(testExecution)
+					}
+				}
+			}
+		}
+	}
 }
 
 // index returns the index to use for a result
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+func TestMaxErrors(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	state.KeepGoing = true
+	state.MaxErrors = 2
+	bt := newBuildingTargets(state, true, false)
+	stopped := func() bool {
+		_, actions := state.TaskQueues()
+		select {
+		case _, ok := <-actions:
+			return !ok
+		default:
+			return false
+		}
+	}
+	fail := func(label string, status core.BuildResultStatus) {
+		bt.ProcessResult(&core.BuildResult{Label: core.ParseBuildLabel(label, ""), Status: status, Err: fmt.Errorf("oh no")})
+	}
+	fail("//src/core:core", core.TargetBuildFailed)
+	fail("//src/core:core_test", core.TargetTestFailed)
+	assert.False(t, stopped())
+	fail("//src/fs:fs", core.TargetBuildFailed)
+	assert.True(t, stopped())
+}
+
+func TestKeepGoingWithoutMaxErrors(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	state.KeepGoing = true
+	bt := newBuildingTargets(state, true, false)
+	for i := 0; i < 20; i++ {
+		bt.ProcessResult(&core.BuildResult{Label: core.BuildLabel{PackageName: "src/core", Name: fmt.Sprintf("target_%d", i)}, Status: core.TargetBuildFailed, Err: fmt.Errorf("oh no")})
+	}
+	_, actions := state.TaskQueues()
+	select {
+	case _, ok := <-actions:
+		assert.True(t, ok, "build shouldn't have been stopped")
+	default:
+	}
+}
+
+func TestStreamFailures(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	state.ShowTestOutputOnFailure = true
+	assert.True(t, newBuildingTargets(state, false, true).streamFailures)
+	assert.False(t, newBuildingTargets(state, true, false).streamFailures)
+	state.ShowTestOutput = true
+	assert.False(t, newBuildingTargets(state, false, true).streamFailures, "all output is shown at the end anyway")
+}
@@ -0,0 +1,96 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/fs"
+)
+
+// A timingsWriter collects the timing of each target's build action and writes them out as JSON.
+// Unlike the trace writer it only writes once the build is complete, which lets it replace the
+// file atomically.
+type timingsWriter struct {
+	filename string
+	start    time.Time
+	active   map[core.BuildLabel]time.Time
+	timings  []timing
+}
+
+// A timing describes how long a single target took to build.
+type timing struct {
+	Target     string `json:"target"`
+	StartMs    int64  `json:"start_ms"`
+	DurationMs int64  `json:"duration_ms"`
+	CacheHit   bool   `json:"cache_hit"`
+	Worker     int    `json:"worker"`
+}
+
+// newTimingsWriter returns a new timingsWriter that will write to the given file.
+// Start times are recorded relative to the given time.
+func newTimingsWriter(filename string, start time.Time) *timingsWriter {
+	return &timingsWriter{
+		filename: filename,
+		start:    start,
+		active:   map[core.BuildLabel]time.Time{},
+		timings:  []timing{},
+	}
+}
+
+// AddResult records a single build result. Anything other than building targets is ignored.
+func (tw *timingsWriter) AddResult(threadID int, result *core.BuildResult) {
+	if result.Status.Category() != "Build" {
+		return
+	} else if result.Status.IsActive() {
+		if _, present := tw.active[result.Label]; !present {
+			tw.active[result.Label] = result.Time
+		}
+		return
+	}
+	start, present := tw.active[result.Label]
+	if !present {
+		start = result.Time // Can happen if it was retrieved from the cache without ever starting to build.
+	}
+	delete(tw.active, result.Label)
+	tw.timings = append(tw.timings, timing{
+		Target:     result.Label.String(),
+		StartMs:    start.Sub(tw.start).Milliseconds(),
+		DurationMs: result.Time.Sub(start).Milliseconds(),
+		CacheHit:   result.Status == core.TargetCached,
+		Worker:     threadID,
+	})
+}
+
+// Close writes out the collected timings.
+func (tw *timingsWriter) Close() error {
+	b, err := json.MarshalIndent(tw.timings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(bytes.NewReader(b), tw.filename, 0644)
+}
+
+// ReadBuildTimings reads a file written by --build_timings_file and returns how long each target took to build.
+func ReadBuildTimings(filename string) (map[core.BuildLabel]time.Duration, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var timings []timing
+	if err := json.Unmarshal(b, &timings); err != nil {
+		return nil, fmt.Errorf("Failed to read build timings from %s: %w", filename, err)
+	}
+	m := make(map[core.BuildLabel]time.Duration, len(timings))
+	for _, t := range timings {
+		label, err := core.TryParseBuildLabel(t.Target, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read build timings from %s: %w", filename, err)
+		}
+		m[label] += time.Duration(t.DurationMs) * time.Millisecond
+	}
+	return m, nil
+}
@@ -0,0 +1,56 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+func TestTimingsWriter(t *testing.T) {
+	start := time.Now()
+	filename := filepath.Join(t.TempDir(), "timings.json")
+	tw := newTimingsWriter(filename, start)
+	label1 := core.ParseBuildLabel("//src/core:core", "")
+	label2 := core.ParseBuildLabel("//src/fs:fs", "")
+	tw.AddResult(0, &core.BuildResult{Label: label1, Status: core.PackageParsed, Time: start})
+	tw.AddResult(1, &core.BuildResult{Label: label1, Status: core.TargetBuilding, Time: start.Add(100 * time.Millisecond)})
+	tw.AddResult(1, &core.BuildResult{Label: label1, Status: core.TargetBuilding, Time: start.Add(200 * time.Millisecond)})
+	tw.AddResult(2, &core.BuildResult{Label: label2, Status: core.TargetBuilding, Time: start.Add(150 * time.Millisecond)})
+	tw.AddResult(1, &core.BuildResult{Label: label1, Status: core.TargetBuilt, Time: start.Add(600 * time.Millisecond)})
+	tw.AddResult(2, &core.BuildResult{Label: label2, Status: core.TargetCached, Time: start.Add(160 * time.Millisecond)})
+	tw.AddResult(1, &core.BuildResult{Label: label1, Status: core.TargetTesting, Time: start.Add(700 * time.Millisecond)})
+	tw.AddResult(1, &core.BuildResult{Label: label1, Status: core.TargetTested, Time: start.Add(900 * time.Millisecond)})
+	require.NoError(t, tw.Close())
+
+	b, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	var timings []timing
+	require.NoError(t, json.Unmarshal(b, &timings))
+	assert.Equal(t, []timing{
+		{Target: "//src/core:core", StartMs: 100, DurationMs: 500, Worker: 1},
+		{Target: "//src/fs:fs", StartMs: 150, DurationMs: 10, CacheHit: true, Worker: 2},
+	}, timings)
+}
+
+func TestReadBuildTimings(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timings.json")
+	tw := newTimingsWriter(filename, time.Now())
+	tw.timings = []timing{
+		{Target: "//src/core:core", StartMs: 100, DurationMs: 500, Worker: 1},
+		{Target: "//src/fs:fs", StartMs: 150, DurationMs: 10, CacheHit: true, Worker: 2},
+	}
+	require.NoError(t, tw.Close())
+	timings, err := ReadBuildTimings(filename)
+	require.NoError(t, err)
+	assert.Equal(t, map[core.BuildLabel]time.Duration{
+		core.ParseBuildLabel("//src/core:core", ""): 500 * time.Millisecond,
+		core.ParseBuildLabel("//src/fs:fs", ""):     10 * time.Millisecond,
+	}, timings)
+}
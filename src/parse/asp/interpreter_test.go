@@ -120,6 +120,18 @@ func TestInterpreterBuiltins(t *testing.T) {
 	assert.NotNil(t, s.pkg.Target("lib"))
 }
 
+func TestInterpreterRuleName(t *testing.T) {
+	s, err := parseFile("src/parse/asp/test_data/interpreter/rule_name.build")
+	require.NoError(t, err)
+	assert.Equal(t, "build_rule", s.pkg.Target("direct").RuleName)
+	assert.Equal(t, "my_rule", s.pkg.Target("wrapped").RuleName)
+	target := s.pkg.Target("callback")
+	assert.Equal(t, "callback_rule", target.RuleName)
+	s.state.Graph.AddPackage(s.pkg)
+	require.NoError(t, target.PostBuildFunction.Call(target, ""))
+	assert.Equal(t, "callback_rule", s.pkg.Target("callback_extra").RuleName)
+}
+
 func TestInterpreterConfig(t *testing.T) {
 	s, err := parseFile("src/parse/asp/test_data/interpreter/config.build")
 	require.NoError(t, err)
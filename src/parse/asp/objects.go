@@ -698,6 +698,9 @@ func (f *pyFunc) Call(s *scope, c *Call) pyObject {
 	s2.Set("CONFIG", s.config) // This needs to be copied across too :(
 	s2.Callback = s.Callback
 	s2.parsingFor = s.parsingFor
+	if s2.ruleName = s.ruleName; s2.ruleName == "" {
+		s2.ruleName = f.name
+	}
 	// Handle implicit 'self' parameter for bound functions.
 	args := c.Arguments
 	if f.self != nil {
@@ -72,6 +72,8 @@ const (
 	fileContentArgIdx
 	subrepoArgIdx
 	noTestCoverageArgIdx
+	validationArgIdx
+	networkArgIdx
 )
 
 // createTarget creates a new build target as part of build_rule().
@@ -104,6 +106,7 @@ func createTarget(s *scope, args []pyObject) *core.BuildTarget {
 	target.NeedsTransitiveDependencies = isTruthy(needsTransitiveDepsBuildRuleArgIdx)
 	target.OutputIsComplete = isTruthy(outputIsCompleteBuildRuleArgIdx)
 	target.Sandbox = isTruthy(sandboxBuildRuleArgIdx)
+	target.Network = isTruthy(networkArgIdx)
 	target.TestOnly = test || isTruthy(testOnlyBuildRuleArgIdx)
 	if isTruthy(progressBuildRuleArgIdx) {
 		target.ShowProgress()
@@ -130,9 +133,15 @@ func createTarget(s *scope, args []pyObject) *core.BuildTarget {
 	target.BuildTimeout = sizeAndTimeout(s, size, args[buildTimeoutBuildRuleArgIdx], s.state.Config.Build.Timeout)
 	target.Stamp = isTruthy(stampBuildRuleArgIdx)
 	target.IsFilegroup = args[cmdBuildRuleArgIdx] == filegroupCommand
+	if target.RuleName = s.ruleName; target.RuleName == "" {
+		target.RuleName = "build_rule"
+	}
 	if desc := args[buildingDescriptionBuildRuleArgIdx]; desc != nil && desc != None {
 		target.BuildingDescription = string(desc.(pyString))
 	}
+	if validation := args[validationArgIdx]; validation != nil && validation != None {
+		target.Validation = string(validation.(pyString))
+	}
 	if target.IsBinary {
 		target.AddLabel("bin")
 	}
@@ -186,7 +195,7 @@ func validateSandbox(state *core.BuildState, target *core.BuildTarget) error {
 		return nil
 	}
 	if !target.IsRemoteFile {
-		if target.Sandbox && (target.Test == nil || target.Test.Sandbox) {
+		if target.Sandbox && !target.Network && (target.Test == nil || target.Test.Sandbox) {
 			return nil
 		}
 	}
@@ -611,6 +620,7 @@ func (f *preBuildFunction) Call(target *core.BuildTarget) error {
 	s.config = f.s.config
 	s.Set("CONFIG", f.s.config)
 	s.Callback = true
+	s.ruleName = target.RuleName // Anything created here is attributed to the rule that created the target.
 	s.Set(f.f.args[0], pyString(target.Label.Name))
 	_, err := s.interpreter.interpretStatements(s, f.f.code)
 	return annotateCallbackError(s, target, err)
@@ -631,6 +641,7 @@ func (f *postBuildFunction) Call(target *core.BuildTarget, output string) error
 	s.config = f.s.config
 	s.Set("CONFIG", f.s.config)
 	s.Callback = true
+	s.ruleName = target.RuleName
 	s.Set(f.f.args[0], pyString(target.Label.Name))
 	s.Set(f.f.args[1], fromStringList(strings.Split(strings.TrimSpace(output), "\n")))
 	_, err := s.interpreter.interpretStatements(s, f.f.code)
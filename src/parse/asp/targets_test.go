@@ -45,3 +45,20 @@ func TestValidateTargetSandbox(t *testing.T) {
 	err = validateSandbox(state, foo)
 	require.NoError(t, err)
 }
+
+func TestValidateTargetNetwork(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	state.Config.Sandbox.ExcludeableTargets = []core.BuildLabel{core.NewBuildLabel("third_party", "all")}
+
+	foo := core.NewBuildTarget(core.NewBuildLabel("pkg", "foo"))
+	foo.Sandbox = true
+	foo.Network = true
+	err := validateSandbox(state, foo)
+	require.Error(t, err)
+
+	bar := core.NewBuildTarget(core.NewBuildLabel("third_party", "bar"))
+	bar.Sandbox = true
+	bar.Network = true
+	err = validateSandbox(state, bar)
+	require.NoError(t, err)
+}
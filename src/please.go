@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -41,6 +42,7 @@ import (
 	"github.com/thought-machine/please/src/query"
 	"github.com/thought-machine/please/src/run"
 	"github.com/thought-machine/please/src/sandbox"
+	"github.com/thought-machine/please/src/sbom"
 	"github.com/thought-machine/please/src/scm"
 	"github.com/thought-machine/please/src/test"
 	"github.com/thought-machine/please/src/tool"
@@ -52,6 +54,10 @@ var log = logging.Log
 
 var config *core.Configuration
 
+// maxChangedFilesForDependees is the number of changed files beyond which we warn that finding their
+// dependees with plz query changes --in may be very expensive.
+const maxChangedFilesForDependees = 10000
+
 var opts struct {
 	Usage      string `usage:"Please is a high-performance multi-language build system.\n\nIt uses BUILD files to describe what to build and how to build it.\nSee https://please.build for more information about how it works and what Please can do for you."`
 	BuildFlags struct {
@@ -59,6 +65,7 @@ var opts struct {
 		Arch       cli.Arch             `short:"a" long:"arch" description:"Architecture to compile for."`
 		RepoRoot   cli.Filepath         `short:"r" long:"repo_root" description:"Root of repository to build." env:"PLZ_REPO_ROOT"`
 		NumThreads int                  `short:"n" long:"num_threads" description:"Number of concurrent build operations. Default is number of CPUs + 2."`
+		Jobs       int                  `long:"jobs" description:"Alias for --num_threads."`
 		Include    []string             `short:"i" long:"include" description:"Label of targets to include in automatic detection."`
 		Exclude    []string             `short:"e" long:"exclude" description:"Label of targets to exclude from automatic detection."`
 		Option     ConfigOverrides      `short:"o" long:"override" env:"PLZ_OVERRIDES" env-delim:";" description:"Options to override from .plzconfig (e.g. -o please.selfupdate:false)"`
@@ -76,7 +83,9 @@ var opts struct {
 		Colour            bool          `long:"colour" description:"Forces coloured output from logging & other shell output."`
 		NoColour          bool          `long:"nocolour" description:"Forces colourless output from logging & other shell output."`
 		TraceFile         cli.Filepath  `long:"trace_file" description:"File to write Chrome tracing output into"`
+		BuildTimingsFile  cli.Filepath  `long:"build_timings_file" description:"File to write the timings of each target's build into, as JSON"`
 		ShowAllOutput     bool          `long:"show_all_output" description:"Show all output live from all commands. Implies --plain_output."`
+		Warnings          string        `long:"warnings" choice:"always" choice:"never" choice:"once" default:"once" description:"When to show stderr (e.g. compiler warnings) from successful builds: once shows it when the target is built, always also replays it when it's retrieved from the cache."`
 		CompletionScript  bool          `long:"completion_script" description:"Prints the bash / zsh completion script to stdout"`
 	} `group:"Options controlling output & logging"`
 
@@ -85,9 +94,11 @@ var opts struct {
 		NoHashVerification bool    `long:"nohash_verification" description:"Hash verification errors are nonfatal." env:"PLZ_NO_HASH_VERIFICATION"`
 		NoLock             bool    `long:"nolock" description:"Don't attempt to lock the repo exclusively. Use with care."`
 		KeepWorkdirs       bool    `long:"keep_workdirs" description:"Don't clean directories in plz-out/tmp after successfully building targets."`
+		KeepSandbox        bool    `long:"keep_sandbox" description:"Print the working directory of any failed build and write its environment to a .plz-env file there."`
 		HTTPProxy          cli.URL `long:"http_proxy" env:"HTTP_PROXY" description:"HTTP proxy to use for downloads"`
 		Debug              bool    `long:"debug" description:"When enabled, Please will enter into an interactive debugger when breakpoint() is called during parsing."`
 		KeepGoing          bool    `long:"keep_going" description:"Continue as much as possible after an error. While the target that failed and those that depend on it cannot be build, other prerequisites of these targets can be."`
+		MaxErrors          int     `long:"max_errors" optional:"true" optional-value:"10" description:"With --keep_going, stop scheduling new actions once this many targets have failed. Defaults to 10 if given without a value; without it, --keep_going has no limit."`
 		AllowSudo          bool    `long:"allow_sudo" hidden:"true" description:"Allow running under sudo (normally this is a very bad idea)"`
 	} `group:"Options that enable / disable certain behaviors"`
 
@@ -105,12 +116,16 @@ var opts struct {
 	Complete         string `long:"complete" hidden:"true" env:"PLZ_COMPLETE" description:"Provide completion options for this build target."`
 
 	Build struct {
-		Shell      string `long:"shell" choice:"shell" choice:"run" optional:"true" optional-value:"shell" description:"Like --prepare, but opens a shell in the build directory with the appropriate environment variables."`
-		Rebuild    bool   `long:"rebuild" description:"To force the optimisation and rebuild one or more targets."`
-		NoDownload bool   `long:"nodownload" hidden:"true" description:"Don't download outputs after building. Only applies when using remote build execution."`
-		Download   bool   `long:"download" hidden:"true" description:"Force download of all outputs regardless of original target spec. Only applies when using remote build execution."`
-		OutDir     string `long:"out_dir" optional:"true" description:"Copies build output to given directory"`
-		Args       struct {
+		Shell        string       `long:"shell" choice:"shell" choice:"run" optional:"true" optional-value:"shell" description:"Like --prepare, but opens a shell in the build directory with the appropriate environment variables and the target's tools on the PATH."`
+		Rebuild      bool         `long:"rebuild" description:"To force the optimisation and rebuild one or more targets."`
+		NoDownload   bool         `long:"nodownload" hidden:"true" description:"Don't download outputs after building. Only applies when using remote build execution."`
+		Download     bool         `long:"download" hidden:"true" description:"Force download of all outputs regardless of original target spec. Only applies when using remote build execution."`
+		OutDir       string       `long:"out_dir" optional:"true" description:"Copies build output to given directory"`
+		SBOMFile     cli.Filepath `long:"sbom_file" description:"Writes a software bill of materials for the built targets to this file, in SPDX JSON format"`
+		Reproduce    bool         `long:"reproduce" description:"Builds each target twice and fails if the outputs differ. Only targets that actually get built are checked, so use --rebuild to force the requested ones to be."`
+		Manifest     cli.Filepath `long:"watch_manifest" description:"Writes a JSON manifest of which targets were built, unchanged, cached or failed to this file after the build"`
+		ConfigMatrix string       `long:"config_matrix" description:"Comma-separated list of build configs (e.g. opt,dbg) to build the targets in, one after another."`
+		Args         struct {
 			Targets []core.BuildLabel `positional-arg-name:"targets" description:"Targets to build"`
 		} `positional-args:"true" required:"true"`
 	} `command:"build" description:"Builds one or more targets"`
@@ -131,11 +146,15 @@ var opts struct {
 		TestResultsFile  cli.Filepath `long:"test_results_file" default:"plz-out/log/test_results.xml" description:"File to write combined test results to."`
 		SurefireDir      cli.Filepath `long:"surefire_dir" default:"plz-out/surefire-reports" description:"Directory to copy XML test results to."`
 		ShowOutput       bool         `short:"s" long:"show_output" description:"Always show output of tests, even on success."`
+		OutputOnFailure  bool         `long:"test_output_on_failure" description:"Show output of tests only when they fail or are flaky. Printed as soon as they finish when used with --stream_results."`
 		DebugFailingTest bool         `short:"d" long:"debug" description:"Allows starting an interactive debugger on test failure. Does not work with all test types (currently only python/pytest). Implies -c dbg unless otherwise set."`
 		Failed           bool         `short:"f" long:"failed" description:"Runs just the test cases that failed from the immediately previous run."`
 		Detailed         bool         `long:"detailed" description:"Prints more detailed output after tests."`
 		Shell            string       `long:"shell" choice:"shell" choice:"run" optional:"true" optional-value:"shell" description:"Opens a shell in the test directory with the appropriate environment variables."`
 		StreamResults    bool         `long:"stream_results" description:"Prints test results on stdout as they are run."`
+		EnvFile          cli.Filepath `long:"test_env_file" description:"File of KEY=value environment variables to set for tests, in .env format. They don't affect whether tests are rerun."`
+		ShardCount       int          `long:"shard_count" description:"Splits the tests into this many shards by a hash of their labels and only runs one of them, for distributing tests across machines. Shards are not all the same size."`
+		ShardIndex       int          `long:"shard_index" description:"Index of the shard to run when --shard_count is passed, starting from 0."`
 		// Slightly awkward since we can specify a single test with arguments or multiple test targets.
 		Args struct {
 			Target core.BuildLabel `positional-arg-name:"target" description:"Target to test"`
@@ -157,14 +176,23 @@ var opts struct {
 		TestResultsFile     cli.Filepath  `long:"test_results_file" default:"plz-out/log/test_results.xml" description:"File to write combined test results to."`
 		SurefireDir         cli.Filepath  `long:"surefire_dir" default:"plz-out/surefire-reports" description:"Directory to copy XML test results to."`
 		CoverageResultsFile cli.Filepath  `long:"coverage_results_file" env:"COVERAGE_RESULTS_FILE" default:"plz-out/log/coverage.json" description:"File to write combined coverage results to."`
-		CoverageXMLReport   cli.Filepath  `long:"coverage_xml_report" env:"COVERAGE_XML_REPORT" default:"plz-out/log/coverage.xml" description:"XML File to write combined coverage results to."`
+		CoverageXMLReport   cli.Filepath  `long:"coverage_xml_report" env:"COVERAGE_XML_REPORT" default:"plz-out/log/coverage.xml" description:"File to write combined coverage results to, in Cobertura XML format."`
+		BadgeFile           cli.Filepath  `long:"badge_file" description:"File to write an SVG badge showing the total line coverage to."`
+		BadgeThreshold      []int         `long:"badge_threshold" default:"50" default:"80" description:"Coverage percentages at which the badge turns from red to yellow and from yellow to green. Pass twice to override both."`
 		Incremental         bool          `short:"i" long:"incremental" description:"Calculates summary statistics for incremental coverage, i.e. stats for just the lines currently modified."`
+		IncrementalMin      float32       `long:"incremental_coverage_fail_if_below" description:"Fails if the incremental coverage percentage is below this. Implies --incremental."`
+		BranchCoverage      bool          `long:"branch_coverage" description:"Additionally records finer-grained hit counts in the coverage results file. Currently only supported for Go, where they're the counts of each basic block."`
+		GitHubChecks        bool          `long:"github_checks" description:"Posts the coverage results as a GitHub check run on the current commit. Requires $GITHUB_TOKEN to be set."`
 		ShowOutput          bool          `short:"s" long:"show_output" description:"Always show output of tests, even on success."`
+		OutputOnFailure     bool          `long:"test_output_on_failure" description:"Show output of tests only when they fail or are flaky. Printed as soon as they finish when used with --stream_results."`
 		DebugFailingTest    bool          `short:"d" long:"debug" description:"Allows starting an interactive debugger on test failure. Does not work with all test types (currently only python/pytest). Implies -c dbg unless otherwise set."`
 		Failed              bool          `short:"f" long:"failed" description:"Runs just the test cases that failed from the immediately previous run."`
 		Detailed            bool          `long:"detailed" description:"Prints more detailed output after tests."`
 		Shell               string        `long:"shell" choice:"shell" choice:"run" optional:"true" optional-value:"shell" description:"Opens a shell in the test directory with the appropriate environment variables."`
 		StreamResults       bool          `long:"stream_results" description:"Prints test results on stdout as they are run."`
+		EnvFile             cli.Filepath  `long:"test_env_file" description:"File of KEY=value environment variables to set for tests, in .env format. They don't affect whether tests are rerun."`
+		ShardCount          int           `long:"shard_count" description:"Splits the tests into this many shards by a hash of their labels and only runs one of them, for distributing tests across machines. Shards are not all the same size."`
+		ShardIndex          int           `long:"shard_index" description:"Index of the shard to run when --shard_count is passed, starting from 0."`
 		Args                struct {
 			Target core.BuildLabel `positional-arg-name:"target" description:"Target to test"`
 			Args   TargetsOrArgs   `positional-arg-name:"arguments" description:"Arguments or test selectors"`
@@ -185,21 +213,23 @@ var opts struct {
 	} `command:"debug" description:"Starts a debug session on the given target if supported by its build definition."`
 
 	Run struct {
-		Env        bool   `long:"env" description:"Overrides environment variables (e.g. PATH) in the new process."`
-		Rebuild    bool   `long:"rebuild" description:"To force the optimisation and rebuild one or more targets."`
-		InWD       bool   `long:"in_wd" description:"Deprecated in favour of --wd=/path/to/this/directory. When running locally, stay in the original working directory."`
-		WD         string `long:"wd" description:"The working directory in which to run the target."`
-		InTempDir  bool   `long:"in_tmp_dir" description:"Runs in a temp directory, setting env variables and copying in runtime data similar to tests."`
-		EntryPoint string `long:"entry_point" short:"e" description:"The entry point of the target to use." default:""`
-		Cmd        string `long:"cmd" description:"Overrides the command to be run. This is useful when the initial command needs to be wrapped in another one." default:""`
+		Env        bool        `long:"env" description:"Overrides environment variables (e.g. PATH) in the new process."`
+		Rebuild    bool        `long:"rebuild" description:"To force the optimisation and rebuild one or more targets."`
+		InWD       bool        `long:"in_wd" description:"Deprecated in favour of --wd=/path/to/this/directory. When running locally, stay in the original working directory."`
+		WD         string      `long:"wd" description:"The working directory in which to run the target."`
+		InTempDir  bool        `long:"in_tmp_dir" description:"Runs in a temp directory, setting env variables and copying in runtime data similar to tests."`
+		Mounts     []run.Mount `long:"mount" description:"With --in_tmp_dir, makes an additional host path available in the temp directory, in the form src:dst. Can be repeated."`
+		EntryPoint string      `long:"entry_point" short:"e" description:"The entry point of the target to use." default:""`
+		Cmd        string      `long:"cmd" description:"Overrides the command to be run. This is useful when the initial command needs to be wrapped in another one." default:""`
 		Parallel   struct {
 			NumTasks       int                `short:"n" long:"num_tasks" default:"10" description:"Maximum number of subtasks to run in parallel"`
 			Output         process.OutputMode `long:"output" default:"default" choice:"default" choice:"quiet" choice:"group_immediate" description:"Allows to control how the output should be handled."`
 			PositionalArgs struct {
 				Targets TargetsOrArgs `positional-arg-name:"target" required:"true" description:"Target to run"`
 			} `positional-args:"true" required:"true"`
-			Args   cli.Filepaths `short:"a" long:"arg" description:"Arguments to pass to the target. Deprecated, pass them directly as arguments (after -- if needed)"`
-			Detach bool          `long:"detach" description:"Detach from the parent process when all children have spawned"`
+			Args    cli.Filepaths `short:"a" long:"arg" description:"Arguments to pass to the target. Deprecated, pass them directly as arguments (after -- if needed)"`
+			Detach  bool          `long:"detach" description:"Detach from the parent process when all children have spawned"`
+			PIDFile cli.Filepath  `long:"pid_file" description:"With --detach, file to write the PIDs of the spawned children to, one per line. Not written if any of them fail to start."`
 		} `command:"parallel" description:"Runs a sequence of targets in parallel"`
 		Sequential struct {
 			Quiet          bool               `short:"q" long:"quiet" description:"Suppress output from successful subprocesses."`
@@ -255,8 +285,9 @@ var opts struct {
 	} `command:"clean" description:"Cleans build artifacts" subcommands-optional:"true"`
 
 	Watch struct {
-		Run    bool `short:"r" long:"run" description:"Runs the specified targets when they change (default is to build or test as appropriate)."`
-		NoTest bool `long:"notest" description:"If set, no tests will be ran. The targets will only be re-built."`
+		Run    bool   `short:"r" long:"run" description:"Runs the specified targets when they change (default is to build or test as appropriate)."`
+		NoTest bool   `long:"notest" description:"If set, no tests will be ran. The targets will only be re-built."`
+		Exec   string `long:"exec" description:"Shell command to run after each successful rebuild. $PLZ_CHANGED_TARGETS is set to the targets that were rebuilt."`
 		Args   struct {
 			Target core.BuildLabel `positional-arg-name:"target" description:"Target to watch for changes"`
 			Args   TargetsOrArgs   `positional-arg-name:"arguments" description:"Additional targets to watch, or test selectors"`
@@ -278,6 +309,7 @@ var opts struct {
 		Dir                cli.Filepath `long:"dir" description:"Directory to create config in" default:"."`
 		BazelCompatibility bool         `long:"bazel_compat" description:"Initialises config for Bazel compatibility mode."`
 		NoPrompt           bool         `long:"no_prompt" description:"Don't interactively prompt for optional config'"`
+		Template           string       `long:"template" description:"URL of a .zip or .tar.gz archive, or the name of a template in the please-build organisation, to populate the new repo from"`
 		Config             struct {
 			User  bool `short:"u" long:"user" description:"Modifies the user-level config file"`
 			Local bool `short:"l" long:"local" description:"Modifies the local config file (.plzconfig.local)"`
@@ -289,11 +321,13 @@ var opts struct {
 			Revision  string `short:"r" long:"revision" description:"The revision to pin the pleasings repo to. This can be a branch, commit, tag, or other git reference."`
 			Location  string `short:"l" long:"location" description:"The location of the build file to write the subrepo rule to" default:"BUILD"`
 			PrintOnly bool   `long:"print" description:"Print the rule to standard out instead of writing it to a file"`
+			NoVerify  bool   `long:"no_verify" description:"Don't check with GitHub that the revision exists"`
 		} `command:"pleasings" description:"Initialises the pleasings repo"`
 		Pleasew struct {
 		} `command:"pleasew" description:"Initialises the pleasew wrapper script"`
 		Plugin struct {
 			Version string `short:"v" long:"version" description:"Version of plugin to install. If not set, the latest is found."`
+			Force   bool   `long:"force" description:"Install the plugins even if they conflict with one another."`
 			Args    struct {
 				Plugins []string `positional-arg-name:"plugin" required:"true" description:"Plugins to install"`
 			} `positional-args:"true"`
@@ -301,12 +335,13 @@ var opts struct {
 	} `command:"init" subcommands-optional:"true" description:"Initialises a .plzconfig file in the current directory"`
 
 	Gc struct {
-		Conservative bool `short:"c" long:"conservative" description:"Runs a more conservative / safer GC."`
-		TargetsOnly  bool `short:"t" long:"targets_only" description:"Only print the targets to delete"`
-		SrcsOnly     bool `short:"s" long:"srcs_only" description:"Only print the source files to delete"`
-		NoPrompt     bool `short:"y" long:"no_prompt" description:"Remove targets without prompting"`
-		DryRun       bool `short:"n" long:"dry_run" description:"Don't remove any targets or files, just print what would be done"`
-		Git          bool `short:"g" long:"git" description:"Use 'git rm' to remove unused files instead of just 'rm'."`
+		Conservative bool     `short:"c" long:"conservative" description:"Runs a more conservative / safer GC."`
+		TargetsOnly  bool     `short:"t" long:"targets_only" description:"Only print the targets to delete"`
+		SrcsOnly     bool     `short:"s" long:"srcs_only" description:"Only print the source files to delete"`
+		NoPrompt     bool     `short:"y" long:"no_prompt" description:"Remove targets without prompting"`
+		DryRun       bool     `short:"n" long:"dry_run" description:"Don't remove any targets or files, just print what would be done"`
+		Git          bool     `short:"g" long:"git" description:"Use 'git rm' to remove unused files instead of just 'rm'."`
+		KeepLabel    []string `long:"keep_label" description:"Never remove targets with this label. Can be repeated; adds to the KeepLabel values in the [gc] config section."`
 		Args         struct {
 			Targets []core.BuildLabel `positional-arg-name:"targets" description:"Targets to limit gc to."`
 		} `positional-args:"true"`
@@ -326,9 +361,15 @@ var opts struct {
 	} `command:"export" subcommands-optional:"true" description:"Exports a set of targets and files from the repo."`
 
 	Format struct {
-		Quiet bool `long:"quiet" short:"q" description:"Don't print corrections to stdout, simply exit with a code indicating success / failure (for linting etc)."`
-		Write bool `long:"write" short:"w" description:"Rewrite files after update"`
-		Args  struct {
+		Quiet            bool         `long:"quiet" short:"q" description:"Don't print corrections to stdout, simply exit with a code indicating success / failure (for linting etc)."`
+		Write            bool         `long:"write" short:"w" description:"Rewrite files after update"`
+		RemoveUnusedDeps bool         `long:"remove_unused_deps" description:"Removes deps of go_library and go_binary targets that aren't imported by any of their sources. Entries with a '# keep' comment are left alone."`
+		DryRun           bool         `long:"dry_run" description:"With --remove_unused_deps, print the deps that would be removed instead of modifying any files."`
+		Imports          bool         `long:"imports" description:"Expands wildcard labels (e.g. //pkg/... or //pkg:all) in deps to the targets they match. Entries with a '# no-imports' comment are left alone."`
+		MigrateRules     []string     `long:"migrate_rules" description:"Renames calls to one rule to another, in the form from=to. Can be passed multiple times."`
+		MigrateRulesMap  cli.Filepath `long:"migrate_rules_map" description:"JSON file mapping rules given to --migrate_rules to a mapping of old to new argument names."`
+		StripKeep        bool         `long:"strip_keep_comments" description:"Removes '# keep' comments, which otherwise stop list entries being moved by formatting or removed by --remove_unused_deps."`
+		Args             struct {
 			Files cli.Filepaths `positional-arg-name:"files" description:"BUILD files to reformat"`
 		} `positional-args:"true"`
 	} `command:"format" alias:"fmt" description:"Autoformats BUILD files"`
@@ -348,11 +389,13 @@ var opts struct {
 
 	Query struct {
 		Deps struct {
-			DOT    bool `long:"dot" description:"Output in dot format"`
-			Hidden bool `long:"hidden" short:"h" description:"Output internal / hidden dependencies too"`
-			Level  int  `long:"level" default:"-1" description:"Levels of the dependencies to retrieve."`
-			Unique bool `long:"unique" hidden:"true" description:"Has no effect, only exists for compatibility."`
-			Args   struct {
+			DOT          bool         `long:"dot" description:"Output in dot format"`
+			Hidden       bool         `long:"hidden" short:"h" description:"Output internal / hidden dependencies too"`
+			Level        int          `long:"level" default:"-1" description:"Levels of the dependencies to retrieve."`
+			Unique       bool         `long:"unique" hidden:"true" description:"Has no effect, only exists for compatibility."`
+			CriticalPath bool         `long:"critical_path" description:"Highlights the critical path through the dependencies in the dot output. Requires --timings_file."`
+			TimingsFile  cli.Filepath `long:"timings_file" description:"File written by --build_timings_file to take build times from for --critical_path."`
+			Args         struct {
 				Targets []core.BuildLabel `positional-arg-name:"targets" description:"Targets to query" required:"true"`
 			} `positional-args:"true" required:"true"`
 		} `command:"deps" description:"Queries the dependencies of a target."`
@@ -364,9 +407,11 @@ var opts struct {
 			} `positional-args:"true" required:"true"`
 		} `command:"revdeps" alias:"reverseDeps" description:"Queries all the reverse dependencies of a target."`
 		SomePath struct {
-			Except []core.BuildLabel `long:"except" description:"Targets to exclude from path calculation"`
-			Hidden bool              `long:"hidden" description:"Show hidden targets as well"`
-			Args   struct {
+			Except   []core.BuildLabel `long:"except" description:"Targets to exclude from path calculation"`
+			Hidden   bool              `long:"hidden" description:"Show hidden targets as well"`
+			AllPaths bool              `long:"all_paths" description:"Show all paths between the targets rather than just one"`
+			MaxPaths int               `long:"max_paths" default:"100" description:"Maximum number of paths to show with --all_paths. 0 means no limit."`
+			Args     struct {
 				Target1 core.BuildLabel `positional-arg-name:"target1" description:"First build target" required:"true"`
 				Target2 core.BuildLabel `positional-arg-name:"target2" description:"Second build target" required:"true"`
 			} `positional-args:"true" required:"true"`
@@ -378,11 +423,12 @@ var opts struct {
 			} `positional-args:"true"`
 		} `command:"alltargets" description:"Lists all targets in the graph"`
 		Print struct {
-			JSON       bool     `long:"json" description:"Print the targets as json rather than python"`
-			OmitHidden bool     `long:"omit_hidden" description:"Omit hidden fields. Can be useful when using wildcard"`
-			Fields     []string `short:"f" long:"field" description:"Individual fields to print of the target"`
-			Labels     []string `short:"l" long:"label" description:"Prints all labels with the given prefix (with the prefix stripped off). Overrides --field."`
-			Args       struct {
+			JSON          bool     `long:"json" description:"Print the targets as json rather than python"`
+			SchemaVersion int      `long:"schema_version" default:"1" description:"Version of the JSON schema to print with --json. 0 is the original unversioned format."`
+			OmitHidden    bool     `long:"omit_hidden" description:"Omit hidden fields. Can be useful when using wildcard"`
+			Fields        []string `short:"f" long:"field" description:"Individual fields to print of the target"`
+			Labels        []string `short:"l" long:"label" description:"Prints all labels with the given prefix (with the prefix stripped off). Overrides --field."`
+			Args          struct {
 				Targets []core.BuildLabel `positional-arg-name:"targets" description:"Targets to print" required:"true"`
 			} `positional-args:"true" required:"true"`
 		} `command:"print" description:"Prints a representation of a single target"`
@@ -404,7 +450,9 @@ var opts struct {
 			} `positional-args:"true" required:"true"`
 		} `command:"output" alias:"outputs" description:"Prints all outputs of a target."`
 		Graph struct {
-			Args struct {
+			PruneUnconnected bool `long:"prune_unconnected" description:"Consider the whole graph, but only include targets that the given targets transitively depend on or are depended on by."`
+			NDJSON           bool `long:"ndjson" description:"Print each target as a separate JSON object on its own line, rather than one nested object."`
+			Args             struct {
 				Targets []core.BuildLabel `positional-arg-name:"targets" description:"Targets to render graph for"`
 			} `positional-args:"true"`
 		} `command:"graph" description:"Prints a representation of the build graph."`
@@ -418,6 +466,7 @@ var opts struct {
 		} `command:"whatinputs" description:"Prints out target(s) with provided file(s) as inputs"`
 		WhatOutputs struct {
 			EchoFiles bool `long:"echo_files" description:"Echo the file for which the printed output is responsible."`
+			JSON      bool `long:"json" description:"Print the targets and their outputs as a JSON array."`
 			Args      struct {
 				Files cli.StdinStrings `positional-arg-name:"files" required:"true" description:"Files to query targets responsible for"`
 			} `positional-args:"true"`
@@ -428,18 +477,20 @@ var opts struct {
 			} `positional-args:"true"`
 		} `command:"rules" description:"Prints built-in rules to stdout as JSON"`
 		Changes struct {
-			Since            string `short:"s" long:"since" default:"origin/master" description:"Revision to compare against"`
-			IncludeDependees string `long:"include_dependees" default:"none" choice:"none" choice:"direct" choice:"transitive" description:"Deprecated: use level 1 for direct and -1 for transitive. Include direct or transitive dependees of changed targets."`
-			IncludeSubrepos  bool   `long:"include_subrepos" description:"Include changed targets that belong to subrepos."`
-			Level            int    `long:"level" default:"-2" description:"Levels of the dependencies of changed targets (-1 for unlimited)." default-mask:"0"`
-			Inexact          bool   `long:"inexact" description:"Calculate changes more quickly and without doing any SCM checkouts, but may miss some targets."`
-			In               string `long:"in" description:"Calculate changes contained within given scm spec (commit range/sha/ref/etc). Implies --inexact."`
+			Since            string       `short:"s" long:"since" description:"Revision to compare against. Defaults to origin/master."`
+			SinceFile        cli.Filepath `long:"since_file" description:"File whose first line is the revision to compare against. Can't be used with --since."`
+			IncludeDependees string       `long:"include_dependees" default:"none" choice:"none" choice:"direct" choice:"transitive" description:"Deprecated: use level 1 for direct and -1 for transitive. Include direct or transitive dependees of changed targets."`
+			IncludeSubrepos  bool         `long:"include_subrepos" description:"Include changed targets that belong to subrepos."`
+			Level            int          `long:"level" default:"-2" description:"Levels of the dependencies of changed targets (-1 for unlimited)." default-mask:"0"`
+			Inexact          bool         `long:"inexact" description:"Calculate changes more quickly and without doing any SCM checkouts, but may miss some targets."`
+			In               string       `long:"in" description:"Calculate changes contained within given scm spec (e.g. a sha or ref, or a range such as A..B or A...B). Implies --inexact."`
 			Args             struct {
 				Files cli.StdinStrings `positional-arg-name:"files" description:"Files to calculate changes for. Overrides flags relating to SCM operations."`
 			} `positional-args:"true"`
 		} `command:"changes" description:"Calculates the set of changed targets in regard to a set of modified files or SCM commits."`
 		Filter struct {
-			Hidden bool `long:"hidden" description:"Show hidden targets as well"`
+			Hidden bool     `long:"hidden" description:"Show hidden targets as well"`
+			ByType []string `long:"by_type" description:"Only show targets created by this rule (e.g. go_library). Can be a glob pattern and can be repeated."`
 			Args   struct {
 				Targets []core.BuildLabel `positional-arg-name:"targets" description:"Targets to filter"`
 			} `positional-args:"true"`
@@ -447,8 +498,9 @@ var opts struct {
 		RepoRoot struct {
 		} `command:"reporoot" alias:"repo_root" description:"Output the root of the current Please repo"`
 		Config struct {
-			JSON bool `long:"json" description:"Output as JSON."`
-			Args struct {
+			JSON   bool `long:"json" description:"Output as JSON."`
+			Schema bool `long:"schema" description:"Print the JSON schema describing the output of --json."`
+			Args   struct {
 				Options []string `positional-arg-name:"options" description:"Print specific options."`
 			} `positional-args:"true"`
 		} `command:"config" description:"Prints the configuration settings"`
@@ -465,7 +517,23 @@ var opts struct {
 // Functions are called after args are parsed and return a POSIX exit code (0 means success).
 var buildFunctions = map[string]func() int{
 	"build": func() int {
+		if opts.Build.ConfigMatrix != "" {
+			if opts.Build.OutDir != "" || opts.Build.SBOMFile != "" || opts.Build.Manifest != "" {
+				log.Fatalf("--config_matrix can't be combined with --out_dir, --sbom_file or --watch_manifest")
+			}
+			return buildConfigMatrix(opts.Build.Args.Targets, opts.Build.ConfigMatrix)
+		}
 		success, state := runBuild(opts.Build.Args.Targets, true, false, false)
+		if opts.Build.Manifest != "" {
+			if err := output.WriteManifest(state, getAbsolutePath(string(opts.Build.Manifest), originalWorkingDirectory)); err != nil {
+				log.Fatalf("Failed to write build manifest: %s", err)
+			}
+		}
+		if success && opts.Build.SBOMFile != "" {
+			if err := sbom.Write(state, state.ExpandOriginalLabels(), getAbsolutePath(string(opts.Build.SBOMFile), originalWorkingDirectory)); err != nil {
+				log.Fatalf("Failed to write SBOM: %s", err)
+			}
+		}
 		if !success || opts.Build.OutDir == "" {
 			return toExitCode(success, state)
 		}
@@ -519,14 +587,21 @@ var buildFunctions = map[string]func() int{
 		success, state := doTest(targets, args, opts.Cover.SurefireDir, opts.Cover.TestResultsFile)
 		test.AddOriginalTargetsToCoverage(state, opts.Cover.IncludeAllFiles)
 		test.RemoveFilesFromCoverage(state.Coverage, state.Config.Cover.ExcludeExtension, state.Config.Cover.ExcludeGlob)
+		if !opts.Cover.BranchCoverage {
+			state.Coverage.BranchCoverage = nil
+		}
 
 		var stats *test.IncrementalStats
-		if opts.Cover.Incremental {
+		incrementalPassed := true
+		if opts.Cover.Incremental || opts.Cover.IncrementalMin > 0 {
 			lines, err := scm.NewFallback(core.RepoRoot).ChangedLines()
 			if err != nil {
 				log.Fatalf("Failed to determine changes: %s", err)
 			}
 			stats = test.CalculateIncrementalStats(state, lines)
+			if opts.Cover.IncrementalMin > 0 {
+				incrementalPassed = stats.CheckThreshold(opts.Cover.IncrementalMin)
+			}
 		}
 		if opts.Cover.CoverageResultsFile != "" {
 			test.WriteCoverageToFileOrDie(state.Coverage, string(opts.Cover.CoverageResultsFile), stats)
@@ -534,15 +609,44 @@ var buildFunctions = map[string]func() int{
 		if opts.Cover.CoverageXMLReport != "" {
 			test.WriteXMLCoverageToFileOrDie(targets, state.Coverage, string(opts.Cover.CoverageXMLReport))
 		}
+		if opts.Cover.BadgeFile != "" {
+			if t := opts.Cover.BadgeThreshold; len(t) != 2 || t[0] > t[1] {
+				log.Fatalf("--badge_threshold must be given twice, with the lower threshold first")
+			}
+			test.WriteCoverageBadgeOrDie(state.Coverage, getAbsolutePath(string(opts.Cover.BadgeFile), originalWorkingDirectory), opts.Cover.BadgeThreshold)
+		}
 
 		if opts.Cover.LineCoverageReport && success {
 			output.PrintLineCoverageReport(state, opts.Cover.IncludeFile.AsStrings())
 		} else if !opts.Cover.NoCoverageReport && opts.Cover.Shell == "" {
 			output.PrintCoverage(state, opts.Cover.IncludeFile.AsStrings())
 		}
-		if opts.Cover.Incremental {
+		if stats != nil {
 			output.PrintIncrementalCoverage(stats)
 		}
+		if opts.Cover.GitHubChecks {
+			if err := test.PostGitHubCheck(state.Coverage, state.Config.Cover.MinLineCoverage, state.Config.Cover.MinLineCoverageExcludes); err != nil {
+				log.Errorf("Failed to post GitHub check: %s", err)
+				return 1
+			}
+		}
+		if minCoverage := state.Config.Cover.MinLineCoverage; minCoverage > 0 && !opts.Cover.FailingTestsOk {
+			if files := test.FilesBelowCoverageThreshold(state.Coverage, minCoverage, state.Config.Cover.MinLineCoverageExcludes); len(files) > 0 {
+				log.Errorf("%d files are below the minimum line coverage of %d%%:", len(files), minCoverage)
+				for _, file := range files {
+					log.Errorf("  %s", file)
+				}
+				if success {
+					return 1
+				}
+			}
+		}
+		if !incrementalPassed && !opts.Cover.FailingTestsOk {
+			log.Errorf("Incremental coverage of %0.1f%% is below the minimum of %0.1f%%", stats.Percentage, stats.MinPercentage)
+			if success {
+				return 1
+			}
+		}
 		return toExitCode(success, state)
 	},
 	"debug": func() int {
@@ -623,7 +727,7 @@ var buildFunctions = map[string]func() int{
 				log.Fatalf("%v expanded to more than one target. If you want to run multiple targets, use `plz run parallel %v` or `plz run sequential %v`. ", opts.Run.Args.Target, opts.Run.Args.Target, opts.Run.Args.Target)
 			}
 
-			run.Run(state, annotatedOutputLabels[0], opts.Run.Args.Args.AsStrings(), opts.Run.Remote, opts.Run.Env, opts.Run.InTempDir, dir, opts.Run.Cmd)
+			run.Run(state, annotatedOutputLabels[0], opts.Run.Args.Args.AsStrings(), opts.Run.Remote, opts.Run.Env, opts.Run.InTempDir, opts.Run.Mounts, dir, opts.Run.Cmd)
 		}
 		return 1 // We should never return from run.Run so if we make it here something's wrong.
 	},
@@ -637,10 +741,14 @@ var buildFunctions = map[string]func() int{
 			if opts.Run.WD != "" {
 				dir = getAbsolutePath(opts.Run.WD, originalWorkingDirectory)
 			}
+			var pidFile string
+			if opts.Run.Parallel.PIDFile != "" {
+				pidFile = getAbsolutePath(string(opts.Run.Parallel.PIDFile), originalWorkingDirectory)
+			}
 			output := opts.Run.Parallel.Output
 			args = append(args, opts.Run.Parallel.Args.AsStrings()...)
 			annotated = state.ExpandMaybeAnnotatedLabels(annotated)
-			os.Exit(run.Parallel(context.Background(), state, annotated, args, opts.Run.Parallel.NumTasks, output, opts.Run.Remote, opts.Run.Env, opts.Run.Parallel.Detach, opts.Run.InTempDir, dir))
+			os.Exit(run.Parallel(context.Background(), state, annotated, args, opts.Run.Parallel.NumTasks, output, opts.Run.Remote, opts.Run.Env, opts.Run.Parallel.Detach, opts.Run.InTempDir, opts.Run.Mounts, dir, pidFile))
 		}
 		return 1
 	},
@@ -661,7 +769,7 @@ var buildFunctions = map[string]func() int{
 			}
 			args = append(args, opts.Run.Sequential.Args.AsStrings()...)
 			annotated = state.ExpandMaybeAnnotatedLabels(annotated)
-			os.Exit(run.Sequential(state, annotated, args, output, opts.Run.Remote, opts.Run.Env, opts.Run.InTempDir, dir))
+			os.Exit(run.Sequential(state, annotated, args, output, opts.Run.Remote, opts.Run.Env, opts.Run.InTempDir, opts.Run.Mounts, dir))
 		}
 		return 1
 	},
@@ -700,7 +808,8 @@ var buildFunctions = map[string]func() int{
 	"gc": func() int {
 		success, state := runBuild(core.WholeGraph, false, false, true)
 		if success {
-			gc.GarbageCollect(state, opts.Gc.Args.Targets, state.ExpandLabels(state.Config.Gc.Keep), state.Config.Gc.Keep, state.Config.Gc.KeepLabel,
+			keepLabels := append(slices.Clone(state.Config.Gc.KeepLabel), opts.Gc.KeepLabel...)
+			gc.GarbageCollect(state, opts.Gc.Args.Targets, state.ExpandLabels(state.Config.Gc.Keep), state.Config.Gc.Keep, keepLabels,
 				opts.Gc.Conservative, opts.Gc.TargetsOnly, opts.Gc.SrcsOnly, opts.Gc.NoPrompt, opts.Gc.DryRun, opts.Gc.Git)
 		}
 		return toExitCode(success, state)
@@ -709,7 +818,27 @@ var buildFunctions = map[string]func() int{
 		if opts.Format.Quiet && opts.Format.Write {
 			log.Fatal("Can't use both --quiet and --write at the same time")
 		}
-		if changed, err := format.Format(config, opts.Format.Args.Files.AsStrings(), opts.Format.Write, opts.Format.Quiet); err != nil {
+		files := repoRelativeFiles(opts.Format.Args.Files)
+		edits := format.Edits{}
+		if opts.Format.RemoveUnusedDeps {
+			if code := removeUnusedDeps(files, opts.Format.DryRun, edits); code != 0 || opts.Format.DryRun {
+				return code
+			}
+		}
+		if opts.Format.Imports {
+			if code := expandWildcardDeps(files, edits); code != 0 {
+				return code
+			}
+		}
+		var rulesMap string
+		if opts.Format.MigrateRulesMap != "" {
+			rulesMap = getAbsolutePath(string(opts.Format.MigrateRulesMap), originalWorkingDirectory)
+		}
+		migrations, err := format.ParseRuleMigrations(opts.Format.MigrateRules, rulesMap)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		if changed, err := format.Format(config, files, opts.Format.Write, opts.Format.Quiet, migrations, opts.Format.StripKeep, edits); err != nil {
 			log.Fatalf("Failed to reformat files: %s", err)
 		} else if changed && opts.Format.Quiet {
 			return 1
@@ -718,6 +847,12 @@ var buildFunctions = map[string]func() int{
 	},
 	"init": func() int {
 		plzinit.InitConfig(string(opts.Init.Dir), opts.Init.BazelCompatibility, opts.Init.NoPrompt)
+		if opts.Init.Template != "" {
+			// The template goes on top of the default config, so its files take precedence.
+			if err := plzinit.InitTemplate(string(opts.Init.Dir), opts.Init.Template, config.Please.PluginRepo); err != nil {
+				log.Fatalf("%s", err)
+			}
+		}
 
 		if opts.Init.NoPrompt {
 			return 0
@@ -741,6 +876,11 @@ var buildFunctions = map[string]func() int{
 		return 0
 	},
 	"init.pleasings": func() int {
+		if !opts.Init.Pleasings.NoVerify {
+			if err := plzinit.VerifyPleasingsRevision(opts.Init.Pleasings.Revision); err != nil {
+				log.Fatalf("%s", err)
+			}
+		}
 		if err := plzinit.InitPleasings(opts.Init.Pleasings.Location, opts.Init.Pleasings.PrintOnly, opts.Init.Pleasings.Revision); err != nil {
 			log.Fatalf("failed to write pleasings subrepo file: %v", err)
 		}
@@ -751,7 +891,7 @@ var buildFunctions = map[string]func() int{
 		return 0
 	},
 	"init.plugin": func() int {
-		if err := plzinit.InitPlugins(opts.Init.Plugin.Args.Plugins, opts.Init.Plugin.Version); err != nil {
+		if err := plzinit.InitPlugins(opts.Init.Plugin.Args.Plugins, opts.Init.Plugin.Version, opts.Init.Plugin.Force); err != nil {
 			log.Fatalf("%s", err)
 		}
 		return 0
@@ -777,8 +917,19 @@ var buildFunctions = map[string]func() int{
 		return runTool(opts.Tool.Args.Tool)
 	},
 	"query.deps": func() int {
+		var timings map[core.BuildLabel]time.Duration
+		if opts.Query.Deps.CriticalPath {
+			if !opts.Query.Deps.DOT || opts.Query.Deps.TimingsFile == "" {
+				log.Fatalf("--critical_path requires --dot and --timings_file")
+			}
+			t, err := output.ReadBuildTimings(getAbsolutePath(string(opts.Query.Deps.TimingsFile), originalWorkingDirectory))
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			timings = t
+		}
 		return runQuery(true, opts.Query.Deps.Args.Targets, func(state *core.BuildState) {
-			query.Deps(os.Stdout, state, state.ExpandOriginalLabels(), opts.Query.Deps.Hidden, opts.Query.Deps.Level, opts.Query.Deps.DOT)
+			query.Deps(os.Stdout, state, state.ExpandOriginalLabels(), opts.Query.Deps.Hidden, opts.Query.Deps.Level, opts.Query.Deps.DOT, timings)
 		})
 	},
 	"query.revdeps": func() int {
@@ -791,7 +942,13 @@ var buildFunctions = map[string]func() int{
 		a := plz.ReadStdinLabels([]core.BuildLabel{opts.Query.SomePath.Args.Target1})
 		b := plz.ReadStdinLabels([]core.BuildLabel{opts.Query.SomePath.Args.Target2})
 		return runQuery(true, append(a, b...), func(state *core.BuildState) {
-			if err := query.SomePath(state.Graph, a, b, opts.Query.SomePath.Except, opts.Query.SomePath.Hidden); err != nil {
+			var err error
+			if opts.Query.SomePath.AllPaths {
+				err = query.AllPaths(state.Graph, a, b, opts.Query.SomePath.Except, opts.Query.SomePath.Hidden, opts.Query.SomePath.MaxPaths)
+			} else {
+				err = query.SomePath(state.Graph, a, b, opts.Query.SomePath.Except, opts.Query.SomePath.Hidden)
+			}
+			if err != nil {
 				fmt.Printf("%s\n", err)
 				os.Exit(1)
 			}
@@ -803,8 +960,11 @@ var buildFunctions = map[string]func() int{
 		})
 	},
 	"query.print": func() int {
+		if v := opts.Query.Print.SchemaVersion; v < 0 || v > query.CurrentPrintSchemaVersion {
+			log.Fatalf("Unknown schema version %d, must be between 0 and %d", v, query.CurrentPrintSchemaVersion)
+		}
 		return runQuery(false, opts.Query.Print.Args.Targets, func(state *core.BuildState) {
-			query.Print(state, state.ExpandOriginalLabels(), opts.Query.Print.Fields, opts.Query.Print.Labels, opts.Query.Print.OmitHidden, opts.Query.Print.JSON)
+			query.Print(state, state.ExpandOriginalLabels(), opts.Query.Print.Fields, opts.Query.Print.Labels, opts.Query.Print.OmitHidden, opts.Query.Print.JSON, opts.Query.Print.SchemaVersion)
 		})
 	},
 	"query.input": func() int {
@@ -862,15 +1022,23 @@ var buildFunctions = map[string]func() int{
 	},
 	"query.graph": func() int {
 		targets := opts.Query.Graph.Args.Targets
-		return runQuery(true, targets, func(state *core.BuildState) {
+		toParse := targets
+		if opts.Query.Graph.PruneUnconnected {
+			toParse = append(slices.Clone(targets), core.WholeGraph...)
+		}
+		return runQuery(true, toParse, func(state *core.BuildState) {
 			if len(opts.Query.Graph.Args.Targets) == 0 {
 				targets = opts.Query.Graph.Args.Targets // It special-cases doing the full graph.
 			}
-			query.Graph(state, state.ExpandLabels(targets))
+			query.Graph(state, state.ExpandLabels(targets), opts.Query.Graph.PruneUnconnected, opts.Query.Graph.NDJSON)
 		})
 	},
 	"query.whatinputs": func() int {
-		files := opts.Query.WhatInputs.Args.Files.Get()
+		wd, err := filepath.Rel(core.RepoRoot, originalWorkingDirectory)
+		if err != nil {
+			log.Fatalf("Failed to make working directory relative to repo root: %s", err)
+		}
+		files := query.ExpandFileGlobs(opts.Query.WhatInputs.Args.Files.Get(), wd)
 		// Make all these relative to the repo root; many things do not work if they're absolute.
 		for i, file := range files {
 			if filepath.IsAbs(file) {
@@ -895,7 +1063,7 @@ var buildFunctions = map[string]func() int{
 	},
 	"query.whatoutputs": func() int {
 		return runQuery(true, core.WholeGraph, func(state *core.BuildState) {
-			query.WhatOutputs(state.Graph, opts.Query.WhatOutputs.Args.Files.Get(), opts.Query.WhatOutputs.EchoFiles)
+			query.WhatOutputs(state.Graph, opts.Query.WhatOutputs.Args.Files.Get(), opts.Query.WhatOutputs.EchoFiles, opts.Query.WhatOutputs.JSON)
 		})
 	},
 	"query.rules": func() int {
@@ -934,9 +1102,29 @@ var buildFunctions = map[string]func() int{
 		if len(opts.Query.Changes.Args.Files) > 0 {
 			return runInexact(opts.Query.Changes.Args.Files.Get())
 		}
+		if opts.Query.Changes.SinceFile != "" {
+			if opts.Query.Changes.Since != "" {
+				log.Fatalf("--since and --since_file can't be used together")
+			}
+			b, err := os.ReadFile(getAbsolutePath(string(opts.Query.Changes.SinceFile), originalWorkingDirectory))
+			if err != nil {
+				log.Fatalf("Failed to read revision from --since_file: %s", err)
+			}
+			line, _, _ := strings.Cut(string(b), "\n")
+			if opts.Query.Changes.Since = strings.TrimSpace(line); opts.Query.Changes.Since == "" {
+				log.Fatalf("--since_file %s doesn't contain a revision on its first line", opts.Query.Changes.SinceFile)
+			}
+		} else if opts.Query.Changes.Since == "" {
+			// This isn't a default on the flag so we can tell whether it was given alongside --since_file.
+			opts.Query.Changes.Since = "origin/master"
+		}
 		scm := scm.MustNew(core.RepoRoot)
 		if opts.Query.Changes.In != "" {
-			return runInexact(scm.ChangesIn(opts.Query.Changes.In, ""))
+			files := scm.ChangesIn(opts.Query.Changes.In, "")
+			if len(files) > maxChangedFilesForDependees && level != 0 {
+				log.Warning("%s contains %d changed files; finding their dependees may be very slow. Consider passing --level=0.", opts.Query.Changes.In, len(files))
+			}
+			return runInexact(files)
 		} else if opts.Query.Changes.Inexact {
 			return runInexact(scm.ChangedFiles(opts.Query.Changes.Since, true, ""))
 		}
@@ -965,7 +1153,7 @@ var buildFunctions = map[string]func() int{
 	},
 	"query.filter": func() int {
 		return runQuery(false, opts.Query.Filter.Args.Targets, func(state *core.BuildState) {
-			query.Filter(state, state.ExpandOriginalLabels(), opts.Query.Filter.Hidden)
+			query.Filter(state, state.ExpandOriginalLabels(), opts.Query.Filter.Hidden, opts.Query.Filter.ByType)
 		})
 	},
 	"query.reporoot": func() int {
@@ -973,7 +1161,9 @@ var buildFunctions = map[string]func() int{
 		return 0
 	},
 	"query.config": func() int {
-		if opts.Query.Config.JSON {
+		if opts.Query.Config.Schema {
+			query.ConfigSchema()
+		} else if opts.Query.Config.JSON {
 			if len(opts.Query.Config.Args.Options) > 0 {
 				log.Fatal("The --option flag isn't available with the --json flag")
 			}
@@ -988,7 +1178,7 @@ var buildFunctions = map[string]func() int{
 		// Don't ask it to test now since we don't know if any of them are tests yet.
 		success, state := runBuild(targets, true, false, false)
 		state.NeedRun = opts.Watch.Run
-		watch.Watch(state, state.ExpandOriginalLabels(), args, opts.Watch.NoTest, runPlease)
+		watch.Watch(state, state.ExpandOriginalLabels(), args, opts.Watch.NoTest, opts.Watch.Exec, originalWorkingDirectory, runPlease)
 		return toExitCode(success, state)
 	},
 	"generate": func() int {
@@ -1029,6 +1219,25 @@ var buildFunctions = map[string]func() int{
 		return 1
 	},
 	"sandbox": func() int {
+		if len(os.Args) > 2 && os.Args[2] == "--" {
+			// A user wants to run something in the sandbox, as opposed to us re-executing ourselves.
+			cli.InitLogging(cli.MinVerbosity)
+			config := core.DefaultConfiguration()
+			if core.FindRepoRoot() {
+				if cfg, err := core.ReadDefaultConfigFiles(fs.HostFS, nil); err == nil {
+					config = cfg
+				}
+			}
+			if _, err := core.LookBuildPath(config.Sandbox.Tool, config); err != nil {
+				config.Sandbox.Tool = "" // Fall back to the built-in sandbox.
+			}
+			config.Sandbox.Build = true
+			code, err := sandbox.Run(core.NewBuildState(config).ProcessExecutor, os.Args[3:])
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			return code
+		}
 		if err := sandbox.Sandbox(os.Args[2:]); err != nil {
 			log.Fatal(err)
 		}
@@ -1055,12 +1264,58 @@ func runTool(_tool tool.Tool) int {
 	config = mustReadConfigAndSetRoot(false)
 	if success, state := runBuild(label, true, false, false); success {
 		annotatedOutputLabels := core.AnnotateLabels(label)
-		run.Run(state, annotatedOutputLabels[0], opts.Tool.Args.Args.AsStrings(), false, false, false, "", "")
+		run.Run(state, annotatedOutputLabels[0], opts.Tool.Args.Args.AsStrings(), false, false, false, nil, "", "")
 	}
 	// If all went well, we shouldn't get here.
 	return 1
 }
 
+// removeUnusedDeps parses the packages containing the given BUILD files (or the whole repo if none are given)
+// and removes any unused deps from them.
+// removeUnusedDeps removes unused deps from rules in the given BUILD files.
+// The changes are added to the given edits, to be written (or not) by format.Format.
+func removeUnusedDeps(files []string, dryRun bool, edits format.Edits) int {
+	labels := make([]core.BuildLabel, 0, len(files))
+	for _, file := range files {
+		pkg := filepath.Dir(file)
+		if pkg == "." {
+			pkg = ""
+		}
+		labels = append(labels, core.BuildLabel{PackageName: pkg, Name: "all"})
+	}
+	return runQuery(true, labels, func(state *core.BuildState) {
+		if len(files) == 0 {
+			for file := range plz.FindAllBuildFiles(config, core.RepoRoot, "") {
+				files = append(files, file)
+			}
+		}
+		if _, err := format.RemoveUnusedDeps(state, files, dryRun, edits); err != nil {
+			log.Fatalf("Failed to remove unused deps: %s", err)
+		}
+	})
+}
+
+// expandWildcardDeps expands wildcards in the deps of rules in the given BUILD files.
+// The changes are added to the given edits, to be written (or not) by format.Format.
+func expandWildcardDeps(files []string, edits format.Edits) int {
+	if len(files) == 0 {
+		for file := range plz.FindAllBuildFiles(config, core.RepoRoot, "") {
+			files = append(files, file)
+		}
+	}
+	labels, err := format.WildcardDeps(config, files, edits)
+	if err != nil {
+		log.Fatalf("Failed to read BUILD files: %s", err)
+	} else if len(labels) == 0 {
+		return 0
+	}
+	return runQuery(true, labels, func(state *core.BuildState) {
+		if _, err := format.ExpandWildcardDeps(state, files, edits); err != nil {
+			log.Fatalf("Failed to expand wildcard deps: %s", err)
+		}
+	})
+}
+
 // ConfigOverrides are used to implement completion on the -o flag.
 type ConfigOverrides map[string]string
 
@@ -1069,6 +1324,20 @@ func (overrides ConfigOverrides) Complete(match string) []flags.Completion {
 	return core.DefaultConfiguration().Completions(match)
 }
 
+// repoRelativeFiles returns the given files, which are relative to the original working directory,
+// relative to the repo root instead.
+func repoRelativeFiles(files cli.Filepaths) []string {
+	ret := make([]string, len(files))
+	for i, file := range files {
+		rel, err := filepath.Rel(core.RepoRoot, getAbsolutePath(string(file), originalWorkingDirectory))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			log.Fatalf("%s does not lie within this repo", file)
+		}
+		ret[i] = rel
+	}
+	return ret
+}
+
 // Get an absolute path from a relative path.
 func getAbsolutePath(path string, here string) string {
 	if filepath.IsAbs(path) {
@@ -1126,6 +1395,7 @@ func Please(targets []core.BuildLabel, config *core.Configuration, shouldBuild,
 	}
 	state := core.NewBuildState(config)
 	state.KeepGoing = opts.BehaviorFlags.KeepGoing
+	state.MaxErrors = opts.BehaviorFlags.MaxErrors
 	state.VerifyHashes = !opts.BehaviorFlags.NoHashVerification
 	// Only one of these two can be passed
 	state.NumTestRuns = uint16(opts.Test.NumRuns)
@@ -1134,6 +1404,18 @@ func Please(targets []core.BuildLabel, config *core.Configuration, shouldBuild,
 	}
 	state.TestSequentially = opts.Test.Sequentially || opts.Cover.Sequentially // Similarly here.
 	state.TestArgs = opts.Test.StateArgs
+	state.TestShardCount = opts.Test.ShardCount + opts.Cover.ShardCount // Similarly, only one of these can be passed.
+	state.TestShardIndex = opts.Test.ShardIndex + opts.Cover.ShardIndex
+	if state.TestShardCount < 0 || state.TestShardIndex < 0 || (state.TestShardIndex > 0 && state.TestShardIndex >= state.TestShardCount) {
+		log.Fatalf("Invalid test shard %d of %d; --shard_index must be between 0 and --shard_count - 1", state.TestShardIndex, state.TestShardCount)
+	}
+	if envFile := opts.Test.EnvFile + opts.Cover.EnvFile; envFile != "" { // Similarly, only one of these can be passed.
+		env, err := core.ReadEnvFile(getAbsolutePath(string(envFile), originalWorkingDirectory))
+		if err != nil {
+			log.Fatalf("Failed to read test env file: %s", err)
+		}
+		state.TestEnv = env
+	}
 	state.NeedCoverage = opts.Cover.active
 	state.NeedBuild = shouldBuild
 	state.NeedTests = shouldTest
@@ -1142,12 +1424,17 @@ func Please(targets []core.BuildLabel, config *core.Configuration, shouldBuild,
 	state.PrepareOnly = opts.Build.Shell != "" || opts.Test.Shell != "" || opts.Cover.Shell != ""
 	state.Watch = !opts.Watch.Args.Target.IsEmpty()
 	state.CleanWorkdirs = !opts.BehaviorFlags.KeepWorkdirs
+	state.KeepSandbox = opts.BehaviorFlags.KeepSandbox
+	state.Reproduce = opts.Build.Reproduce
 	state.ForceRebuild = opts.Build.Rebuild || opts.Run.Rebuild
 	state.ForceRerun = opts.Test.Rerun || opts.Cover.Rerun
 	state.ShowTestOutput = opts.Test.ShowOutput || opts.Cover.ShowOutput
+	state.ShowTestOutputOnFailure = opts.Test.OutputOnFailure || opts.Cover.OutputOnFailure
 	state.DebugPort = opts.Debug.Port
 	state.DebugFailingTests = debugFailingTests
 	state.ShowAllOutput = opts.OutputFlags.ShowAllOutput
+	state.ShowBuildWarnings = opts.OutputFlags.Warnings != "never"
+	state.ReplayBuildWarnings = opts.OutputFlags.Warnings == "always"
 	state.ParsePackageOnly = opts.ParsePackageOnly
 	state.EnableBreakpoints = opts.BehaviorFlags.Debug
 
@@ -1170,6 +1457,8 @@ func Please(targets []core.BuildLabel, config *core.Configuration, shouldBuild,
 
 	if opts.Run.InTempDir && opts.Run.WD != "" {
 		log.Fatal("Can't use both --in_temp_dir and --wd at the same time")
+	} else if len(opts.Run.Mounts) > 0 && !opts.Run.InTempDir {
+		log.Fatal("--mount can only be used with --in_tmp_dir")
 	}
 
 	runPlease(state, targets)
@@ -1202,13 +1491,21 @@ func runPlease(state *core.BuildState, targets []core.BuildLabel) {
 
 	// Run the display
 	state.Results() // important this is called now, don't ask...
+	var timingsFile string
+	if opts.OutputFlags.BuildTimingsFile != "" {
+		timingsFile = getAbsolutePath(string(opts.OutputFlags.BuildTimingsFile), originalWorkingDirectory)
+	}
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
-		output.MonitorState(state, !pretty, detailedTests, streamTests, shell, shellRun, string(opts.OutputFlags.TraceFile))
+		output.MonitorState(state, !pretty, detailedTests, streamTests, shell, shellRun, string(opts.OutputFlags.TraceFile), timingsFile)
 		wg.Done()
 	}()
-	plz.Run(targets, opts.BuildFlags.PreTargets, state, config, state.TargetArch)
+	preTargets := opts.BuildFlags.PreTargets
+	if state.NeedBuild {
+		preTargets = slices.Concat(state.Config.Build.PreTargets, preTargets)
+	}
+	plz.Run(targets, preTargets, state, config, state.TargetArch)
 	wg.Wait()
 }
 
@@ -1319,6 +1616,40 @@ func runBuild(targets []core.BuildLabel, shouldBuild, shouldTest, isQuery bool)
 	return Please(targets, config, shouldBuild, shouldTest)
 }
 
+// buildConfigMatrix builds the given targets once in each of the given comma-separated build configs and
+// prints a summary of which ones succeeded. It returns the worst exit code of any of them.
+func buildConfigMatrix(targets []core.BuildLabel, matrix string) int {
+	var configs []string
+	for _, c := range strings.Split(matrix, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			configs = append(configs, c)
+		}
+	}
+	if len(configs) == 0 {
+		log.Fatalf("--config_matrix must name at least one build config")
+	}
+	// runBuild adds the manual excludes each time it's called, so reset them for each build.
+	exclude := slices.Clone(opts.BuildFlags.Exclude)
+	exitCodes := make([]int, len(configs))
+	for i, c := range configs {
+		log.Notice("Building in %s config...", c)
+		opts.BuildFlags.Config = c
+		opts.BuildFlags.Exclude = slices.Clone(exclude)
+		exitCodes[i] = toExitCode(runBuild(targets, true, false, false))
+	}
+	ret := 0
+	fmt.Printf("Results by build config:\n")
+	for i, c := range configs {
+		if exitCodes[i] == 0 {
+			fmt.Printf("  %s: succeeded\n", c)
+		} else {
+			fmt.Printf("  %s: failed\n", c)
+		}
+		ret = max(ret, exitCodes[i])
+	}
+	return ret
+}
+
 var originalWorkingDirectory string
 
 // readConfigAndSetRoot returns an error if we can't find a repo root
@@ -1441,6 +1772,9 @@ func initBuild(args []string) string {
 	if opts.OutputFlags.ShowAllOutput {
 		opts.OutputFlags.PlainOutput = true
 	}
+	if opts.BuildFlags.NumThreads == 0 {
+		opts.BuildFlags.NumThreads = opts.BuildFlags.Jobs
+	}
 	// Init logging, but don't do file output until we've chdir'd.
 	cli.InitLogging(opts.OutputFlags.Verbosity)
 	if _, present := os.LookupEnv("SUDO_COMMAND"); present && !opts.BehaviorFlags.AllowSudo {
@@ -1,6 +1,8 @@
 package plzinit
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -32,3 +34,38 @@ func TestInitPleasings(t *testing.T) {
 
 	assert.Equal(t, expectedRule, string(b))
 }
+
+func TestPluginConflicts(t *testing.T) {
+	assert.Empty(t, pluginConflicts([]string{"go", "python", "java"}))
+	assert.Equal(t, []string{
+		"Plugins go-proto and go_proto would both preload ///go_proto//build_defs:go_proto",
+	}, pluginConflicts([]string{"go-proto", "python", "go_proto"}))
+}
+
+func TestVerifyPleasingsRevision(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/commits/v1.2.0":
+			w.Write([]byte(`{"sha": "abcdef"}`))
+		case "/releases/latest":
+			w.Write([]byte(`{"tag_name": "v1.3.0"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	pleasingsAPIURL = srv.URL
+
+	assert.NoError(t, VerifyPleasingsRevision(""))
+	assert.NoError(t, VerifyPleasingsRevision("v1.2.0"))
+	err := VerifyPleasingsRevision("v9.9.9")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "v1.3.0")
+}
+
+func TestVerifyPleasingsRevisionNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	pleasingsAPIURL = srv.URL
+	srv.Close()
+	assert.NoError(t, VerifyPleasingsRevision("v1.2.0"))
+}
@@ -1,9 +1,13 @@
 package plzinit
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/thought-machine/please/src/core"
 )
@@ -35,3 +39,62 @@ func InitPleasings(location string, printOnly bool, revision string) error {
 	_, err = fmt.Fprintf(f, pleasingsSubrepoTemplate, revision)
 	return err
 }
+
+// pleasingsAPIURL is the GitHub API URL for the pleasings repo. Overridden in tests.
+var pleasingsAPIURL = "https://api.github.com/repos/thought-machine/pleasings"
+
+// VerifyPleasingsRevision checks that the given revision exists in the pleasings repo.
+// If it doesn't, the returned error suggests the latest release instead. Failing to talk to GitHub
+// at all is only logged as a warning, so this still works for anyone without access to it.
+func VerifyPleasingsRevision(revision string) error {
+	if revision == "" {
+		return nil // We default to master, which certainly exists.
+	}
+	resp, err := githubGet(pleasingsAPIURL + "/commits/" + url.PathEscape(revision))
+	if err != nil {
+		log.Warning("Couldn't verify pleasings revision %s: %s", revision, err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	} else if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusUnprocessableEntity {
+		log.Warning("Couldn't verify pleasings revision %s: %s", revision, resp.Status)
+		return nil
+	}
+	if tag, err := latestPleasingsRelease(); err != nil {
+		log.Warning("Couldn't find latest pleasings release: %s", err)
+	} else if tag != "" {
+		return fmt.Errorf("revision %s doesn't exist in the pleasings repo; the latest release is %s", revision, tag)
+	}
+	return fmt.Errorf("revision %s doesn't exist in the pleasings repo", revision)
+}
+
+// latestPleasingsRelease returns the tag of the latest release of the pleasings repo.
+func latestPleasingsRelease() (string, error) {
+	resp, err := githubGet(pleasingsAPIURL + "/releases/latest")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", err
+	}
+	return release.TagName, nil
+}
+
+func githubGet(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/vnd.github.v3+json")
+	client := &http.Client{Timeout: 10 * time.Second}
+	return client.Do(req)
+}
@@ -94,9 +94,19 @@ func info(format string, args ...interface{}) {
 
 // InitPlugins initialises one or more plugins by inserting plugin config values into
 // the host repo config file, and creating a build target in //plugins.
-func InitPlugins(plugins []string, version string) error {
+// It fails without changing anything if the plugins conflict with one another, unless force is true.
+func InitPlugins(plugins []string, version string, force bool) error {
 	log.Debug("Initialising plugin(s): %v", plugins)
 
+	if conflicts := pluginConflicts(plugins); len(conflicts) > 0 {
+		for _, conflict := range conflicts {
+			info("%s", conflict)
+		}
+		if !force {
+			return fmt.Errorf("Conflicting plugins given; pass --force to install them anyway")
+		}
+	}
+
 	// Check that we're in a plz repo
 	configPath := filepath.Join(core.RepoRoot, ".plzconfig")
 	if !fs.FileExists(configPath) {
@@ -123,6 +133,30 @@ func InitPlugins(plugins []string, version string) error {
 	return ast.Write(file, configPath)
 }
 
+// pluginConflicts returns a description of each pair of plugins that would preload the same build defs.
+func pluginConflicts(plugins []string) []string {
+	var conflicts []string
+	seen := map[string]string{}
+	for _, p := range plugins {
+		subinclude := pluginSubinclude(p)
+		if existing, present := seen[subinclude]; present {
+			conflicts = append(conflicts, fmt.Sprintf("Plugins %s and %s would both preload %s", existing, p, subinclude))
+			continue
+		}
+		seen[subinclude] = p
+	}
+	return conflicts
+}
+
+// pluginSubinclude returns the label of the build defs that we preload for a plugin.
+// TODO(sam): We can get the actual name of the package containing the build_defs
+// if we build the plugin target, which we do below. Refactor this to build the target
+// earlier and use the build_defs dir specified in the plugin config
+func pluginSubinclude(plugin string) string {
+	pluginName := strings.ReplaceAll(plugin, "-", "_")
+	return "///" + pluginName + "//build_defs:" + pluginName
+}
+
 // initPlugin initialises the plugin, performing any plugin specific operations, returning the plugin config
 func initPlugin(plugin, version string) (map[string]string, error) {
 	if err := createPluginTarget("plugins/BUILD", plugin, version); err != nil {
@@ -147,11 +181,7 @@ func writeFieldsToConfig(plugin string, plzConfig ast.File, pluginConfig map[str
 	}
 
 	// Inject the preloadsubincludes
-	// TODO(sam): We can get the actual name of the package containing the build_defs
-	// if we build the plugin target, which we do below. Refactor this to build the target
-	// earlier and use the build_defs dir specified in the plugin config
-	subincludeStr := "///" + pluginName + "//build_defs:" + pluginName
-	plzConfig = ast.InjectField(plzConfig, "preloadsubincludes", subincludeStr, "parse", "", true)
+	plzConfig = ast.InjectField(plzConfig, "preloadsubincludes", pluginSubinclude(plugin), "parse", "", true)
 
 	// Write plugin target value
 	plzConfig = ast.InjectField(plzConfig, "Target", "//plugins:"+pluginName, section, pluginName, false)
@@ -0,0 +1,153 @@
+package plzinit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/fs"
+)
+
+// InitTemplate downloads a repo template and extracts it into the given directory, overwriting any
+// files already there. The template can either be the URL of a .zip or .tar.gz archive, or a name,
+// which is looked up as <name>-template in the please-build organisation using the plugin repo URLs.
+func InitTemplate(dir, template string, pluginRepos []string) error {
+	urls := templateURLs(template, pluginRepos)
+	var errs []string
+	for _, url := range urls {
+		b, err := download(url)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := extractTemplate(b, url, dir); err != nil {
+			return fmt.Errorf("Failed to extract template from %s: %w", url, err)
+		}
+		fmt.Printf("Extracted template from %s\n", url)
+		return nil
+	}
+	return fmt.Errorf("Failed to download template %s:\n%s", template, strings.Join(errs, "\n"))
+}
+
+// templateURLs returns the URLs to try to download a template from.
+func templateURLs(template string, pluginRepos []string) []string {
+	if strings.Contains(template, "://") {
+		return []string{template}
+	}
+	if len(pluginRepos) == 0 {
+		pluginRepos = core.DefaultPluginRepos
+	}
+	owner, name, found := strings.Cut(template, "/")
+	if !found {
+		owner, name = "please-build", template
+	}
+	urls := make([]string, len(pluginRepos))
+	for i, repo := range pluginRepos {
+		urls[i] = strings.NewReplacer("{owner}", owner, "{plugin}", name+"-template", "{revision}", "master").Replace(repo)
+	}
+	return urls
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// extractTemplate extracts a template archive into the given directory.
+// If everything in the archive is in a single top-level directory (as it is for archives
+// downloaded from GitHub), that directory is stripped.
+func extractTemplate(b []byte, url, dir string) error {
+	files := map[string][]byte{}
+	modes := map[string]os.FileMode{}
+	add := func(name string, mode os.FileMode, r io.Reader) error {
+		name = path.Clean(name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("archive contains a path outside its root: %s", name)
+		}
+		contents, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		files[name] = contents
+		modes[name] = mode.Perm()
+		return nil
+	}
+	if strings.HasSuffix(url, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = add(f.Name, f.Mode(), r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		gzr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		tr := tar.NewReader(gzr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			} else if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := add(hdr.Name, hdr.FileInfo().Mode(), tr); err != nil {
+				return err
+			}
+		}
+	}
+	prefix := commonPrefix(files)
+	for name, contents := range files {
+		filename := filepath.Join(dir, strings.TrimPrefix(name, prefix))
+		if err := fs.EnsureDir(filename); err != nil {
+			return err
+		} else if err := os.WriteFile(filename, contents, modes[name]|0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commonPrefix returns the single top-level directory that all the given files are in, if there is one.
+func commonPrefix(files map[string][]byte) string {
+	prefix := ""
+	for name := range files {
+		dir, _, found := strings.Cut(name, "/")
+		if !found || (prefix != "" && prefix != dir+"/") {
+			return ""
+		}
+		prefix = dir + "/"
+	}
+	return prefix
+}
@@ -0,0 +1,95 @@
+package plzinit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateURLs(t *testing.T) {
+	repos := []string{"https://github.com/{owner}/{plugin}/archive/{revision}.zip"}
+	assert.Equal(t, []string{"https://github.com/please-build/go-service-template/archive/master.zip"}, templateURLs("go-service", repos))
+	assert.Equal(t, []string{"https://github.com/someone/go-service-template/archive/master.zip"}, templateURLs("someone/go-service", repos))
+	assert.Equal(t, []string{"https://example.com/template.tar.gz"}, templateURLs("https://example.com/template.tar.gz", repos))
+}
+
+func TestInitTemplateZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range map[string]string{
+		"go-service-template-master/.plzconfig":    "[please]\nversion = 17.0.0\n",
+		"go-service-template-master/src/BUILD.plz": "go_binary(name = 'main')\n",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, InitTemplate(dir, server.URL+"/template.zip", nil))
+	b, err := os.ReadFile(filepath.Join(dir, ".plzconfig"))
+	require.NoError(t, err)
+	assert.Equal(t, "[please]\nversion = 17.0.0\n", string(b))
+	b, err = os.ReadFile(filepath.Join(dir, "src/BUILD.plz"))
+	require.NoError(t, err)
+	assert.Equal(t, "go_binary(name = 'main')\n", string(b))
+}
+
+func TestInitTemplateTarball(t *testing.T) {
+	dir := t.TempDir()
+	err := extractTemplate(tarball(t, map[string]string{
+		"pleasew":        "#!/bin/sh\n",
+		"src/main/BUILD": "",
+	}), "https://example.com/template.tar.gz", dir)
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, "pleasew"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	assert.FileExists(t, filepath.Join(dir, "src/main/BUILD"))
+}
+
+func TestInitTemplateOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	err := extractTemplate(tarball(t, map[string]string{
+		"BUILD":           "",
+		"../escaped_file": "oops",
+	}), "https://example.com/template.tar.gz", dir)
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "BUILD"))
+	assert.NoFileExists(t, filepath.Join(dir, "../escaped_file"))
+}
+
+func tarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+func TestCommonPrefix(t *testing.T) {
+	assert.Equal(t, "repo-master/", commonPrefix(map[string][]byte{"repo-master/a": nil, "repo-master/b/c": nil}))
+	assert.Equal(t, "", commonPrefix(map[string][]byte{"repo-master/a": nil, "b/c": nil}))
+	assert.Equal(t, "", commonPrefix(map[string][]byte{"a": nil}))
+}
@@ -87,6 +87,11 @@ type Target interface {
 // If showOutput is true then output will be printed to stderr as well as returned.
 // It returns the stdout only, combined stdout and stderr and any error that occurred.
 func (e *Executor) ExecWithTimeout(ctx context.Context, target Target, dir string, env []string, timeout time.Duration, showOutput, attachStdin, attachStdout, foreground bool, sandbox SandboxConfig, argv []string) ([]byte, []byte, error) {
+	return e.execWithTimeout(ctx, target, dir, env, timeout, showOutput, attachStdin, attachStdout, foreground, sandbox, argv, nil)
+}
+
+// execWithTimeout implements ExecWithTimeout. If stderr is non-nil, the command's stderr is also written to it.
+func (e *Executor) execWithTimeout(ctx context.Context, target Target, dir string, env []string, timeout time.Duration, showOutput, attachStdin, attachStdout, foreground bool, sandbox SandboxConfig, argv []string, stderr io.Writer) ([]byte, []byte, error) {
 	// We deliberately don't attach this context to the command, so we have better
 	// control over how the process gets terminated.
 	ctx, cancel := context.WithTimeout(ctx, timeout)
@@ -105,6 +110,9 @@ func (e *Executor) ExecWithTimeout(ctx context.Context, target Target, dir strin
 		cmd.Stdout = io.MultiWriter(&out, &outerr)
 		cmd.Stderr = &outerr
 	}
+	if stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+	}
 	if target != nil && target.ShouldShowProgress() {
 		progress = new(float32)
 		cmd.Stdout = newProgressWriter(target, progress, cmd.Stdout)
@@ -159,6 +167,15 @@ func (e *Executor) ExecWithTimeoutShellStdStreams(target Target, dir string, env
 	return e.ExecWithTimeout(context.Background(), target, dir, env, timeout, showOutput, attachStdStreams, attachStdStreams, foreground, sandbox, c)
 }
 
+// ExecWithTimeoutShellStderr is as ExecWithTimeoutShell but also returns the stderr of the command on its own.
+// It returns the stdout, stderr, combined stdout and stderr and any error that occurred.
+func (e *Executor) ExecWithTimeoutShellStderr(target Target, dir string, env []string, timeout time.Duration, showOutput, foreground bool, sandbox SandboxConfig, cmd string) ([]byte, []byte, []byte, error) {
+	var stderr bytes.Buffer
+	c := BashCommand("bash", cmd, target.ShouldExitOnError())
+	out, combined, err := e.execWithTimeout(context.Background(), target, dir, env, timeout, showOutput, false, false, foreground, sandbox, c, &stderr)
+	return out, stderr.Bytes(), combined, err
+}
+
 // KillProcess kills a process, attempting to send it a SIGTERM first followed by a SIGKILL
 // shortly after if it hasn't exited.
 func (e *Executor) KillProcess(cmd *exec.Cmd) {
@@ -143,6 +143,15 @@ func TestChangesIncludesRootTarget(t *testing.T) {
 	assert.EqualValues(t, []core.BuildLabel{t1.Label}, Changes(s, []string{"file.go"}, 0, false))
 }
 
+func TestChangesReportsEachTargetOnce(t *testing.T) {
+	s := core.NewDefaultBuildState()
+	t1 := addTarget(s, "//src/core:core", nil, "src/core/core.go", "src/core/state.go", "src/core/graph.go")
+	t2 := addTarget(s, "//src/query:changes", t1, "src/query/changes.go", "src/query/deps.go")
+	files := []string{"src/core/core.go", "src/query/changes.go", "src/core/state.go", "src/query/deps.go", "src/core/graph.go"}
+	assert.EqualValues(t, []core.BuildLabel{t1.Label, t2.Label}, Changes(s, files, 0, false))
+	assert.EqualValues(t, []core.BuildLabel{t1.Label, t2.Label}, Changes(s, files, -1, false))
+}
+
 func addTarget(state *core.BuildState, label string, dep *core.BuildTarget, sources ...string) *core.BuildTarget {
 	t := core.NewBuildTarget(core.ParseBuildLabel(label, ""))
 	for _, src := range sources {
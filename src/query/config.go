@@ -45,6 +45,9 @@ func ConfigJSON(config *core.Configuration) {
 	if err != nil {
 		log.Fatalf("Failed to get JSON configuration: %s", err)
 	}
+	// Add a reference to the schema as the first field (we know data is an object so begins with a brace).
+	schema, _ := json.Marshal(core.ConfigSchemaURL)
+	data = append([]byte(`{"$schema":`+string(schema)+","), data[1:]...)
 
 	var out bytes.Buffer
 	if err := json.Indent(&out, data, "", "    "); err != nil {
@@ -54,6 +57,15 @@ func ConfigJSON(config *core.Configuration) {
 	fmt.Print(out.String())
 }
 
+// ConfigSchema prints the JSON schema describing the output of ConfigJSON.
+func ConfigSchema() {
+	data, err := json.MarshalIndent(core.ConfigSchema(), "", "    ")
+	if err != nil {
+		log.Fatalf("Failed to generate JSON schema: %s", err)
+	}
+	fmt.Println(string(data))
+}
+
 func parseOption(option string) (section, subsection, name string, err error) {
 	parts := strings.Split(option, ".")
 	if len(parts) < 2 || len(parts) > 3 {
@@ -0,0 +1,88 @@
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+// A criticalPath is the longest path, weighted by build time, from each of a set of targets down
+// through their dependencies. It's the chain of targets that bounds how quickly they can be built.
+type criticalPath struct {
+	timings map[core.BuildLabel]time.Duration
+	costs   map[*core.BuildTarget]time.Duration
+	next    map[*core.BuildTarget]*core.BuildTarget
+	onPath  map[*core.BuildTarget]bool
+	hidden  bool
+}
+
+// newCriticalPath calculates the critical path from each of the given targets, using the given build timings.
+// Targets without a timing are assumed to take no time.
+// hidden is true if hidden targets are being printed; if not, the path passes through them but edges are
+// collapsed over them in the same way deps does.
+func newCriticalPath(state *core.BuildState, roots []*core.BuildTarget, timings map[core.BuildLabel]time.Duration, hidden bool) *criticalPath {
+	cp := &criticalPath{
+		timings: timings,
+		costs:   map[*core.BuildTarget]time.Duration{},
+		next:    map[*core.BuildTarget]*core.BuildTarget{},
+		onPath:  map[*core.BuildTarget]bool{},
+		hidden:  hidden,
+	}
+	for _, root := range roots {
+		cp.cost(state, root)
+		for target := root; target != nil; target = cp.next[target] {
+			cp.onPath[target] = true
+		}
+	}
+	return cp
+}
+
+// cost returns the total build time of the longest path from the given target through its dependencies.
+func (cp *criticalPath) cost(state *core.BuildState, target *core.BuildTarget) time.Duration {
+	if cost, present := cp.costs[target]; present {
+		return cost
+	}
+	var longest time.Duration
+	for _, declared := range target.DeclaredDependencies() {
+		dep := state.Graph.TargetOrDie(declared)
+		if !state.ShouldInclude(dep) {
+			continue
+		}
+		for _, l := range dep.ProvideFor(target) {
+			dep := state.Graph.TargetOrDie(l)
+			if cost := cp.cost(state, dep); cp.next[target] == nil || cost > longest {
+				longest = cost
+				cp.next[target] = dep
+			}
+		}
+	}
+	cp.costs[target] = cp.timings[target.Label] + longest
+	return cp.costs[target]
+}
+
+// contains returns true if the given target is on the critical path.
+func (cp *criticalPath) contains(target *core.BuildTarget) bool {
+	return cp != nil && cp.onPath[target]
+}
+
+// containsEdge returns true if the edge from parent to target is on the critical path.
+// If we aren't printing hidden targets, the edge may pass through any number of them on the way.
+func (cp *criticalPath) containsEdge(parent, target *core.BuildTarget) bool {
+	if !cp.contains(parent) {
+		return false
+	}
+	for next := cp.next[parent]; next != nil; next = cp.next[next] {
+		if next == target {
+			return true
+		} else if cp.hidden || !next.HasParent() {
+			return false
+		}
+	}
+	return false
+}
+
+// dotAttrs returns the attributes to draw a target on the critical path with.
+func (cp *criticalPath) dotAttrs(target *core.BuildTarget) string {
+	return fmt.Sprintf(`color=red label="%s\n%s"`, target, cp.timings[target.Label].Round(time.Millisecond))
+}
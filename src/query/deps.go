@@ -4,22 +4,38 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/thought-machine/please/src/core"
 )
 
 // Deps prints all transitive dependencies of a set of targets.
-func Deps(out io.Writer, state *core.BuildState, labels []core.BuildLabel, hidden bool, targetLevel int, formatdot bool) {
+// If timings are given, the critical path through the dependencies is highlighted in the dot output.
+func Deps(out io.Writer, state *core.BuildState, labels []core.BuildLabel, hidden bool, targetLevel int, formatdot bool, timings map[core.BuildLabel]time.Duration) {
+	var cp *criticalPath
+	if formatdot && timings != nil {
+		roots := make([]*core.BuildTarget, len(labels))
+		for i, label := range labels {
+			roots[i] = state.Graph.TargetOrDie(label)
+		}
+		cp = newCriticalPath(state, roots, timings, hidden)
+	}
 	if formatdot {
 		fmt.Fprintf(out, "digraph deps {\n")
 		fmt.Fprintf(out, "  fontname=\"Helvetica,Arial,sans-serif\"\n")
 		fmt.Fprintf(out, "  node [fontname=\"Helvetica,Arial,sans-serif\"]\n")
 		fmt.Fprintf(out, "  edge [fontname=\"Helvetica,Arial,sans-serif\"]\n")
 		fmt.Fprintf(out, "  rankdir=\"LR\"\n")
+		for _, label := range labels {
+			if target := state.Graph.TargetOrDie(label); cp.contains(target) {
+				fmt.Fprintf(out, "  \"%s\" [%s];\n", target, cp.dotAttrs(target))
+			}
+		}
 	}
 	done := map[*core.BuildTarget]bool{}
 	for _, label := range labels {
-		deps(out, state, state.Graph.TargetOrDie(label), done, targetLevel, 0, hidden, formatdot)
+		target := state.Graph.TargetOrDie(label)
+		deps(out, state, target, target, done, targetLevel, 0, hidden, formatdot, cp)
 	}
 	if formatdot {
 		fmt.Fprintf(out, "}\n")
@@ -27,12 +43,14 @@ func Deps(out io.Writer, state *core.BuildState, labels []core.BuildLabel, hidde
 }
 
 // deps looks at all the deps of the given target & recurses into them, printing as appropriate.
-func deps(out io.Writer, state *core.BuildState, target *core.BuildTarget, done map[*core.BuildTarget]bool, targetLevel, currentLevel int, hidden, formatdot bool) {
+// parent is the target that edges are drawn from in the dot output; it's the target itself unless that's hidden
+// and we aren't printing hidden targets, in which case edges are collapsed onto the nearest visible ancestor.
+func deps(out io.Writer, state *core.BuildState, target, parent *core.BuildTarget, done map[*core.BuildTarget]bool, targetLevel, currentLevel int, hidden, formatdot bool, cp *criticalPath) {
 	if currentLevel == targetLevel {
 		return
 	}
-	for _, l := range target.DeclaredDependencies() {
-		dep := state.Graph.TargetOrDie(l)
+	for _, declared := range target.DeclaredDependencies() {
+		dep := state.Graph.TargetOrDie(declared)
 		if !state.ShouldInclude(dep) || done[dep] {
 			continue // target is filtered out
 		}
@@ -41,16 +59,16 @@ func deps(out io.Writer, state *core.BuildState, target *core.BuildTarget, done
 			if dep := state.Graph.TargetOrDie(l); hidden || !dep.HasParent() {
 				// dep is to be printed; either we're printing hidden deps or it has no parent (i.e. is not hidden)
 				if formatdot {
-					printTargetDot(out, dep, target)
+					printTargetDot(out, state, dep, parent, dependencyType(target, declared), cp)
 				} else {
 					printTarget(out, dep, currentLevel)
 				}
-				deps(out, state, dep, done, targetLevel, currentLevel+1, hidden, formatdot)
+				deps(out, state, dep, dep, done, targetLevel, currentLevel+1, hidden, formatdot, cp)
 			} else if dep.Label.Parent() == target.Label.Parent() {
 				// This is a hidden dependency of the current target, recurse without increasing depth
-				deps(out, state, dep, done, targetLevel, currentLevel, hidden, formatdot)
+				deps(out, state, dep, parent, done, targetLevel, currentLevel, hidden, formatdot, cp)
 			} else {
-				deps(out, state, dep, done, targetLevel, currentLevel+1, hidden, formatdot)
+				deps(out, state, dep, parent, done, targetLevel, currentLevel+1, hidden, formatdot, cp)
 			}
 		}
 	}
@@ -61,19 +79,72 @@ func printTarget(out io.Writer, target *core.BuildTarget, currentLevel int) {
 	fmt.Fprintf(out, "%s%s\n", indent, target.Label)
 }
 
-func printTargetDot(out io.Writer, target, parent *core.BuildTarget) {
+func printTargetDot(out io.Writer, state *core.BuildState, target, parent *core.BuildTarget, depType string, cp *criticalPath) {
 	fmt.Fprintf(out, "  subgraph \"%s\" {\n", target)
-	shape := "ellipse"
+	shape := "box"
 	if target.IsFilegroup {
 		shape = "folder"
 	} else if target.IsRemoteFile {
 		shape = "octagon"
 	} else if target.IsTextFile {
 		shape = "note"
+	} else if target.IsTest() {
+		shape = "ellipse"
 	} else if target.IsBinary {
-		shape = "component"
+		shape = "diamond"
+	}
+	attrs := fmt.Sprintf("shape=%s tooltip=\"%s, %d outputs\"", shape, target.RuleName, len(target.Outputs()))
+	if cp.contains(target) {
+		attrs += " " + cp.dotAttrs(target)
+	} else if colour := dotColour(state, target); colour != "" {
+		attrs += fmt.Sprintf(" color=%s", colour)
+	}
+	if url := dotURL(state, target); url != "" {
+		attrs += fmt.Sprintf(" URL=\"%s\"", url)
 	}
-	fmt.Fprintf(out, "   node [shape=%s] \"%s\";\n", shape, target)
-	fmt.Fprintf(out, "   \"%s\" -> \"%s\";\n", parent, target)
+	fmt.Fprintf(out, "   node [%s] \"%s\";\n", attrs, target)
+	if cp.containsEdge(parent, target) {
+		depType += " color=red"
+	}
+	fmt.Fprintf(out, "   \"%s\" -> \"%s\" [label=%s];\n", parent, target, depType)
 	fmt.Fprintf(out, "  }\n")
 }
+
+// dependencyType returns how a target depends on the given label: as a tool, data or a plain dep.
+func dependencyType(target *core.BuildTarget, label core.BuildLabel) string {
+	if target.IsTool(label) {
+		return "tool"
+	}
+	for _, data := range target.AllData() {
+		if l, ok := data.Label(); ok && l == label {
+			return "data"
+		}
+	}
+	return "dep"
+}
+
+// dotColour returns the colour to draw a target in, based on the languages (i.e. requires) it's configured for.
+func dotColour(state *core.BuildState, target *core.BuildTarget) string {
+	for _, require := range target.Requires {
+		if colour, present := state.Config.Colours[require]; present {
+			// These are pseudo-variables like ${YELLOW} or ${BOLD_RED} for the terminal; dot just wants the colour.
+			colour = strings.TrimSuffix(strings.TrimPrefix(colour, "${"), "}")
+			return strings.ToLower(strings.TrimPrefix(colour, "BOLD_"))
+		}
+	}
+	return ""
+}
+
+// dotURL returns a link to the source of a target, for targets that are just files.
+func dotURL(state *core.BuildState, target *core.BuildTarget) string {
+	if target.IsRemoteFile {
+		if urls := target.AllURLs(state); len(urls) > 0 {
+			return urls[0]
+		}
+	} else if target.IsFilegroup && len(target.Sources) == 1 {
+		if _, ok := target.Sources[0].Label(); !ok {
+			return target.Sources[0].Paths(state.Graph)[0]
+		}
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package query
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -40,7 +41,7 @@ func TestQueryDeps(t *testing.T) {
 
 	t.Run("visible_level_1", func(t *testing.T) {
 		var buf bytes.Buffer
-		Deps(&buf, state, query, false, 1, false)
+		Deps(&buf, state, query, false, 1, false, nil)
 		assert.Equal(t, `//third_party/python:absl
 //third_party/python:colorlog
 `, buf.String())
@@ -48,7 +49,7 @@ func TestQueryDeps(t *testing.T) {
 
 	t.Run("visible_level_2", func(t *testing.T) {
 		var buf bytes.Buffer
-		Deps(&buf, state, query, false, 2, false)
+		Deps(&buf, state, query, false, 2, false, nil)
 		assert.Equal(t, `//third_party/python:absl
   //third_party/python:six
 //third_party/python:colorlog
@@ -57,7 +58,7 @@ func TestQueryDeps(t *testing.T) {
 
 	t.Run("visible_minus_level", func(t *testing.T) {
 		var buf bytes.Buffer
-		Deps(&buf, state, query, false, -1, false)
+		Deps(&buf, state, query, false, -1, false, nil)
 		assert.Equal(t, `//third_party/python:absl
   //third_party/python:six
 //third_party/python:colorlog
@@ -66,7 +67,7 @@ func TestQueryDeps(t *testing.T) {
 
 	t.Run("hidden_level_1", func(t *testing.T) {
 		var buf bytes.Buffer
-		Deps(&buf, state, query, true, 1, false)
+		Deps(&buf, state, query, true, 1, false, nil)
 		assert.Equal(t, `//third_party/python:absl
 //third_party/python:colorlog
 //tools/performance:_parse_perf_test#lib
@@ -76,7 +77,7 @@ func TestQueryDeps(t *testing.T) {
 
 	t.Run("hidden_level_2", func(t *testing.T) {
 		var buf bytes.Buffer
-		Deps(&buf, state, query, true, 2, false)
+		Deps(&buf, state, query, true, 2, false, nil)
 		assert.Equal(t, `//third_party/python:absl
   //third_party/python:_absl#wheel
 //third_party/python:colorlog
@@ -89,7 +90,7 @@ func TestQueryDeps(t *testing.T) {
 
 	t.Run("hidden_minus_level", func(t *testing.T) {
 		var buf bytes.Buffer
-		Deps(&buf, state, query, true, -1, false)
+		Deps(&buf, state, query, true, -1, false, nil)
 		assert.Equal(t, `//third_party/python:absl
   //third_party/python:_absl#wheel
     //third_party/python:_absl#download
@@ -105,3 +106,131 @@ func TestQueryDeps(t *testing.T) {
 `, buf.String())
 	})
 }
+
+func TestQueryDepsDot(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	state.Config.Colours = map[string]string{"go": "${BOLD_YELLOW}"}
+	pkg := core.NewPackage("src/core")
+
+	file := addNewTarget(state.Graph, pkg, "config_file", []core.BuildInput{core.FileLabel{File: "config.json", Package: pkg.Name}})
+	file.IsFilegroup = true
+	file.RuleName = "filegroup"
+	tool := addNewTarget(state.Graph, pkg, "gen", nil)
+	tool.IsBinary = true
+	tool.RuleName = "go_binary"
+	tool.Requires = []string{"go"}
+	lib := addNewTarget(state.Graph, pkg, "core", nil)
+	lib.RuleName = "go_library"
+	lib.Requires = []string{"go"}
+	lib.AddOutput("core.a")
+	lib.AddTool(tool.Label)
+	lib.AddDependency(tool.Label)
+	lib.AddDatum(file.Label)
+	lib.AddDependency(file.Label)
+
+	var buf bytes.Buffer
+	Deps(&buf, state, []core.BuildLabel{lib.Label}, false, -1, true, nil)
+	assert.Equal(t, `digraph deps {
+  fontname="Helvetica,Arial,sans-serif"
+  node [fontname="Helvetica,Arial,sans-serif"]
+  edge [fontname="Helvetica,Arial,sans-serif"]
+  rankdir="LR"
+  subgraph "//src/core:config_file" {
+   node [shape=folder tooltip="filegroup, 1 outputs" URL="src/core/config.json"] "//src/core:config_file";
+   "//src/core:core" -> "//src/core:config_file" [label=data];
+  }
+  subgraph "//src/core:gen" {
+   node [shape=diamond tooltip="go_binary, 0 outputs" color=yellow] "//src/core:gen";
+   "//src/core:core" -> "//src/core:gen" [label=tool];
+  }
+}
+`, buf.String())
+}
+
+func TestQueryDepsDotCriticalPath(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	pkg := core.NewPackage("src/core")
+
+	util := addNewTarget(state.Graph, pkg, "util", nil)
+	util.RuleName = "go_library"
+	gen := addNewTarget(state.Graph, pkg, "gen", nil)
+	gen.RuleName = "go_binary"
+	parser := addNewTarget(state.Graph, pkg, "parser", nil)
+	parser.RuleName = "go_library"
+	parser.AddDependency(util.Label)
+	lib := addNewTarget(state.Graph, pkg, "core", nil)
+	lib.RuleName = "go_library"
+	lib.AddDependency(gen.Label)
+	lib.AddDependency(parser.Label)
+
+	timings := map[core.BuildLabel]time.Duration{
+		lib.Label:    time.Second,
+		gen.Label:    1500 * time.Millisecond,
+		parser.Label: time.Second,
+		util.Label:   time.Second,
+	}
+	var buf bytes.Buffer
+	Deps(&buf, state, []core.BuildLabel{lib.Label}, false, -1, true, timings)
+	assert.Equal(t, `digraph deps {
+  fontname="Helvetica,Arial,sans-serif"
+  node [fontname="Helvetica,Arial,sans-serif"]
+  edge [fontname="Helvetica,Arial,sans-serif"]
+  rankdir="LR"
+  "//src/core:core" [color=red label="//src/core:core\n1s"];
+  subgraph "//src/core:gen" {
+   node [shape=box tooltip="go_binary, 0 outputs"] "//src/core:gen";
+   "//src/core:core" -> "//src/core:gen" [label=dep];
+  }
+  subgraph "//src/core:parser" {
+   node [shape=box tooltip="go_library, 0 outputs" color=red label="//src/core:parser\n1s"] "//src/core:parser";
+   "//src/core:core" -> "//src/core:parser" [label=dep color=red];
+  }
+  subgraph "//src/core:util" {
+   node [shape=box tooltip="go_library, 0 outputs" color=red label="//src/core:util\n1s"] "//src/core:util";
+   "//src/core:parser" -> "//src/core:util" [label=dep color=red];
+  }
+}
+`, buf.String())
+}
+
+func TestQueryDepsDotCriticalPathHidden(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	pkg := core.NewPackage("src/core")
+
+	util := addNewTarget(state.Graph, pkg, "util", nil)
+	util.RuleName = "go_library"
+	gen := addNewTarget(state.Graph, pkg, "gen", nil)
+	gen.RuleName = "go_binary"
+	srcs := addNewTarget(state.Graph, pkg, "_core#srcs", nil)
+	srcs.RuleName = "go_library"
+	srcs.AddDependency(util.Label)
+	lib := addNewTarget(state.Graph, pkg, "core", nil)
+	lib.RuleName = "go_library"
+	lib.AddDependency(gen.Label)
+	lib.AddDependency(srcs.Label)
+
+	timings := map[core.BuildLabel]time.Duration{
+		lib.Label:  time.Second,
+		gen.Label:  1500 * time.Millisecond,
+		srcs.Label: time.Second,
+		util.Label: time.Second,
+	}
+	var buf bytes.Buffer
+	Deps(&buf, state, []core.BuildLabel{lib.Label}, false, -1, true, timings)
+	assert.Equal(t, `digraph deps {
+  fontname="Helvetica,Arial,sans-serif"
+  node [fontname="Helvetica,Arial,sans-serif"]
+  edge [fontname="Helvetica,Arial,sans-serif"]
+  rankdir="LR"
+  "//src/core:core" [color=red label="//src/core:core\n1s"];
+  subgraph "//src/core:util" {
+   node [shape=box tooltip="go_library, 0 outputs" color=red label="//src/core:util\n1s"] "//src/core:util";
+   "//src/core:core" -> "//src/core:util" [label=dep color=red];
+  }
+  subgraph "//src/core:gen" {
+   node [shape=box tooltip="go_binary, 0 outputs"] "//src/core:gen";
+   "//src/core:core" -> "//src/core:gen" [label=dep];
+  }
+}
+`, buf.String())
+}
@@ -5,18 +5,34 @@ import (
 	"strings"
 
 	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/fs"
 )
 
 // Filter takes the list of BuildLabels and checks which ones match the label selectors passed in.
-func Filter(state *core.BuildState, labels core.BuildLabels, showHidden bool) {
+// If any types are given, targets must also have been created by a rule matching one of them; these
+// can be glob patterns (e.g. go_*).
+func Filter(state *core.BuildState, labels core.BuildLabels, showHidden bool, types []string) {
 	// Eventually this could be more clever...
 	matcher := state.ShouldInclude
 
 	for _, label := range labels {
 		if showHidden || !strings.HasPrefix(label.Name, "_") {
-			if matcher(state.Graph.TargetOrDie(label)) {
+			if target := state.Graph.TargetOrDie(label); matcher(target) && matchesType(target, types) {
 				fmt.Println(label)
 			}
 		}
 	}
 }
+
+// matchesType returns true if the target's rule name matches any of the given patterns, or there are none.
+func matchesType(target *core.BuildTarget, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if matched, _ := fs.Match(t, target.RuleName); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -3,8 +3,11 @@ package query
 import (
 	"encoding/base64"
 	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"sync"
 
 	"github.com/thought-machine/please/src/build"
@@ -12,9 +15,18 @@ import (
 )
 
 // Graph prints a representation of the build graph as JSON.
-func Graph(state *core.BuildState, targets []core.BuildLabel) {
+// If pruneUnconnected is true, the whole graph is considered and only targets that are connected to
+// the given ones (either as a transitive dependency or reverse dependency) are included.
+// If ndjson is true, each target is printed as a separate JSON object on its own line instead.
+func Graph(state *core.BuildState, targets []core.BuildLabel, pruneUnconnected, ndjson bool) {
+	if ndjson {
+		if err := printNDJSONGraph(os.Stdout, state, graphTargets(state, targets, pruneUnconnected)); err != nil {
+			log.Fatalf("Failed to serialise JSON: %s\n", err)
+		}
+		return
+	}
 	log.Notice("Generating graph...")
-	g := makeJSONGraph(state, targets)
+	g := makeJSONGraph(state, targets, pruneUnconnected)
 
 	encoder := json.NewEncoder(os.Stdout)
 	encoder.SetIndent("", "    ")
@@ -58,7 +70,7 @@ type JSONTarget struct {
 	TestOnly bool        `json:"test_only,omitempty" note:"true if target should be restricted to test code"`
 }
 
-func makeJSONGraph(state *core.BuildState, targets []core.BuildLabel) *JSONGraph {
+func makeJSONGraph(state *core.BuildState, targets []core.BuildLabel, pruneUnconnected bool) *JSONGraph {
 	ret := JSONGraph{
 		Packages: map[string]JSONPackage{},
 		Subrepos: map[string]*JSONGraph{},
@@ -67,15 +79,76 @@ func makeJSONGraph(state *core.BuildState, targets []core.BuildLabel) *JSONGraph
 		for pkg := range makeAllPackages(state) {
 			ret.Subrepo(pkg.subrepo).Packages[pkg.name] = pkg
 		}
+	} else if pruneUnconnected {
+		for _, target := range connectedTargets(state.Graph, targets) {
+			ret.addTarget(state, target)
+		}
 	} else {
 		done := map[core.BuildLabel]struct{}{}
 		for _, target := range targets {
-			addJSONTarget(state, &ret, target, done)
+			walkTargets(state, target, done, func(target *core.BuildTarget) {
+				ret.addTarget(state, target)
+			})
 		}
 	}
 	return &ret
 }
 
+// NDJSONTarget is the representation of a target printed by plz query graph --ndjson.
+// This format is stable; fields may be added to it but existing ones won't change.
+type NDJSONTarget struct {
+	Label  string   `json:"label"`
+	Type   string   `json:"type"`
+	Deps   []string `json:"deps,omitempty"`
+	Srcs   []string `json:"srcs,omitempty"`
+	Outs   []string `json:"outs,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// printNDJSONGraph prints the given targets as newline-delimited JSON, one target per line.
+func printNDJSONGraph(w io.Writer, state *core.BuildState, targets []*core.BuildTarget) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, target := range targets {
+		t := NDJSONTarget{
+			Label:  target.Label.String(),
+			Type:   target.RuleName,
+			Srcs:   target.AllSourcePaths(state.Graph),
+			Labels: target.Labels,
+		}
+		for _, dep := range target.Dependencies() {
+			t.Deps = append(t.Deps, dep.Label.String())
+		}
+		for _, out := range target.Outputs() {
+			t.Outs = append(t.Outs, filepath.Join(target.Label.PackageName, out))
+		}
+		if err := encoder.Encode(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// graphTargets returns the targets that makeJSONGraph would include, in label order.
+func graphTargets(state *core.BuildState, targets []core.BuildLabel, pruneUnconnected bool) []*core.BuildTarget {
+	if len(targets) == 0 {
+		return state.Graph.AllTargets()
+	}
+	var ret core.BuildTargets
+	if pruneUnconnected {
+		ret = connectedTargets(state.Graph, targets)
+	} else {
+		done := map[core.BuildLabel]struct{}{}
+		for _, label := range targets {
+			walkTargets(state, label, done, func(target *core.BuildTarget) {
+				ret = append(ret, target)
+			})
+		}
+	}
+	sort.Sort(ret)
+	return ret
+}
+
 // Subrepo returns a subrepo for the given name. If it's empty the top-level repo is returned.
 func (graph *JSONGraph) Subrepo(name string) *JSONGraph {
 	if name == "" {
@@ -110,7 +183,9 @@ func makeAllPackages(state *core.BuildState) <-chan JSONPackage {
 	return ch
 }
 
-func addJSONTarget(state *core.BuildState, graph *JSONGraph, label core.BuildLabel, done map[core.BuildLabel]struct{}) {
+// walkTargets calls the given function for the target with the given label (or every target in
+// its package if it's a :all label) and all their transitive dependencies, once each.
+func walkTargets(state *core.BuildState, label core.BuildLabel, done map[core.BuildLabel]struct{}, f func(*core.BuildTarget)) {
 	if _, present := done[label]; present {
 		return
 	}
@@ -118,11 +193,20 @@ func addJSONTarget(state *core.BuildState, graph *JSONGraph, label core.BuildLab
 	if label.IsAllTargets() {
 		pkg := state.Graph.PackageOrDie(label)
 		for _, target := range pkg.AllTargets() {
-			addJSONTarget(state, graph, target.Label, done)
+			walkTargets(state, target.Label, done, f)
 		}
 		return
 	}
 	target := state.Graph.TargetOrDie(label)
+	f(target)
+	for _, dep := range target.Dependencies() {
+		walkTargets(state, dep.Label, done, f)
+	}
+}
+
+// addTarget adds a single target to this graph.
+func (graph *JSONGraph) addTarget(state *core.BuildState, target *core.BuildTarget) {
+	label := target.Label
 	repo := graph.Subrepo(label.Subrepo)
 	if _, present := repo.Packages[label.PackageName]; present {
 		repo.Packages[label.PackageName].Targets[label.Name] = makeJSONTarget(state, target)
@@ -133,9 +217,47 @@ func addJSONTarget(state *core.BuildState, graph *JSONGraph, label core.BuildLab
 			},
 		}
 	}
-	for _, dep := range target.Dependencies() {
-		addJSONTarget(state, graph, dep.Label, done)
+}
+
+// connectedTargets returns all the targets that can be reached from the given ones by following
+// dependencies in either the forward or reverse direction (but not a mixture of the two).
+func connectedTargets(graph *core.BuildGraph, labels []core.BuildLabel) []*core.BuildTarget {
+	var seeds []*core.BuildTarget
+	for _, label := range labels {
+		if label.IsAllTargets() {
+			seeds = append(seeds, graph.PackageOrDie(label).AllTargets()...)
+		} else {
+			seeds = append(seeds, graph.TargetOrDie(label))
+		}
+	}
+	revdeps := map[*core.BuildTarget][]*core.BuildTarget{}
+	for _, target := range graph.AllTargets() {
+		for _, dep := range target.Dependencies() {
+			revdeps[dep] = append(revdeps[dep], target)
+		}
+	}
+	done := map[*core.BuildTarget]struct{}{}
+	var ret []*core.BuildTarget
+	bfs := func(next func(*core.BuildTarget) []*core.BuildTarget) {
+		seen := map[*core.BuildTarget]struct{}{}
+		queue := slices.Clone(seeds)
+		for len(queue) > 0 {
+			target := queue[0]
+			queue = queue[1:]
+			if _, present := seen[target]; present {
+				continue
+			}
+			seen[target] = struct{}{}
+			if _, present := done[target]; !present {
+				done[target] = struct{}{}
+				ret = append(ret, target)
+			}
+			queue = append(queue, next(target)...)
+		}
 	}
+	bfs(func(target *core.BuildTarget) []*core.BuildTarget { return target.Dependencies() })
+	bfs(func(target *core.BuildTarget) []*core.BuildTarget { return revdeps[target] })
+	return ret
 }
 
 func makeJSONPackage(state *core.BuildState, pkg *core.Package) JSONPackage {
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,7 +12,7 @@ import (
 
 func TestQueryEntireGraph(t *testing.T) {
 	state := makeGraph(t)
-	graph := makeJSONGraph(state, nil)
+	graph := makeJSONGraph(state, nil, false)
 	assert.Equal(t, 2, len(graph.Packages))
 	pkg1 := graph.Packages["package1"]
 	assert.Equal(t, 2, len(pkg1.Targets))
@@ -23,7 +24,7 @@ func TestQueryEntireGraph(t *testing.T) {
 }
 
 func TestQuerySingleTarget(t *testing.T) {
-	graph := makeJSONGraph(makeGraph(t), []core.BuildLabel{core.ParseBuildLabel("//package1:target2", "")})
+	graph := makeJSONGraph(makeGraph(t), []core.BuildLabel{core.ParseBuildLabel("//package1:target2", "")}, false)
 	assert.Equal(t, 1, len(graph.Packages))
 	pkg1 := graph.Packages["package1"]
 	assert.Equal(t, 2, len(pkg1.Targets))
@@ -31,7 +32,7 @@ func TestQuerySingleTarget(t *testing.T) {
 }
 
 func TestQueryPackage(t *testing.T) {
-	graph := makeJSONGraph(makeGraph(t), []core.BuildLabel{core.ParseBuildLabel("//package1:all", "")})
+	graph := makeJSONGraph(makeGraph(t), []core.BuildLabel{core.ParseBuildLabel("//package1:all", "")}, false)
 	assert.Equal(t, 1, len(graph.Packages))
 	pkg1 := graph.Packages["package1"]
 	assert.Equal(t, 2, len(pkg1.Targets))
@@ -39,6 +40,50 @@ func TestQueryPackage(t *testing.T) {
 	assert.Equal(t, []string{"//package1:target1"}, pkg1.Targets["target2"].Deps)
 }
 
+func TestQueryPruneUnconnected(t *testing.T) {
+	state := makeGraph(t)
+	pkg3 := core.NewPackage("package3")
+	pkg3.AddTarget(makeTarget("//package3:target4"))
+	t5 := makeTarget("//package3:target5", "//package1:target1")
+	pkg3.AddTarget(t5)
+	state.Graph.AddTarget(pkg3.Target("target4"))
+	state.Graph.AddTarget(t5)
+	state.Graph.AddPackage(pkg3)
+	require.NoError(t, t5.ResolveDependencies(state.Graph))
+
+	// target1 is a dependency and target3 a reverse dependency. target5 shares a dependency
+	// with target2 but isn't connected to it by a path in one direction.
+	graph := makeJSONGraph(state, []core.BuildLabel{core.ParseBuildLabel("//package1:target2", "")}, true)
+	assert.Equal(t, 2, len(graph.Packages))
+	assert.Equal(t, 2, len(graph.Packages["package1"].Targets))
+	assert.Contains(t, graph.Packages["package2"].Targets, "target3")
+
+	graph = makeJSONGraph(state, []core.BuildLabel{core.ParseBuildLabel("//package1:target1", "")}, true)
+	assert.Equal(t, 3, len(graph.Packages))
+	assert.Equal(t, 1, len(graph.Packages["package3"].Targets))
+	assert.Contains(t, graph.Packages["package3"].Targets, "target5")
+
+	graph = makeJSONGraph(state, []core.BuildLabel{core.ParseBuildLabel("//package3:target4", "")}, true)
+	assert.Equal(t, 1, len(graph.Packages))
+}
+
+func TestQueryGraphNDJSON(t *testing.T) {
+	state := makeGraph(t)
+	t2 := state.Graph.TargetOrDie(core.ParseBuildLabel("//package1:target2", ""))
+	t2.RuleName = "genrule"
+	t2.AddOutput("out.txt")
+	t2.AddLabel("codegen")
+	t2.AddSource(core.FileLabel{File: "in.txt", Package: "package1"})
+
+	var buf bytes.Buffer
+	targets := graphTargets(state, []core.BuildLabel{core.ParseBuildLabel("//package2:target3", "")}, false)
+	require.NoError(t, printNDJSONGraph(&buf, state, targets))
+	assert.Equal(t, `{"label":"//package1:target1","type":""}
+{"label":"//package1:target2","type":"genrule","deps":["//package1:target1"],"srcs":["package1/in.txt"],"outs":["package1/out.txt"],"labels":["codegen"]}
+{"label":"//package2:target3","type":"","deps":["//package1:target2"]}
+`, buf.String())
+}
+
 func makeGraph(t *testing.T) *core.BuildState {
 	t.Helper()
 	state := core.NewDefaultBuildState()
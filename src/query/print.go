@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/thought-machine/please/src/core"
 	"github.com/thought-machine/please/src/parse"
@@ -18,10 +19,13 @@ import (
 // Print produces a Python call which would (hopefully) regenerate the same build rule if run.
 // This is of course not ideal since they were almost certainly created as a java_library
 // or some similar wrapper rule, but we've lost that information by now.
-func Print(state *core.BuildState, targets []core.BuildLabel, fields, labels []string, omitHidden, outputJSON bool) {
+//
+// If outputJSON is true it prints JSON instead, in the given schema version (see PrintOutput).
+func Print(state *core.BuildState, targets []core.BuildLabel, fields, labels []string, omitHidden, outputJSON bool, schemaVersion int) {
 	order := parse.BuildRuleArgOrder(state)
 	graph := state.Graph
 	ts := map[string]map[string]interface{}{}
+	output := PrintOutput{SchemaVersion: schemaVersion, Targets: []PrintTarget{}}
 	for _, target := range targets {
 		if target.IsHidden() && omitHidden {
 			continue
@@ -30,7 +34,14 @@ func Print(state *core.BuildState, targets []core.BuildLabel, fields, labels []s
 		t := graph.TargetOrDie(target)
 
 		if outputJSON {
-			ts[target.String()] = targetToValueMap(order, fields, t)
+			if schemaVersion == 0 {
+				ts[target.String()] = targetToValueMap(order, fields, t, 0)
+			} else {
+				output.Targets = append(output.Targets, PrintTarget{
+					Label:  target.String(),
+					Fields: targetToValueMap(order, fields, t, schemaVersion),
+				})
+			}
 			continue
 		}
 
@@ -58,7 +69,13 @@ func Print(state *core.BuildState, targets []core.BuildLabel, fields, labels []s
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetEscapeHTML(false)
 		enc.SetIndent("", "    ")
-		if err := enc.Encode(ts); err != nil {
+		var err error
+		if schemaVersion == 0 {
+			err = enc.Encode(ts)
+		} else {
+			err = enc.Encode(output)
+		}
+		if err != nil {
 			panic(err)
 		}
 	}
@@ -72,9 +89,29 @@ func handleSpecialFields(specials specialFieldsMap, target *core.BuildTarget, na
 	return reflect.ValueOf(fun(target)), true
 }
 
+// CurrentPrintSchemaVersion is the latest version of the JSON schema for plz query print.
+const CurrentPrintSchemaVersion = 1
+
+// PrintOutput is the JSON output of plz query print --json, from schema version 1 onwards.
+// Version 0 is a map of label to fields, with no wrapper, and is retained for compatibility.
+//
+// Fields are keyed by their snake_case names as they'd be passed to build_rule (except for a
+// few with no equivalent, like named_secrets) and lists are always arrays, never null.
+type PrintOutput struct {
+	SchemaVersion int           `json:"schema_version"`
+	Targets       []PrintTarget `json:"targets"`
+}
+
+// A PrintTarget is a single target in a PrintOutput.
+type PrintTarget struct {
+	Label  string                 `json:"label"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
 // targetToValueMap creates a map of fields on BuildTarget keyed by the name tag on the struct field annotation. It
 // handles converting fields like named fields, or complex fields so that this can be serialised to json.
-func targetToValueMap(order map[string]int, fieldsToInclude []string, target *core.BuildTarget) map[string]interface{} {
+// For schema version 0, fields without a name tag are keyed by their lowercased Go name and lists may be null.
+func targetToValueMap(order map[string]int, fieldsToInclude []string, target *core.BuildTarget, schemaVersion int) map[string]interface{} {
 	ret := map[string]interface{}{}
 	fs := fields(reflect.ValueOf(target).Elem(), order)
 
@@ -94,7 +131,11 @@ func targetToValueMap(order map[string]int, fieldsToInclude []string, target *co
 			continue
 		}
 		name := fieldName(field.field)
-		if _, ok := include[name]; len(fieldsToInclude) != 0 && !ok {
+		jsonName := name
+		if schemaVersion > 0 && field.field.Tag.Get("name") == "" {
+			jsonName = snakeCase(field.field.Name)
+		}
+		if _, ok := include[jsonName]; len(fieldsToInclude) != 0 && !ok {
 			continue
 		}
 
@@ -102,14 +143,17 @@ func targetToValueMap(order map[string]int, fieldsToInclude []string, target *co
 		if !isSpecial {
 			value = field.value
 		}
-		if _, ok := ret[name]; ok && isZero(value) {
+		if _, ok := ret[jsonName]; ok && isZero(value) {
 			continue
 		}
+		if schemaVersion > 0 && value.Kind() == reflect.Slice && value.IsNil() {
+			value = reflect.MakeSlice(value.Type(), 0, 0)
+		}
 
 		if s, ok := value.Interface().(fmt.Stringer); ok {
-			ret[name] = s.String()
+			ret[jsonName] = s.String()
 		} else {
-			ret[name] = value.Interface()
+			ret[jsonName] = value.Interface()
 		}
 	}
 	return ret
@@ -318,6 +362,21 @@ func (p *printer) printField(f reflect.StructField, v reflect.Value) {
 	}
 }
 
+// snakeCase converts a Go field name to snake_case, e.g. ExitOnError -> exit_on_error.
+func snakeCase(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
 func shouldPrint(f reflect.StructField, target *core.BuildTarget) bool {
 	if f.Tag.Get("print") == "false" { // Indicates not to print the field.
 		return false
@@ -71,7 +71,7 @@ func TestPrintJSONOutput(t *testing.T) {
 	target.Tools = append(target.Tools, src("//tools:tool1"))
 	target.IsBinary = true
 
-	valueMap := targetToValueMap(order, nil, target)
+	valueMap := targetToValueMap(order, nil, target, 0)
 	jsonValue := new(bytes.Buffer)
 	encoder := json.NewEncoder(jsonValue)
 	encoder.SetEscapeHTML(false)
@@ -87,6 +87,38 @@ func TestPrintJSONOutput(t *testing.T) {
 	assert.ElementsMatch(t, result["outs"], []string{"out1.go", "out2.go"})
 }
 
+func TestPrintJSONSchema(t *testing.T) {
+	target := core.NewBuildTarget(core.ParseBuildLabel("//src/query:test_print_schema", ""))
+	target.AddOutput("out1.go")
+	target.ExitOnError = true
+
+	b, err := json.Marshal(PrintOutput{
+		SchemaVersion: CurrentPrintSchemaVersion,
+		Targets: []PrintTarget{{
+			Label:  target.Label.String(),
+			Fields: targetToValueMap(order, []string{"outs", "srcs", "exit_on_error"}, target, CurrentPrintSchemaVersion),
+		}},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"schema_version": 1,
+		"targets": [{
+			"label": "//src/query:test_print_schema",
+			"fields": {"outs": ["out1.go"], "srcs": [], "exit_on_error": true}
+		}]
+	}`, string(b))
+
+	// Version 0 doesn't know about exit_on_error, and leaves empty lists as null.
+	b, err = json.Marshal(targetToValueMap(order, []string{"outs", "srcs", "exitonerror"}, target, 0))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"outs": ["out1.go"], "srcs": null, "exitonerror": true}`, string(b))
+}
+
+func TestSnakeCase(t *testing.T) {
+	assert.Equal(t, "exit_on_error", snakeCase("ExitOnError"))
+	assert.Equal(t, "stamp", snakeCase("Stamp"))
+}
+
 func TestFilegroupOutput(t *testing.T) {
 	target := core.NewBuildTarget(core.ParseBuildLabel("//src/query:test_filegroup_output", ""))
 	target.AddSource(src("file.go"))
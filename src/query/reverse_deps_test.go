@@ -34,6 +34,32 @@ func TestReverseDeps(t *testing.T) {
 	assert.ElementsMatch(t, core.BuildLabels{branch.Label}, labels)
 }
 
+func TestReverseDepsDiamond(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	graph := state.Graph
+
+	root := core.NewBuildTarget(core.ParseBuildLabel("//package:root", ""))
+	left := core.NewBuildTarget(core.ParseBuildLabel("//package:left", ""))
+	right := core.NewBuildTarget(core.ParseBuildLabel("//package:right", ""))
+	top := core.NewBuildTarget(core.ParseBuildLabel("//package:top", ""))
+	left.AddDependency(root.Label)
+	right.AddDependency(root.Label)
+	top.AddDependency(left.Label)
+	top.AddDependency(right.Label)
+	for _, t := range []*core.BuildTarget{root, left, right, top} {
+		graph.AddTarget(t)
+	}
+	for _, t := range []*core.BuildTarget{left, right, top} {
+		t.ResolveDependencies(graph)
+	}
+	graph.AddPackage(core.NewPackage("package"))
+
+	labels := revDepsLabels(state, []core.BuildLabel{root.Label}, false, -1)
+	assert.ElementsMatch(t, core.BuildLabels{left.Label, right.Label, top.Label}, labels)
+	labels = revDepsLabels(state, []core.BuildLabel{root.Label}, false, 1)
+	assert.ElementsMatch(t, core.BuildLabels{left.Label, right.Label}, labels)
+}
+
 func TestReverseDepsWithHidden(t *testing.T) {
 	state := core.NewDefaultBuildState()
 	graph := state.Graph
@@ -21,21 +21,79 @@ func SomePath(graph *core.BuildGraph, from, to, except []core.BuildLabel, showHi
 	for _, l1 := range expandAllTargets(graph, from) {
 		for _, l2 := range expandAllTargets(graph, to) {
 			if path := s.SomePath(l1, l2); len(path) != 0 {
-				fmt.Println("Found path:")
-				if !showHidden {
-					// Filter path to just non-hidden targets
-					for i, x := range path {
-						path[i] = x.Parent()
-					}
-					path = slices.Compact(path)
-				}
-				for _, l := range path {
-					fmt.Printf("  %s\n", l)
-				}
+				printPath(path, showHidden)
 				return nil
 			}
 		}
 	}
+	return noPathError(from, to)
+}
+
+// AllPaths is like SomePath but finds every path between the targets, up to maxPaths of them
+// (or without limit if maxPaths is zero). Useful for finding all the routes by which a diamond
+// dependency arises.
+func AllPaths(graph *core.BuildGraph, from, to, except []core.BuildLabel, showHidden bool, maxPaths int) error {
+	paths := allPaths(graph, from, to, except, showHidden, maxPaths)
+	if len(paths) == 0 {
+		return noPathError(from, to)
+	}
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Println()
+		}
+		printPath(path, true) // already filtered by allPaths
+	}
+	return nil
+}
+
+// allPaths returns all the distinct paths between the given targets, up to maxPaths of them.
+func allPaths(graph *core.BuildGraph, from, to, except []core.BuildLabel, showHidden bool, maxPaths int) [][]core.BuildLabel {
+	s := allpaths{
+		somepath: somepath{
+			graph:  graph,
+			except: make(map[core.BuildLabel]struct{}, len(except)),
+		},
+		maxPaths: maxPaths,
+		seen:     map[string]struct{}{},
+	}
+	for _, ex := range except {
+		s.except[ex] = struct{}{}
+	}
+	for _, l1 := range expandAllTargets(graph, from) {
+		for _, l2 := range expandAllTargets(graph, to) {
+			t1 := graph.TargetOrDie(l1)
+			t2 := graph.TargetOrDie(l2)
+			// As with SomePath, we don't know which way around the dependency goes.
+			if _, cont := s.findPaths(t1, t2, nil, map[core.BuildLabel]bool{}, showHidden); !cont {
+				return s.paths
+			} else if _, cont := s.findPaths(t2, t1, nil, map[core.BuildLabel]bool{}, showHidden); !cont {
+				return s.paths
+			}
+		}
+	}
+	return s.paths
+}
+
+// printPath prints a single path, optionally filtering out hidden targets.
+func printPath(path []core.BuildLabel, showHidden bool) {
+	fmt.Println("Found path:")
+	for _, l := range filterPath(path, showHidden) {
+		fmt.Printf("  %s\n", l)
+	}
+}
+
+// filterPath filters the given path to just non-hidden targets, unless showHidden is true.
+func filterPath(path []core.BuildLabel, showHidden bool) []core.BuildLabel {
+	if showHidden {
+		return path
+	}
+	for i, x := range path {
+		path[i] = x.Parent()
+	}
+	return slices.Compact(path)
+}
+
+func noPathError(from, to []core.BuildLabel) error {
 	if len(from) == 1 && len(to) == 1 {
 		return fmt.Errorf("Couldn't find any dependency path between %s and %s", from[0], to[0])
 	}
@@ -81,33 +139,81 @@ func (s *somepath) somePath(target1, target2 core.BuildLabel) []core.BuildLabel
 }
 
 func somePath(graph *core.BuildGraph, target1, target2 *core.BuildTarget, seen, except map[core.BuildLabel]struct{}) []core.BuildLabel {
-	if target1.Label == target2.Label {
-		return []core.BuildLabel{target1.Label}
-	} else if target1.Parent(graph) == target2 {
-		// If there's some path to the parent of the named target, count that. This is usually what you want e.g. in the
-		// case of protos where the named target is just a filegroup that isn't actually depended on after the
-		// require/provide is resolved.
+	if pathEnds(graph, target1, target2) {
 		return []core.BuildLabel{target1.Label}
 	} else if _, present := seen[target1.Label]; present {
 		return nil
 	}
 	seen[target1.Label] = struct{}{}
-	for _, dep := range target1.DeclaredDependencies() {
+	for _, t := range nextTargets(graph, target1, except) {
+		if path := somePath(graph, t, target2, seen, except); len(path) != 0 {
+			return append([]core.BuildLabel{target1.Label}, path...)
+		}
+	}
+	return nil
+}
+
+// pathEnds returns true if a path reaching target1 has reached target2.
+func pathEnds(graph *core.BuildGraph, target1, target2 *core.BuildTarget) bool {
+	// If there's some path to the parent of the named target, count that. This is usually what you want e.g. in the
+	// case of protos where the named target is just a filegroup that isn't actually depended on after the
+	// require/provide is resolved.
+	return target1.Label == target2.Label || target1.Parent(graph) == target2
+}
+
+// nextTargets returns the targets that a path can continue to from the given target.
+func nextTargets(graph *core.BuildGraph, target *core.BuildTarget, except map[core.BuildLabel]struct{}) []*core.BuildTarget {
+	var ret []*core.BuildTarget
+	for _, dep := range target.DeclaredDependencies() {
 		if t := graph.Target(dep); t != nil {
 			if _, present := except[t.Label]; present {
 				continue
 			}
-			for _, l := range t.ProvideFor(target1) {
-				if path := somePath(graph, graph.TargetOrDie(l), target2, seen, except); len(path) != 0 {
-					return append([]core.BuildLabel{target1.Label}, path...)
-				}
+			for _, l := range t.ProvideFor(target) {
+				ret = append(ret, graph.TargetOrDie(l))
 			}
 		}
 	}
-	if target1.Subrepo != nil && target1.Subrepo.Target != nil {
-		if path := somePath(graph, target1.Subrepo.Target, target2, seen, except); len(path) != 0 {
-			return append([]core.BuildLabel{target1.Label}, path...)
+	if target.Subrepo != nil && target.Subrepo.Target != nil {
+		ret = append(ret, target.Subrepo.Target)
+	}
+	return ret
+}
+
+type allpaths struct {
+	somepath
+	maxPaths int
+	paths    [][]core.BuildLabel
+	seen     map[string]struct{}
+}
+
+// findPaths finds all paths from target1 to target2, appending them to s.paths.
+// The build graph is acyclic, so every path found is a simple one. reachable memoises whether
+// target2 can be reached from each target, which avoids exploring dead ends more than once.
+// It returns whether target2 was reachable from target1, and false for the second value once
+// maxPaths have been found.
+func (s *allpaths) findPaths(target1, target2 *core.BuildTarget, prefix []core.BuildLabel, reachable map[core.BuildLabel]bool, showHidden bool) (bool, bool) {
+	prefix = append(prefix, target1.Label)
+	if pathEnds(s.graph, target1, target2) {
+		path := filterPath(slices.Clone(prefix), showHidden)
+		// Paths can collapse onto one another once hidden targets are removed.
+		key := fmt.Sprint(path)
+		if _, present := s.seen[key]; !present {
+			s.seen[key] = struct{}{}
+			s.paths = append(s.paths, path)
 		}
+		return true, s.maxPaths <= 0 || len(s.paths) < s.maxPaths
+	} else if r, present := reachable[target1.Label]; present && !r {
+		return false, true
 	}
-	return nil
+	found := false
+	for _, t := range nextTargets(s.graph, target1, s.except) {
+		f, cont := s.findPaths(t, target2, prefix, reachable, showHidden)
+		found = found || f
+		if !cont {
+			return found, false
+		}
+	}
+	reachable[target1.Label] = found
+	return found, true
 }
@@ -0,0 +1,55 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+func TestAllPaths(t *testing.T) {
+	graph := core.NewGraph()
+	// top depends on root via left, other and right, which also depends on it via a hidden child.
+	root := addPathTarget(graph, "//package:root")
+	left := addPathTarget(graph, "//package:left", root)
+	child := addPathTarget(graph, "//package:_right#child", root)
+	right := addPathTarget(graph, "//package:right", root, child)
+	other := addPathTarget(graph, "//package:other", root)
+	top := addPathTarget(graph, "//package:top", left, right, other)
+
+	all := []core.BuildLabel{top.Label, root.Label}
+	paths := allPaths(graph, all[:1], all[1:], nil, true, 0)
+	assert.ElementsMatch(t, [][]core.BuildLabel{
+		{top.Label, left.Label, root.Label},
+		{top.Label, right.Label, root.Label},
+		{top.Label, right.Label, child.Label, root.Label},
+		{top.Label, other.Label, root.Label},
+	}, paths)
+
+	// The hidden target collapses onto its parent, giving the same path as the one without it.
+	paths = allPaths(graph, all[:1], all[1:], nil, false, 0)
+	assert.ElementsMatch(t, [][]core.BuildLabel{
+		{top.Label, left.Label, root.Label},
+		{top.Label, right.Label, root.Label},
+		{top.Label, other.Label, root.Label},
+	}, paths)
+
+	// Works the other way around too, and respects except and the limit.
+	paths = allPaths(graph, all[1:], all[:1], []core.BuildLabel{left.Label, other.Label}, false, 1)
+	assert.Equal(t, [][]core.BuildLabel{
+		{top.Label, right.Label, root.Label},
+	}, paths)
+	paths = allPaths(graph, all[1:], all[:1], []core.BuildLabel{left.Label}, false, 0)
+	assert.Len(t, paths, 2)
+}
+
+func addPathTarget(graph *core.BuildGraph, label string, deps ...*core.BuildTarget) *core.BuildTarget {
+	t := core.NewBuildTarget(core.ParseBuildLabel(label, ""))
+	for _, dep := range deps {
+		t.AddDependency(dep.Label)
+	}
+	graph.AddTarget(t)
+	t.ResolveDependencies(graph)
+	return t
+}
@@ -2,9 +2,12 @@ package query
 
 import (
 	"fmt"
+	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/fs"
 )
 
 // WhatInputs prints the targets with the provided files as sources
@@ -49,3 +52,47 @@ func whatInputs(targets []*core.BuildTarget, file string, hidden bool) []core.Bu
 
 	return ret
 }
+
+// ExpandFileGlobs expands any glob patterns in the given files (e.g. src/proto/*.proto or **/*_test.go).
+// Patterns that start with // are relative to the repo root; others are relative to the given working
+// directory, which is itself relative to the repo root. Files that aren't globs are returned as-is.
+func ExpandFileGlobs(files []string, wd string) []string {
+	ret := make([]string, 0, len(files))
+	for _, file := range files {
+		if !fs.IsGlob(file) {
+			ret = append(ret, file)
+			continue
+		}
+		pattern, isRootRelative := strings.CutPrefix(file, "//")
+		if !isRootRelative && !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(wd, pattern)
+		}
+		matches := globFiles(pattern)
+		if len(matches) == 0 {
+			log.Warning("%s doesn't match any files", file)
+		}
+		ret = append(ret, matches...)
+	}
+	return ret
+}
+
+// globFiles returns all the files matching the given pattern, which is relative to the repo root.
+func globFiles(pattern string) []string {
+	// Only walk from the deepest directory that doesn't need globbing.
+	root := ""
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts[:len(parts)-1] {
+		if fs.IsGlob(part) {
+			break
+		}
+		root = strings.Join(parts[:i+1], "/")
+	}
+	if root == "" {
+		return fs.Glob(fs.HostFS, nil, ".", []string{pattern}, nil, false)
+	}
+	matches := fs.Glob(fs.HostFS, nil, root, []string{strings.TrimPrefix(pattern, root+"/")}, nil, false)
+	for i, match := range matches {
+		matches[i] = filepath.Join(root, match)
+	}
+	return matches
+}
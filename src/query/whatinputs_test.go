@@ -1,6 +1,7 @@
 package query
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -88,3 +89,22 @@ func TestWhatInputsSourceBothTargetsHidden(t *testing.T) {
 	inputLabels := whatInputs(graph.AllTargets(), "package1/file1.txt", true)
 	assert.Equal(t, []core.BuildLabel{{PackageName: "package1", Name: "_target1#srcs"}, {PackageName: "package1", Name: "target1"}}, inputLabels)
 }
+
+func TestExpandFileGlobs(t *testing.T) {
+	files := ExpandFileGlobs([]string{"//src/query/completions_test_repo/foo/*/BUILD_FILE", "BUILD_FILE", "foo/ba?/BUILD_FILE"}, "src/query/completions_test_repo")
+	assert.ElementsMatch(t, []string{
+		"src/query/completions_test_repo/foo/bar/BUILD_FILE",
+		"src/query/completions_test_repo/foo/baz/BUILD_FILE",
+		"BUILD_FILE",
+		"src/query/completions_test_repo/foo/bar/BUILD_FILE",
+		"src/query/completions_test_repo/foo/baz/BUILD_FILE",
+	}, files)
+}
+
+func TestExpandFileGlobsDoubleStar(t *testing.T) {
+	files := ExpandFileGlobs([]string{"//src/query/completions_test_repo/**/BUILD_FILE"}, "")
+	assert.Equal(t, 7, len(files))
+	for _, file := range files {
+		assert.True(t, strings.HasPrefix(file, "src/query/completions_test_repo/"), file)
+	}
+}
@@ -1,16 +1,36 @@
 package query
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/thought-machine/please/src/core"
 )
 
+// whatOutput is the JSON representation of a target that outputs a file.
+type whatOutput struct {
+	QueriedFile string `json:"queried_file,omitempty"`
+	File        string `json:"file"`
+	Target      string `json:"target"`
+	OutputPath  string `json:"output_path"`
+}
+
 // WhatOutputs prints the target responsible for producing each of the provided files
 // The targets are printed in the same order as the provided files, separated by a newline
 // Use printFiles to additionally echo the files themselves (i.e. print <file> <target>)
-func WhatOutputs(graph *core.BuildGraph, files []string, printFiles bool) {
+// If useJSON is true, they're printed as a JSON array instead.
+func WhatOutputs(graph *core.BuildGraph, files []string, printFiles, useJSON bool) {
 	targets := graph.AllTargets()
+	if useJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(whatOutputsJSON(graph, targets, files, printFiles)); err != nil {
+			log.Fatalf("failed to write JSON: %v", err)
+		}
+		return
+	}
 	for _, f := range files {
 		if t := whatOutputs(targets, f); len(t) > 0 {
 			for _, l := range t {
@@ -40,3 +60,25 @@ func whatOutputs(targets []*core.BuildTarget, file string) []core.BuildLabel {
 	}
 	return ret
 }
+
+func whatOutputsJSON(graph *core.BuildGraph, targets []*core.BuildTarget, files []string, printFiles bool) []whatOutput {
+	ret := []whatOutput{}
+	for _, f := range files {
+		labels := whatOutputs(targets, f)
+		if len(labels) == 0 {
+			log.Warning("'%s' is not a product of any current target", f)
+		}
+		for _, l := range labels {
+			out := whatOutput{
+				File:       f,
+				Target:     l.String(),
+				OutputPath: strings.TrimPrefix(strings.TrimPrefix(f, graph.TargetOrDie(l).OutDir()), "/"),
+			}
+			if printFiles {
+				out.QueriedFile = f
+			}
+			ret = append(ret, out)
+		}
+	}
+	return ret
+}
@@ -55,3 +55,15 @@ func TestDetectOutputsFilegroup(t *testing.T) {
 	targets = whatOutputs(graph.AllTargets(), "plz-out/gen/package1/out2")
 	assert.Equal(t, []core.BuildLabel{{PackageName: "package1", Name: "target1"}}, targets)
 }
+
+func TestWhatOutputsJSON(t *testing.T) {
+	graph := core.NewGraph()
+	makeTarget2(graph, "//package1:target1", false, "out1", "dir/out2")
+	makeTarget2(graph, "//package1:target2", false, "out3")
+	files := []string{"plz-out/gen/package1/dir/out2", "plz-out/gen/package1/out3", "plz-out/gen/package1/nope"}
+	assert.Equal(t, []whatOutput{
+		{File: "plz-out/gen/package1/dir/out2", Target: "//package1:target1", OutputPath: "dir/out2"},
+		{File: "plz-out/gen/package1/out3", Target: "//package1:target2", OutputPath: "out3"},
+	}, whatOutputsJSON(graph, graph.AllTargets(), files, false))
+	assert.Equal(t, "plz-out/gen/package1/out3", whatOutputsJSON(graph, graph.AllTargets(), files[1:2], true)[0].QueriedFile)
+}
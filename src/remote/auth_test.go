@@ -0,0 +1,99 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCCredentials(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "plz", id)
+		assert.Equal(t, "s3cret", secret)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%d", requests),
+			"expires_in":   3600,
+		})
+	}))
+	defer srv.Close()
+	filename := filepath.Join(t.TempDir(), "creds")
+	require.NoError(t, os.WriteFile(filename, []byte("plz:s3cret\n"), 0600))
+
+	cred, err := newOIDCCredProvider(srv.URL+"/token", filename, time.Second)
+	require.NoError(t, err)
+	md, err := cred.GetRequestMetadata(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Bearer token1"}, md)
+	// Should be cached and not refetched.
+	md, err = cred.GetRequestMetadata(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Bearer token1"}, md)
+	assert.Equal(t, 1, requests)
+	// It's refreshed a minute before it expires.
+	assert.WithinDuration(t, time.Now().Add(time.Hour-oidcExpiryMargin), cred.refreshAt, 5*time.Second)
+	cred.refreshAt = time.Now().Add(-time.Second)
+	md, err = cred.GetRequestMetadata(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Bearer token2"}, md)
+	assert.Equal(t, 2, requests)
+}
+
+func TestOIDCShortLivedCredentials(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%d", requests),
+			"expires_in":   40,
+		})
+	}))
+	defer srv.Close()
+	filename := filepath.Join(t.TempDir(), "creds")
+	require.NoError(t, os.WriteFile(filename, []byte("plz:s3cret\n"), 0600))
+
+	cred, err := newOIDCCredProvider(srv.URL+"/token", filename, time.Second)
+	require.NoError(t, err)
+	_, err = cred.GetRequestMetadata(context.Background())
+	assert.NoError(t, err)
+	// A token that lasts less than the margin is still used for most of its lifetime.
+	_, err = cred.GetRequestMetadata(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), cred.refreshAt, 5*time.Second)
+}
+
+func TestOIDCCredentialsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	filename := filepath.Join(t.TempDir(), "creds")
+	require.NoError(t, os.WriteFile(filename, []byte("plz:wrong"), 0600))
+
+	cred, err := newOIDCCredProvider(srv.URL+"/token", filename, time.Second)
+	require.NoError(t, err)
+	_, err = cred.GetRequestMetadata(context.Background())
+	assert.Error(t, err)
+}
+
+func TestOIDCInvalidCredentialsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "creds")
+	require.NoError(t, os.WriteFile(filename, []byte("nope"), 0600))
+	_, err := newOIDCCredProvider("http://localhost/token", filename, time.Second)
+	assert.Error(t, err)
+}
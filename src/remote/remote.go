@@ -393,15 +393,17 @@ func (c *Client) Run(target *core.BuildTarget) error {
 func (c *Client) build(target *core.BuildTarget) (*core.BuildMetadata, *pb.ActionResult, *pb.Digest, error) {
 	needStdout := target.PostBuildFunction != nil
 	// If we're gonna stamp the target, first check the unstamped equivalent that we store results under.
-	// This implements the rules of stamp whereby we don't force rebuilds every time e.g. the SCM revision changes.
+	// This implements the rules of stamp whereby we don't force rebuilds every time e.g. the SCM revision changes,
+	// which matches local builds where the revision isn't part of the rule hash.
 	var unstampedDigest *pb.Digest
-	if target.Stamp {
+	storeUnstamped := target.Stamp && target.Cacheable()
+	if storeUnstamped {
 		command, digest, err := c.buildAction(target, false, false, 0)
 		if err != nil {
 			return nil, nil, nil, err
 		} else if metadata, ar := c.maybeRetrieveResults(target, command, digest, false, needStdout, 0); metadata != nil {
 			c.unstampedBuildActionDigests.Put(target.Label, digest)
-			return metadata, ar, digest, nil
+			return metadata, ar, digest, c.fetchBuildWarnings(metadata, ar)
 		}
 		unstampedDigest = digest
 	}
@@ -410,7 +412,7 @@ func (c *Client) build(target *core.BuildTarget) (*core.BuildMetadata, *pb.Actio
 		return nil, nil, nil, err
 	}
 	metadata, ar, err := c.execute(target, command, stampedDigest, false, needStdout, 0)
-	if target.Stamp && err == nil {
+	if storeUnstamped && err == nil {
 		err = c.verifyActionResult(target, command, unstampedDigest, ar, c.state.Config.Remote.VerifyOutputs, false)
 		if err == nil {
 			// Store results under unstamped digest too.
@@ -425,9 +427,24 @@ func (c *Client) build(target *core.BuildTarget) (*core.BuildMetadata, *pb.Actio
 	} else {
 		c.unstampedBuildActionDigests.Put(target.Label, stampedDigest)
 	}
+	if err == nil {
+		err = c.fetchBuildWarnings(metadata, ar)
+	}
 	return metadata, ar, stampedDigest, err
 }
 
+// fetchBuildWarnings retrieves the stderr of a successful build action if it's going to be shown.
+func (c *Client) fetchBuildWarnings(metadata *core.BuildMetadata, ar *pb.ActionResult) error {
+	if len(metadata.Stderr) != 0 || ar.StderrDigest == nil {
+		return nil
+	} else if (metadata.Cached && !c.state.ReplayBuildWarnings) || (!metadata.Cached && !c.state.ShowBuildWarnings) {
+		return nil
+	}
+	b, _, err := c.client.ReadBlob(context.Background(), digest.NewFromProtoUnvalidated(ar.StderrDigest))
+	metadata.Stderr = b
+	return err
+}
+
 // Download downloads outputs for the given target.
 func (c *Client) Download(target *core.BuildTarget) error {
 	if target.Local {
@@ -652,7 +669,10 @@ func (c *Client) maybeRetrieveResults(target *core.BuildTarget, command *pb.Comm
 // execute submits an action to the remote executor and monitors its progress.
 // The returned ActionResult may be nil on failure.
 func (c *Client) execute(target *core.BuildTarget, command *pb.Command, digest *pb.Digest, isTest, needStdout bool, run int) (*core.BuildMetadata, *pb.ActionResult, error) {
-	if !isTest || (!c.state.ForceRerun && c.state.NumTestRuns == 1) {
+	// Network targets without hashes could produce anything, so are always rebuilt rather than retrieved
+	// from the cache (as for local builds). Their results are still stored so they can be downloaded.
+	uncached := !target.Cacheable() && !isTest
+	if !uncached && (!isTest || (!c.state.ForceRerun && c.state.NumTestRuns == 1)) {
 		if metadata, ar := c.maybeRetrieveResults(target, command, digest, isTest, needStdout, run); metadata != nil {
 			return metadata, ar, nil
 		}
@@ -675,7 +695,7 @@ func (c *Client) execute(target *core.BuildTarget, command *pb.Command, digest *
 	// We should skip the cache lookup (and override any existing action result) if we --rebuild, or --rerun and this is
 	// one fo the targets we're testing or building.
 	skipCacheLookup := (isTest && (c.state.ForceRerun || c.state.NumTestRuns != 1)) || (!isTest && c.state.ForceRebuild)
-	skipCacheLookup = skipCacheLookup && c.state.IsOriginalTarget(target)
+	skipCacheLookup = (skipCacheLookup && c.state.IsOriginalTarget(target)) || uncached
 
 	return c.reallyExecute(target, command, digest, needStdout, isTest, skipCacheLookup, run)
 }
@@ -60,6 +60,47 @@ func TestExecuteBuild(t *testing.T) {
 	assert.Equal(t, []byte("hello\n"), metadata.Stdout)
 }
 
+func TestStampedTargetIsRetrievedByUnstampedDigest(t *testing.T) {
+	// Stamped targets aren't rebuilt just because the revision has changed, so the second build should
+	// find the result stored under the unstamped digest rather than executing again.
+	c := newClient()
+	target := core.NewBuildTarget(core.BuildLabel{PackageName: "package", Name: "stamped"})
+	target.AddSource(core.FileLabel{File: "src1.txt", Package: "package"})
+	target.AddOutput("out2.txt")
+	target.BuildTimeout = time.Minute
+	target.PostBuildFunction = testFunction{}
+	target.Command = "echo hello && echo $SCM_REVISION > $OUT"
+	target.Stamp = true
+	require.NoError(t, c.CheckInitialised())
+	_, _, stampedDigest, err := c.build(target)
+	require.NoError(t, err)
+	executions := server.executions
+	_, _, unstampedDigest, err := c.build(target)
+	assert.NoError(t, err)
+	assert.Equal(t, executions, server.executions)
+	assert.NotEqual(t, stampedDigest.Hash, unstampedDigest.Hash)
+}
+
+func TestNetworkTargetIsNotRetrievedFromCache(t *testing.T) {
+	c := newClient()
+	target := core.NewBuildTarget(core.BuildLabel{PackageName: "package", Name: "network"})
+	target.AddSource(core.FileLabel{File: "src1.txt", Package: "package"})
+	target.AddOutput("out2.txt")
+	target.BuildTimeout = time.Minute
+	target.PostBuildFunction = testFunction{}
+	target.Command = "echo hello > $OUT"
+	target.Network = true
+	require.NoError(t, c.CheckInitialised())
+	_, ar, digest, err := c.build(target)
+	require.NoError(t, err)
+	server.actionResults[digest.Hash] = ar
+	executions := server.executions
+	metadata, _, _, err := c.build(target)
+	assert.NoError(t, err)
+	assert.False(t, metadata.Cached)
+	assert.Equal(t, executions+1, server.executions)
+}
+
 type postBuildFunction func(*core.BuildTarget, string) error //nolint:unused
 
 //nolint:unused
@@ -273,6 +314,34 @@ func TestOutDirsSetOutsOnTarget(t *testing.T) {
 	}
 }
 
+func TestBuildWarningsAreFetched(t *testing.T) {
+	c := newClientInstance("mock")
+	c.state.ShowBuildWarnings = true
+	c.state.ReplayBuildWarnings = true
+	require.NoError(t, c.CheckInitialised())
+
+	stderr := []byte("warning: unused variable\n")
+	stderrDigest := digest.NewFromBlob(stderr)
+	server.blobs[stderrDigest.Hash] = stderr
+	server.mockActionResult = &pb.ActionResult{
+		ExitCode:     0,
+		StderrDigest: stderrDigest.ToProto(),
+		ExecutionMetadata: &pb.ExecutedActionMetadata{
+			Worker:                      "kev",
+			QueuedTimestamp:             timestamppb.Now(),
+			ExecutionStartTimestamp:     timestamppb.Now(),
+			ExecutionCompletedTimestamp: timestamppb.Now(),
+		},
+	}
+
+	target := core.NewBuildTarget(core.BuildLabel{PackageName: "package", Name: "warnings"})
+	target.BuildTimeout = time.Minute
+	target.Command = "echo 'warning: unused variable' >&2"
+	metadata, _, _, err := c.build(target)
+	require.NoError(t, err)
+	assert.Equal(t, stderr, metadata.Stderr)
+}
+
 func TestDirectoryMetadataStore(t *testing.T) {
 	cacheDuration := time.Hour
 	now := time.Now().UTC()
@@ -23,6 +23,7 @@ import (
 	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -612,6 +613,23 @@ func (c *Client) dialOpts() ([]grpc.DialOption, error) {
 		grpc.WithChainUnaryInterceptor(grpc_prometheus.UnaryClientInterceptor),
 		grpc.WithChainStreamInterceptor(grpc_prometheus.StreamClientInterceptor),
 	}
+	if c.state.Config.Remote.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(c.state.Config.Remote.KeepaliveTime),
+			Timeout:             time.Duration(c.state.Config.Remote.KeepaliveTimeout),
+			PermitWithoutStream: c.state.Config.Remote.KeepalivePermitWithoutStream,
+		}))
+	}
+	if c.state.Config.Remote.OIDCTokenURL != "" {
+		if c.state.Config.Remote.TokenFile != "" {
+			return opts, fmt.Errorf("Only one of remote.tokenfile and remote.oidctokenurl can be set")
+		}
+		cred, err := newOIDCCredProvider(c.state.Config.Remote.OIDCTokenURL.String(), c.state.Config.Remote.OIDCClientCredentials, time.Duration(c.state.Config.Remote.Timeout))
+		if err != nil {
+			return opts, err
+		}
+		return append(opts, grpc.WithPerRPCCredentials(cred)), nil
+	}
 	if c.state.Config.Remote.TokenFile == "" {
 		return opts, nil
 	}
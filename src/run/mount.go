@@ -0,0 +1,51 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thought-machine/please/src/fs"
+)
+
+// A Mount is an additional path on the host that's made available in the temp directory
+// used by plz run --in_tmp_dir.
+type Mount struct {
+	// Src is the absolute path on the host.
+	Src string
+	// Dst is the path relative to the temp directory.
+	Dst string
+}
+
+// UnmarshalFlag implements the flags.Unmarshaler interface.
+func (m *Mount) UnmarshalFlag(in string) error {
+	src, dst, found := strings.Cut(in, ":")
+	if !found || src == "" || dst == "" {
+		return fmt.Errorf("invalid mount %s, must be in the form src:dst", in)
+	}
+	dst = filepath.Clean(dst)
+	if filepath.IsAbs(dst) || dst == ".." || strings.HasPrefix(dst, "../") {
+		return fmt.Errorf("invalid mount %s, destination must be a relative path within the temp directory", in)
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	m.Src = abs
+	m.Dst = dst
+	return nil
+}
+
+// link makes this mount available in the given directory. It's a symlink so it's left alone
+// when the directory is cleaned up.
+func (m Mount) link(dir string) error {
+	if _, err := os.Stat(m.Src); err != nil {
+		return fmt.Errorf("can't mount %s: %w", m.Src, err)
+	}
+	dst := filepath.Join(dir, m.Dst)
+	if err := fs.EnsureDir(dst); err != nil {
+		return err
+	}
+	return os.Symlink(m.Src, dst)
+}
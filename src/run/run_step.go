@@ -2,6 +2,7 @@
 package run
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math"
@@ -9,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -25,25 +27,31 @@ import (
 var log = logging.Log
 
 // Run implements the running part of 'plz run'.
-func Run(state *core.BuildState, label core.AnnotatedOutputLabel, args []string, remote, env, inTmp bool, dir, overrideCmd string) {
+func Run(state *core.BuildState, label core.AnnotatedOutputLabel, args []string, remote, env, inTmp bool, mounts []Mount, dir, overrideCmd string) {
 	prepareRun()
 
-	run(context.Background(), state, label, args, false, false, remote, env, false, inTmp, dir, overrideCmd)
+	run(context.Background(), state, label, args, false, false, remote, env, nil, inTmp, mounts, dir, overrideCmd)
 }
 
 // Parallel runs a series of targets in parallel.
 // Returns a relevant exit code (i.e. if at least one subprocess exited unsuccessfully, it will be
 // that code, otherwise 0 if all were successful).
 // The given context can be used to control the lifetime of the subprocesses.
-func Parallel(ctx context.Context, state *core.BuildState, labels []core.AnnotatedOutputLabel, args []string, numTasks int, outputMode process.OutputMode, remote, env, detach, inTmp bool, dir string) int {
+// If detach is true and pidFile is non-empty, the PIDs of the detached subprocesses are written to it
+// once they have all started successfully.
+func Parallel(ctx context.Context, state *core.BuildState, labels []core.AnnotatedOutputLabel, args []string, numTasks int, outputMode process.OutputMode, remote, env, detach, inTmp bool, mounts []Mount, dir, pidFile string) int {
 	prepareRun()
 
+	var detached *detachedProcesses
+	if detach {
+		detached = &detachedProcesses{}
+	}
 	var g errgroup.Group
 	g.SetLimit(numTasks)
 	for _, label := range labels {
 		label := label // capture locally
 		g.Go(func() error {
-			err := runWithOutput(ctx, state, label, args, outputMode, remote, env, detach, inTmp, dir)
+			err := runWithOutput(ctx, state, label, args, outputMode, remote, env, detached, inTmp, mounts, dir)
 			if err != nil && ctx.Err() == nil {
 				log.Error("Command failed: %s", err)
 			}
@@ -56,13 +64,44 @@ func Parallel(ctx context.Context, state *core.BuildState, labels []core.Annotat
 		}
 		return err.(*exitError).code
 	}
+	if detach && pidFile != "" {
+		if err := detached.WriteFile(pidFile); err != nil {
+			log.Error("Failed to write pid file: %s", err)
+			return 1
+		}
+	}
 	return 0
 }
 
+// detachedProcesses records the PIDs of the subprocesses that we've detached from.
+type detachedProcesses struct {
+	pids  []int
+	mutex sync.Mutex
+}
+
+// Add records a new detached process.
+func (d *detachedProcesses) Add(pid int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.pids = append(d.pids, pid)
+}
+
+// WriteFile atomically writes the PIDs to the given file, one per line.
+func (d *detachedProcesses) WriteFile(filename string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var buf bytes.Buffer
+	for _, pid := range d.pids {
+		fmt.Fprintf(&buf, "%d\n", pid)
+	}
+	return fs.WriteFile(&buf, filename, 0644)
+}
+
 // runWithOutput runs a subprocess with the given output mechanism.
-func runWithOutput(ctx context.Context, state *core.BuildState, label core.AnnotatedOutputLabel, args []string, outputMode process.OutputMode, remote, env, detach, inTmp bool, dir string) error {
+// If detached is non-nil the subprocess is detached from and its PID recorded there.
+func runWithOutput(ctx context.Context, state *core.BuildState, label core.AnnotatedOutputLabel, args []string, outputMode process.OutputMode, remote, env bool, detached *detachedProcesses, inTmp bool, mounts []Mount, dir string) error {
 	return process.RunWithOutput(outputMode, label.String(), func() ([]byte, error) {
-		out, _, err := run(ctx, state, label, args, true, outputMode != process.Default, remote, env, detach, inTmp, dir, "")
+		out, _, err := run(ctx, state, label, args, true, outputMode != process.Default, remote, env, detached, inTmp, mounts, dir, "")
 		return out, err
 	})
 }
@@ -70,11 +109,11 @@ func runWithOutput(ctx context.Context, state *core.BuildState, label core.Annot
 // Sequential runs a series of targets sequentially.
 // Returns a relevant exit code (i.e. if at least one subprocess exited unsuccessfully, it will be
 // that code, otherwise 0 if all were successful).
-func Sequential(state *core.BuildState, labels []core.AnnotatedOutputLabel, args []string, outputMode process.OutputMode, remote, env, inTmp bool, dir string) int {
+func Sequential(state *core.BuildState, labels []core.AnnotatedOutputLabel, args []string, outputMode process.OutputMode, remote, env, inTmp bool, mounts []Mount, dir string) int {
 	prepareRun()
 	for _, label := range labels {
 		log.Notice("Running %s", label)
-		if err := runWithOutput(context.Background(), state, label, args, outputMode, remote, env, false, inTmp, dir); err != nil {
+		if err := runWithOutput(context.Background(), state, label, args, outputMode, remote, env, nil, inTmp, mounts, dir); err != nil {
 			log.Error("%s", err)
 			return err.(*exitError).code
 		}
@@ -92,7 +131,8 @@ func prepareRun() {
 // If fork is true then we fork to run the target and return any error from the subprocesses.
 // If it's false this function never returns (because we either win or die; it's like
 // Game of Thrones except rather less glamorous).
-func run(ctx context.Context, state *core.BuildState, label core.AnnotatedOutputLabel, args []string, fork, quiet, remote, setenv, detach, tmpDir bool, dir, overrideCmd string) ([]byte, []byte, error) {
+// If detached is non-nil we don't wait for the subprocess, and record its PID there instead.
+func run(ctx context.Context, state *core.BuildState, label core.AnnotatedOutputLabel, args []string, fork, quiet, remote, setenv bool, detached *detachedProcesses, tmpDir bool, mounts []Mount, dir, overrideCmd string) ([]byte, []byte, error) {
 	// This is a bit strange as normally if you run a binary for another platform, this will fail. In some cases
 	// this can be quite useful though e.g. to compile a binary for a target arch, then run an .sh script to
 	// push that to docker.
@@ -120,7 +160,7 @@ func run(ctx context.Context, state *core.BuildState, label core.AnnotatedOutput
 
 	if tmpDir {
 		var err error
-		if dir, err = prepareRunDir(state, target); err != nil {
+		if dir, err = prepareRunDir(state, target, mounts); err != nil {
 			return nil, nil, err
 		}
 	}
@@ -185,14 +225,18 @@ func run(ctx context.Context, state *core.BuildState, label core.AnnotatedOutput
 		}
 		// Plain 'plz run'. One way or another we never return from the following line.
 		must(syscall.Exec(args[0], args, env), args)
-	} else if detach {
+	} else if detached != nil {
 		// Bypass the whole process management system since we explicitly aim not to manage this subprocess.
 		cmd := exec.Command(args[0], args[1:]...)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		cmd.Dir = dir
 		cmd.Env = env
-		return nil, nil, toExitError(cmd.Start(), args, nil)
+		if err := cmd.Start(); err != nil {
+			return nil, nil, toExitError(err, args, nil)
+		}
+		detached.Add(cmd.Process.Pid)
+		return nil, nil, nil
 	}
 	// Run as a normal subcommand.
 	// Note that we don't connect stdin. It doesn't make sense for multiple processes.
@@ -202,7 +246,7 @@ func run(ctx context.Context, state *core.BuildState, label core.AnnotatedOutput
 	return out, combined, toExitError(err, args, combined)
 }
 
-func prepareRunDir(state *core.BuildState, target *core.BuildTarget) (string, error) {
+func prepareRunDir(state *core.BuildState, target *core.BuildTarget, mounts []Mount) (string, error) {
 	path := filepath.Join("plz-out", "run", target.Label.Subrepo, target.Label.PackageName)
 	if err := os.MkdirAll(path, fs.DirPermissions); err != nil && !os.IsExist(err) {
 		return "", err
@@ -222,7 +266,11 @@ func prepareRunDir(state *core.BuildState, target *core.BuildTarget) (string, er
 			return "", err
 		}
 	}
-
+	for _, mount := range mounts {
+		if err := mount.link(path); err != nil {
+			return "", err
+		}
+	}
 	return path, nil
 }
 
@@ -3,6 +3,9 @@ package run
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,20 +22,33 @@ func init() {
 
 func TestSequential(t *testing.T) {
 	state, labels1, labels2 := makeState(core.DefaultConfiguration())
-	code := Sequential(state, labels1, nil, process.Quiet, false, false, false, "")
+	code := Sequential(state, labels1, nil, process.Quiet, false, false, false, nil, "")
 	assert.Equal(t, 0, code)
-	code = Sequential(state, labels2, nil, process.Default, false, false, false, "")
+	code = Sequential(state, labels2, nil, process.Default, false, false, false, nil, "")
 	assert.Equal(t, 1, code)
 }
 
 func TestParallel(t *testing.T) {
 	state, labels1, labels2 := makeState(core.DefaultConfiguration())
-	code := Parallel(context.Background(), state, labels1, nil, 5, process.Default, false, false, false, false, "")
+	code := Parallel(context.Background(), state, labels1, nil, 5, process.Default, false, false, false, false, nil, "", "")
 	assert.Equal(t, 0, code)
-	code = Parallel(context.Background(), state, labels2, nil, 5, process.Quiet, false, false, false, false, "")
+	code = Parallel(context.Background(), state, labels2, nil, 5, process.Quiet, false, false, false, false, nil, "", "")
 	assert.Equal(t, 1, code)
 }
 
+func TestParallelDetachPIDFile(t *testing.T) {
+	state, labels1, _ := makeState(core.DefaultConfiguration())
+	pidFile := filepath.Join(t.TempDir(), "pids")
+	code := Parallel(context.Background(), state, labels1, nil, 5, process.Default, false, false, true, false, nil, "", pidFile)
+	assert.Equal(t, 0, code)
+	b, err := os.ReadFile(pidFile)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Equal(t, 1, len(lines))
+	_, err = strconv.Atoi(lines[0])
+	assert.NoError(t, err)
+}
+
 func TestEnvVars(t *testing.T) {
 	config := core.DefaultConfiguration()
 	config.Build.Path = []string{"/wibble"}
@@ -71,3 +87,29 @@ func annotate(labels []core.BuildLabel) []core.AnnotatedOutputLabel {
 	}
 	return ls
 }
+
+func TestMount(t *testing.T) {
+	src := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "config.yaml"), []byte("hello"), 0644))
+	m := Mount{}
+	assert.NoError(t, m.UnmarshalFlag(src+":etc/app"))
+	assert.Equal(t, Mount{Src: src, Dst: "etc/app"}, m)
+
+	dir := t.TempDir()
+	assert.NoError(t, m.link(dir))
+	b, err := os.ReadFile(filepath.Join(dir, "etc/app/config.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+
+	// Removing the temp directory mustn't remove the host path.
+	assert.NoError(t, os.RemoveAll(dir))
+	assert.FileExists(t, filepath.Join(src, "config.yaml"))
+}
+
+func TestInvalidMount(t *testing.T) {
+	m := Mount{}
+	assert.Error(t, m.UnmarshalFlag("/etc"))
+	assert.Error(t, m.UnmarshalFlag("/etc:/etc"))
+	assert.Error(t, m.UnmarshalFlag("/etc:../etc"))
+	assert.Error(t, Mount{Src: "/does/not/exist", Dst: "x"}.link(t.TempDir()))
+}
@@ -0,0 +1,39 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/thought-machine/please/src/process"
+)
+
+// Run runs the given command in a sandbox set up the same way as it would be for a build action,
+// using the given executor. The current directory stands in for the action's working directory,
+// so it's mounted in the same place and everything else is hidden. It's intended for debugging
+// how commands behave in the sandbox; the command's exit code is returned.
+func Run(executor *process.Executor, args []string) (int, error) {
+	if runtime.GOOS != "linux" {
+		return 0, fmt.Errorf("plz sandbox is only supported on Linux")
+	} else if len(args) == 0 {
+		return 0, fmt.Errorf("plz sandbox needs a command to run after --")
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return 0, err
+	}
+	cmd := executor.ExecCommand(process.NewSandboxConfig(true, true), false, args[0], args[1:]...)
+	cmd.Env = append(append(os.Environ(), cmd.Env...), "TMP_DIR="+dir)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr.Setpgid = false // As for plz build --shell, so the command can read from the terminal.
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
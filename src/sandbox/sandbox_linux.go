@@ -90,7 +90,13 @@ func Sandbox(args []string) error {
 				{HostID: os.Getgid(), Size: 1, ContainerID: userID},
 			},
 		}
-		return execCmd.Run()
+		if err := execCmd.Run(); err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				os.Exit(exitErr.ExitCode()) // Behave as if we'd exec'd it, as below.
+			}
+			return err
+		}
+		return nil
 	}
 	err = syscall.Exec(cmd, args, env)
 	if err != nil {
@@ -0,0 +1,104 @@
+package sbom
+
+import (
+	"sort"
+	"strings"
+)
+
+// spdxLicences maps the names licences are commonly given in BUILD files onto their SPDX identifiers.
+// Keys are normalised by licenceKey.
+var spdxLicences = map[string]string{
+	"0bsd":               "0BSD",
+	"apache-1.1":         "Apache-1.1",
+	"apache-2":           "Apache-2.0",
+	"apache-2.0":         "Apache-2.0",
+	"apache-license-2.0": "Apache-2.0",
+	"apache2":            "Apache-2.0",
+	"asl-2.0":            "Apache-2.0",
+	"boost":              "BSL-1.0",
+	"bsd":                "BSD-3-Clause",
+	"bsd-2":              "BSD-2-Clause",
+	"bsd-2-clause":       "BSD-2-Clause",
+	"bsd-3":              "BSD-3-Clause",
+	"bsd-3-clause":       "BSD-3-Clause",
+	"bsl-1.0":            "BSL-1.0",
+	"cc0":                "CC0-1.0",
+	"cc0-1.0":            "CC0-1.0",
+	"epl-1.0":            "EPL-1.0",
+	"epl-2.0":            "EPL-2.0",
+	"gpl-2.0":            "GPL-2.0-only",
+	"gpl-2.0-only":       "GPL-2.0-only",
+	"gpl-2.0-or-later":   "GPL-2.0-or-later",
+	"gpl-3.0":            "GPL-3.0-only",
+	"gpl-3.0-only":       "GPL-3.0-only",
+	"gpl-3.0-or-later":   "GPL-3.0-or-later",
+	"gplv2":              "GPL-2.0-only",
+	"gplv3":              "GPL-3.0-only",
+	"isc":                "ISC",
+	"lgpl-2.1":           "LGPL-2.1-only",
+	"lgpl-2.1-only":      "LGPL-2.1-only",
+	"lgpl-2.1-or-later":  "LGPL-2.1-or-later",
+	"lgpl-3.0":           "LGPL-3.0-only",
+	"lgpl-3.0-only":      "LGPL-3.0-only",
+	"lgpl-3.0-or-later":  "LGPL-3.0-or-later",
+	"lgplv2.1":           "LGPL-2.1-only",
+	"lgplv3":             "LGPL-3.0-only",
+	"mit":                "MIT",
+	"mpl-2.0":            "MPL-2.0",
+	"mpl2":               "MPL-2.0",
+	"new-bsd":            "BSD-3-Clause",
+	"psf":                "Python-2.0",
+	"python-2.0":         "Python-2.0",
+	"simplified-bsd":     "BSD-2-Clause",
+	"the-unlicense":      "Unlicense",
+	"unlicense":          "Unlicense",
+	"zlib":               "Zlib",
+}
+
+// licenceKey normalises a licence name for lookup in spdxLicences.
+func licenceKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
+
+// licenceExpression returns the SPDX licence expression for the given licences, which a target
+// can be used under any of. Names we don't recognise become LicenseRef- identifiers, which are
+// recorded in the generator so the document can declare them.
+func (g *generator) licenceExpression(licences []string) string {
+	if len(licences) == 0 {
+		return noAssertion
+	}
+	ids := make([]string, len(licences))
+	for i, licence := range licences {
+		ids[i] = g.licenceID(licence)
+	}
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	return "(" + strings.Join(ids, " OR ") + ")"
+}
+
+// licenceID returns the SPDX identifier for a single licence.
+func (g *generator) licenceID(name string) string {
+	if id, present := spdxLicences[licenceKey(name)]; present {
+		return id
+	}
+	id := "LicenseRef-" + idChars(name)
+	if _, present := g.licenceRefs[id]; !present {
+		g.licenceRefs[id] = name
+	}
+	return id
+}
+
+// extractedLicences returns the declarations of all the LicenseRef- identifiers we've used.
+func (g *generator) extractedLicences() []*ExtractedLicence {
+	ret := make([]*ExtractedLicence, 0, len(g.licenceRefs))
+	for id, name := range g.licenceRefs {
+		ret = append(ret, &ExtractedLicence{
+			LicenseID:     id,
+			Name:          name,
+			ExtractedText: "Licence named " + name + " in the BUILD file",
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].LicenseID < ret[j].LicenseID })
+	return ret
+}
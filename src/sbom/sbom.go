@@ -0,0 +1,289 @@
+// Package sbom generates a software bill of materials for a build, in SPDX format.
+//
+// Each target becomes an SPDX package, described by its build label and the hash of the rule
+// that built it. Source files are included with their checksums and related to the targets
+// they're built into; dependencies and tools become relationships between the packages.
+package sbom
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/thought-machine/please/src/build"
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/fs"
+)
+
+// noAssertion is what SPDX uses to indicate that we don't know something.
+const noAssertion = "NOASSERTION"
+
+// A Document is the top-level SPDX document.
+type Document struct {
+	SPDXVersion       string          `json:"spdxVersion"`
+	DataLicense       string          `json:"dataLicense"`
+	SPDXID            string          `json:"SPDXID"`
+	Name              string          `json:"name"`
+	DocumentNamespace string          `json:"documentNamespace"`
+	CreationInfo      CreationInfo    `json:"creationInfo"`
+	Packages          []*Package      `json:"packages"`
+	Files             []*File         `json:"files,omitempty"`
+	Relationships     []*Relationship `json:"relationships"`
+	// Declarations of any licences that don't have an SPDX identifier.
+	HasExtractedLicensingInfos []*ExtractedLicence `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+// CreationInfo describes who created a document and when.
+type CreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+// A Package represents a single build target.
+type Package struct {
+	SPDXID           string `json:"SPDXID"`
+	Name             string `json:"name"`
+	VersionInfo      string `json:"versionInfo"`
+	DownloadLocation string `json:"downloadLocation"`
+	FilesAnalyzed    bool   `json:"filesAnalyzed"`
+	LicenseConcluded string `json:"licenseConcluded"`
+	LicenseDeclared  string `json:"licenseDeclared"`
+	CopyrightText    string `json:"copyrightText"`
+}
+
+// A File represents a single source file.
+type File struct {
+	SPDXID    string     `json:"SPDXID"`
+	FileName  string     `json:"fileName"`
+	Checksums []Checksum `json:"checksums"`
+}
+
+// A Checksum is a single checksum of a file.
+type Checksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+// An ExtractedLicence declares a licence that isn't on the SPDX licence list.
+type ExtractedLicence struct {
+	LicenseID     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+// A Relationship describes how two elements of the document are related.
+type Relationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// Write generates an SBOM for the given targets (and everything they depend on) and writes it to a file.
+func Write(state *core.BuildState, labels []core.BuildLabel, filename string) error {
+	doc, err := Generate(state, labels)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0644)
+}
+
+// Generate generates an SBOM for the given targets and everything they depend on.
+// The targets must have been built already.
+func Generate(state *core.BuildState, labels []core.BuildLabel) (*Document, error) {
+	g := &generator{
+		state: state,
+		doc: &Document{
+			SPDXVersion:       "SPDX-2.3",
+			DataLicense:       "CC0-1.0",
+			SPDXID:            "SPDXRef-DOCUMENT",
+			Name:              documentName(labels),
+			DocumentNamespace: "https://please.build/spdxdocs/" + uuid.New().String(),
+			CreationInfo: CreationInfo{
+				Created:  time.Now().UTC().Format(time.RFC3339),
+				Creators: []string{"Tool: please-" + core.PleaseVersion},
+			},
+			Packages:      []*Package{},
+			Relationships: []*Relationship{},
+		},
+		targets:     map[*core.BuildTarget]string{},
+		files:       map[string]string{},
+		ids:         map[string]bool{},
+		licenceRefs: map[string]string{},
+	}
+	for _, label := range labels {
+		id, err := g.addTarget(state.Graph.TargetOrDie(label))
+		if err != nil {
+			return nil, err
+		}
+		g.relate(g.doc.SPDXID, "DESCRIBES", id)
+	}
+	g.doc.HasExtractedLicensingInfos = g.extractedLicences()
+	return g.doc, nil
+}
+
+type generator struct {
+	state   *core.BuildState
+	doc     *Document
+	targets map[*core.BuildTarget]string // Targets we've already added, to their SPDX ids
+	files   map[string]string            // Likewise for files
+	ids     map[string]bool              // All the ids we've allocated
+	// Licences that aren't on the SPDX list, from their LicenseRef- ids to their original names
+	licenceRefs map[string]string
+}
+
+// addTarget adds a target and all its dependencies to the document. It returns the id of the target's package.
+func (g *generator) addTarget(target *core.BuildTarget) (string, error) {
+	if id, present := g.targets[target]; present {
+		return id, nil
+	}
+	id := g.newID(target.Label.String())
+	g.targets[target] = id
+	licences := g.licenceExpression(target.Licences)
+	pkg := &Package{
+		SPDXID:           id,
+		Name:             target.Label.String(),
+		VersionInfo:      hex.EncodeToString(build.RuleHash(g.state, target, false, false)),
+		DownloadLocation: noAssertion,
+		LicenseConcluded: licences,
+		LicenseDeclared:  licences,
+		CopyrightText:    noAssertion,
+	}
+	g.doc.Packages = append(g.doc.Packages, pkg)
+	if target.IsRemoteFile {
+		if urls := target.AllURLs(g.state); len(urls) > 0 {
+			pkg.DownloadLocation = urls[0]
+		}
+	} else {
+		for _, src := range target.AllSources() {
+			if _, ok := src.Label(); ok {
+				continue // These will be dependencies, which we handle below.
+			}
+			for _, path := range src.FullPaths(g.state.Graph) {
+				// SPDX has no concept of directories so we add everything in them individually.
+				if err := fs.Walk(path, func(name string, isDir bool) error {
+					if isDir {
+						return nil
+					}
+					fileID, err := g.addFile(name)
+					if err != nil {
+						return err
+					}
+					g.relate(id, "GENERATED_FROM", fileID)
+					return nil
+				}); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	tools := map[core.BuildLabel]bool{}
+	for _, tool := range target.AllTools() {
+		if l, ok := tool.Label(); ok {
+			tools[l] = true
+		}
+	}
+	for _, dep := range target.Dependencies() {
+		depID, err := g.addTarget(dep)
+		if err != nil {
+			return "", err
+		}
+		if tools[dep.Label] {
+			g.relate(depID, "BUILD_TOOL_OF", id)
+		} else {
+			g.relate(id, "DEPENDS_ON", depID)
+		}
+	}
+	return id, nil
+}
+
+// addFile adds a single source file to the document. It returns the id of the file.
+func (g *generator) addFile(path string) (string, error) {
+	if id, present := g.files[path]; present {
+		return id, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h1 := sha1.New()
+	h256 := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(h1, h256), f); err != nil {
+		return "", err
+	}
+	id := g.newID("File-" + path)
+	g.files[path] = id
+	filename := path
+	if !filepath.IsAbs(path) {
+		filename = "./" + path // SPDX likes relative paths to be explicit.
+	}
+	g.doc.Files = append(g.doc.Files, &File{
+		SPDXID:   id,
+		FileName: filename,
+		Checksums: []Checksum{
+			checksum("SHA1", h1),
+			checksum("SHA256", h256),
+		},
+	})
+	return id, nil
+}
+
+// relate adds a relationship between two elements of the document.
+func (g *generator) relate(from, relationship, to string) {
+	g.doc.Relationships = append(g.doc.Relationships, &Relationship{
+		SPDXElementID:      from,
+		RelationshipType:   relationship,
+		RelatedSPDXElement: to,
+	})
+}
+
+func checksum(algorithm string, h hash.Hash) Checksum {
+	return Checksum{Algorithm: algorithm, ChecksumValue: hex.EncodeToString(h.Sum(nil))}
+}
+
+// newID returns a new SPDX identifier for the given name. These can only contain letters, numbers, . and -
+// so different names can map onto the same one; we add a suffix if needed to keep them unique.
+func (g *generator) newID(name string) string {
+	id := "SPDXRef-" + idChars(strings.TrimLeft(name, "/"))
+	base := id
+	for i := 2; g.ids[id]; i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	g.ids[id] = true
+	return id
+}
+
+// idChars replaces anything in the given string that can't be part of an SPDX identifier with a -.
+func idChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// documentName returns a name for the document based on the targets it describes.
+func documentName(labels []core.BuildLabel) string {
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.String()
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
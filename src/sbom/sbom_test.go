@@ -0,0 +1,98 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+func TestGenerate(t *testing.T) {
+	state := core.NewDefaultBuildState()
+	lib := addTarget(state, "//src/sbom/test_data:lib", "lib.txt")
+	lib.Licences = []string{"MIT", "Apache-2.0"}
+	tool := addTarget(state, "//src/sbom/test_data:tool")
+	remote := addTarget(state, "//third_party:dep")
+	remote.IsRemoteFile = true
+	remote.AddSource(core.URLLabel("https://example.com/dep.tar.gz"))
+	bin := addTarget(state, "//src/sbom/test_data:bin", "bin.txt")
+	bin.AddTool(tool.Label)
+	for _, dep := range []*core.BuildTarget{lib, tool, remote} {
+		bin.AddDependency(dep.Label)
+	}
+	require.NoError(t, bin.ResolveDependencies(state.Graph))
+
+	doc, err := Generate(state, []core.BuildLabel{bin.Label})
+	require.NoError(t, err)
+	assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+	assert.Equal(t, "//src/sbom/test_data:bin", doc.Name)
+
+	packages := map[string]*Package{}
+	for _, pkg := range doc.Packages {
+		packages[pkg.Name] = pkg
+	}
+	require.Len(t, packages, 4)
+	assert.Equal(t, "SPDXRef-src-sbom-test-data-lib", packages["//src/sbom/test_data:lib"].SPDXID)
+	assert.Equal(t, "(MIT OR Apache-2.0)", packages["//src/sbom/test_data:lib"].LicenseConcluded)
+	assert.Equal(t, noAssertion, packages["//src/sbom/test_data:bin"].LicenseConcluded)
+	assert.Equal(t, noAssertion, packages["//src/sbom/test_data:bin"].DownloadLocation)
+	assert.Equal(t, "https://example.com/dep.tar.gz", packages["//third_party:dep"].DownloadLocation)
+	assert.NotEmpty(t, packages["//third_party:dep"].VersionInfo)
+
+	assert.Equal(t, []*File{
+		{
+			SPDXID:   "SPDXRef-File-src-sbom-test-data-bin.txt",
+			FileName: "./src/sbom/test_data/bin.txt",
+			Checksums: []Checksum{
+				{Algorithm: "SHA1", ChecksumValue: "f04f0cb468de8bd725d09747424ca855223a03ab"},
+				{Algorithm: "SHA256", ChecksumValue: "c9b0783b2b4889aae1c1a3a6eeb59dd423f87b1e2e9f8c815057683a5ad26178"},
+			},
+		},
+		{
+			SPDXID:   "SPDXRef-File-src-sbom-test-data-lib.txt",
+			FileName: "./src/sbom/test_data/lib.txt",
+			Checksums: []Checksum{
+				{Algorithm: "SHA1", ChecksumValue: "f1d8797ad007861ca648e727ae27c84481ded933"},
+				{Algorithm: "SHA256", ChecksumValue: "aee312bbbae1cb5601a20ed4f0aef33f4d02aebcc738b6f48b7a043fdc102f03"},
+			},
+		},
+	}, doc.Files)
+
+	bID := packages["//src/sbom/test_data:bin"].SPDXID
+	assert.ElementsMatch(t, []*Relationship{
+		{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: bID},
+		{SPDXElementID: bID, RelationshipType: "GENERATED_FROM", RelatedSPDXElement: "SPDXRef-File-src-sbom-test-data-bin.txt"},
+		{SPDXElementID: bID, RelationshipType: "DEPENDS_ON", RelatedSPDXElement: "SPDXRef-src-sbom-test-data-lib"},
+		{SPDXElementID: "SPDXRef-src-sbom-test-data-lib", RelationshipType: "GENERATED_FROM", RelatedSPDXElement: "SPDXRef-File-src-sbom-test-data-lib.txt"},
+		{SPDXElementID: "SPDXRef-src-sbom-test-data-tool", RelationshipType: "BUILD_TOOL_OF", RelatedSPDXElement: bID},
+		{SPDXElementID: bID, RelationshipType: "DEPENDS_ON", RelatedSPDXElement: "SPDXRef-third-party-dep"},
+	}, doc.Relationships)
+}
+
+func TestLicenceExpression(t *testing.T) {
+	g := &generator{licenceRefs: map[string]string{}}
+	assert.Equal(t, noAssertion, g.licenceExpression(nil))
+	assert.Equal(t, "Apache-2.0", g.licenceExpression([]string{"Apache 2.0"}))
+	assert.Equal(t, "(BSD-3-Clause OR LicenseRef-My-Licence)", g.licenceExpression([]string{"bsd-3-clause", "My Licence"}))
+	assert.Equal(t, []*ExtractedLicence{
+		{LicenseID: "LicenseRef-My-Licence", Name: "My Licence", ExtractedText: "Licence named My Licence in the BUILD file"},
+	}, g.extractedLicences())
+}
+
+func TestUniqueIDs(t *testing.T) {
+	g := &generator{ids: map[string]bool{}}
+	assert.Equal(t, "SPDXRef-a-b-c", g.newID("//a:b-c"))
+	assert.Equal(t, "SPDXRef-a-b-c-2", g.newID("//a/b:c"))
+	assert.Equal(t, "SPDXRef-a-b-c-3", g.newID("//a:b_c"))
+}
+
+func addTarget(state *core.BuildState, label string, srcs ...string) *core.BuildTarget {
+	target := core.NewBuildTarget(core.ParseBuildLabel(label, ""))
+	for _, src := range srcs {
+		target.AddSource(core.FileLabel{File: src, Package: target.Label.PackageName})
+	}
+	state.Graph.AddTarget(target)
+	return target
+}
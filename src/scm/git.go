@@ -68,18 +68,41 @@ func (g *git) ChangesIn(diffSpec string, relativeTo string) []string {
 		relativeTo = g.repoRoot
 	}
 	files := make([]string, 0)
-	command := []string{"diff-tree", "--no-commit-id", "--name-only", "-r", diffSpec}
+	command := append([]string{"diff-tree", "--no-commit-id", "--name-only", "-r"}, g.diffTreeRevisions(diffSpec)...)
 	out, err := exec.Command("git", command...).CombinedOutput()
 	if err != nil {
 		log.Fatalf("unable to determine changes: %s\nOutput:\n%s", err, string(out))
 	}
 	output := strings.Split(string(out), "\n")
 	for _, o := range output {
-		files = append(files, g.fixGitRelativePath(strings.TrimSpace(o), relativeTo))
+		if o = strings.TrimSpace(o); o != "" {
+			files = append(files, g.fixGitRelativePath(o, relativeTo))
+		}
 	}
 	return files
 }
 
+// diffTreeRevisions converts a revision spec into the arguments to pass to git diff-tree.
+// diff-tree understands single revisions and A..B ranges itself, but not the symmetric A...B form,
+// which we convert into a diff between B and the merge base of the two (as git diff does).
+func (g *git) diffTreeRevisions(diffSpec string) []string {
+	from, to, found := strings.Cut(diffSpec, "...")
+	if !found {
+		return []string{diffSpec}
+	}
+	if from == "" {
+		from = "HEAD"
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+	out, err := exec.Command("git", "merge-base", from, to).CombinedOutput()
+	if err != nil {
+		log.Fatalf("unable to determine merge base of %s and %s: %s\nOutput:\n%s", from, to, err, string(out))
+	}
+	return []string{strings.TrimSpace(string(out)), to}
+}
+
 // ChangedFiles returns a list of modified files since the given commit, optionally including untracked files.
 func (g *git) ChangedFiles(fromCommit string, includeUntracked bool, relativeTo string) []string {
 	if relativeTo == "" {
@@ -249,6 +272,14 @@ func (g *git) CurrentRevDate(format string) string {
 	return t.Format(format)
 }
 
+func (g *git) RemoteURL(name string) (string, error) {
+	out, err := exec.Command("git", "remote", "get-url", name).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git remote get-url %s failed: %s\nOutput:\n%s", name, err, string(out))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 func (g *git) AreIgnored(files ...string) bool {
 	if unignored := g.getUnIgnored(files...); len(unignored) == 0 {
 		return true
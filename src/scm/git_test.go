@@ -2,9 +2,12 @@ package scm
 
 import (
 	"os"
+	"os/exec"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseChangedLines(t *testing.T) {
@@ -22,3 +25,32 @@ func TestParseChangedLines(t *testing.T) {
 		"tools/please_pex/behave.py":                                          {2, 3, 10, 11, 12, 13, 14, 15, 16, 17, 24, 25, 26, 27, 28, 29, 30, 31, 32},
 	}, m)
 }
+
+func TestDiffTreeRevisions(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	run := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q", "-b", "main")
+	run("commit", "-q", "--allow-empty", "-m", "base")
+	base := run("rev-parse", "HEAD")
+	run("checkout", "-q", "-b", "feature")
+	run("commit", "-q", "--allow-empty", "-m", "feature")
+	run("checkout", "-q", "main")
+	run("commit", "-q", "--allow-empty", "-m", "main")
+	run("checkout", "-q", "feature")
+
+	g := &git{repoRoot: dir}
+	assert.Equal(t, []string{"main"}, g.diffTreeRevisions("main"))
+	assert.Equal(t, []string{"main..feature"}, g.diffTreeRevisions("main..feature"))
+	assert.Equal(t, []string{base, "feature"}, g.diffTreeRevisions("main...feature"))
+	assert.Equal(t, []string{base, "HEAD"}, g.diffTreeRevisions("main..."))
+	assert.Equal(t, []string{base, "main"}, g.diffTreeRevisions("...main"))
+}
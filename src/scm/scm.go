@@ -38,6 +38,8 @@ type SCM interface {
 	CurrentRevDate(format string) string
 	// AreIgnored returns whether the files are all ignored or not
 	AreIgnored(files ...string) bool
+	// RemoteURL returns the URL of the given remote.
+	RemoteURL(name string) (string, error)
 }
 
 // New returns a new SCM instance for this repo root.
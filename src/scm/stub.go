@@ -47,3 +47,7 @@ func (s *stub) CurrentRevDate(format string) string {
 func (s *stub) AreIgnored(files ...string) bool {
 	return false
 }
+
+func (s *stub) RemoteURL(name string) (string, error) {
+	return "", fmt.Errorf("unknown SCM, can't get the URL of remote %s", name)
+}
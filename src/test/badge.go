@@ -0,0 +1,71 @@
+package test
+
+import (
+	"bytes"
+	_ "embed" // needed for //go:embed
+	"fmt"
+	"text/template"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/fs"
+)
+
+//go:embed badge.svg.tmpl
+var badgeTemplate string
+
+var badgeTmpl = template.Must(template.New("badge").Parse(badgeTemplate))
+
+// Colours for the badge, as used by shields.io.
+const (
+	badgeRed    = "#e05d44"
+	badgeYellow = "#dfb317"
+	badgeGreen  = "#4c1"
+)
+
+// These are approximations of the rendered text widths; they don't have to be exact, but they
+// do have to be deterministic so the badge is stable.
+const (
+	badgeLabelWidth = 61
+	badgeCharWidth  = 7
+	badgePadding    = 10
+)
+
+// WriteCoverageBadgeOrDie writes an SVG badge showing the total coverage to the given file. Dies on failure.
+// Coverage below the first threshold is shown in red, below the second in yellow, and otherwise in green.
+func WriteCoverageBadgeOrDie(coverage core.TestCoverage, filename string, thresholds []int) {
+	if err := fs.WriteFile(bytes.NewReader(coverageBadge(getStats(coverage).TotalCoverage, thresholds)), filename, 0644); err != nil {
+		log.Fatalf("Failed to write coverage badge to %s: %s", filename, err)
+	}
+}
+
+// coverageBadge returns the SVG for a badge showing the given coverage percentage.
+// The percentage is rounded down so the badge doesn't claim more coverage than there is.
+func coverageBadge(percentage float32, thresholds []int) []byte {
+	pct := int(percentage)
+	colour := badgeGreen
+	if pct < thresholds[0] {
+		colour = badgeRed
+	} else if pct < thresholds[1] {
+		colour = badgeYellow
+	}
+	message := fmt.Sprintf("%d%%", pct)
+	messageWidth := len(message)*badgeCharWidth + badgePadding
+	var buf bytes.Buffer
+	if err := badgeTmpl.Execute(&buf, struct {
+		Message                         string
+		Colour                          string
+		Width, LabelWidth, MessageWidth int
+		LabelX, MessageX                float64
+	}{
+		Message:      message,
+		Colour:       colour,
+		Width:        badgeLabelWidth + messageWidth,
+		LabelWidth:   badgeLabelWidth,
+		MessageWidth: messageWidth,
+		LabelX:       badgeLabelWidth / 2.0,
+		MessageX:     badgeLabelWidth + float64(messageWidth)/2.0,
+	}); err != nil {
+		panic(err) // Can only happen if the template is broken.
+	}
+	return buf.Bytes()
+}
@@ -48,7 +48,7 @@ func parseTestCoverage(target *core.BuildTarget, data []byte, run int) (*core.Te
 // tests, so it's important that we identify anything with zero coverage here.
 func AddOriginalTargetsToCoverage(state *core.BuildState, includeAllFiles bool) {
 	recordedCoverage := state.Coverage
-	state.Coverage = core.TestCoverage{Tests: recordedCoverage.Tests, Files: map[string][]core.LineCoverage{}}
+	state.Coverage = core.TestCoverage{Tests: recordedCoverage.Tests, Files: map[string][]core.LineCoverage{}, BranchCoverage: map[string][]int{}}
 	mergeCoverage(state, recordedCoverage, collectCoverageFiles(state, includeAllFiles))
 }
 
@@ -99,6 +99,9 @@ func mergeCoverage(state *core.BuildState, recordedCoverage core.TestCoverage, c
 		if coverageFiles[file] {
 			state.Coverage.Files[file] = coverage
 			doneFiles[file] = true
+			if branches, present := recordedCoverage.BranchCoverage[file]; present {
+				state.Coverage.BranchCoverage[file] = branches
+			}
 		}
 	}
 	// For any files left over now, enter them in as 100% uncovered.
@@ -132,6 +135,9 @@ func WriteCoverageToFileOrDie(coverage core.TestCoverage, filename string, incre
 	}
 
 	out.Files = convertCoverage(coverage.Files, allowedFiles)
+	if len(coverage.BranchCoverage) > 0 {
+		out.Branches = coverage.BranchCoverage
+	}
 	out.Stats = getStats(coverage)
 	out.Stats.Incremental = incrementalStats
 	out.Stats.CoverageByDirectory = getDirectoryCoverage(coverage)
@@ -228,9 +234,10 @@ func convertCoverage(in map[string][]core.LineCoverage, allowedFiles []string) m
 
 // Used to prepare core.TestCoverage objects for JSON marshalling.
 type jsonCoverage struct {
-	Tests map[string]map[string]string `json:"tests"`
-	Files map[string]string            `json:"files"`
-	Stats stats                        `json:"stats"`
+	Tests    map[string]map[string]string `json:"tests"`
+	Files    map[string]string            `json:"files"`
+	Branches map[string][]int             `json:"branches,omitempty"`
+	Stats    stats                        `json:"stats"`
 }
 
 // stats is a struct describing summarised coverage stats.
@@ -247,6 +254,17 @@ type IncrementalStats struct {
 	ModifiedLines int     `json:"modified_lines"`
 	CoveredLines  int     `json:"covered_lines"`
 	Percentage    float32 `json:"percentage"`
+	MinPercentage float32 `json:"min_percentage,omitempty"`
+	Passed        *bool   `json:"passed,omitempty"`
+}
+
+// CheckThreshold records the given minimum percentage in the stats and returns true if they meet it.
+// Stats with no modified lines always pass, since there's nothing to cover.
+func (stats *IncrementalStats) CheckThreshold(minPercentage float32) bool {
+	passed := stats.ModifiedLines == 0 || stats.Percentage >= minPercentage
+	stats.MinPercentage = minPercentage
+	stats.Passed = &passed
+	return passed
 }
 
 // RemoveFilesFromCoverage removes any files with extensions matching the given set from coverage.
@@ -257,9 +275,11 @@ func RemoveFilesFromCoverage(coverage core.TestCoverage, extensions []string, gl
 	}
 	removeFilesFromCoverage(coverage.Files, extensions)
 	removeGlobsFromCoverage(coverage.Files, globs)
+	removeFilesFromCoverage(coverage.BranchCoverage, extensions)
+	removeGlobsFromCoverage(coverage.BranchCoverage, globs)
 }
 
-func removeFilesFromCoverage(files map[string][]core.LineCoverage, extensions []string) {
+func removeFilesFromCoverage[T any](files map[string]T, extensions []string) {
 	for filename := range files {
 		for _, ext := range extensions {
 			if strings.HasSuffix(filename, ext) {
@@ -269,7 +289,7 @@ func removeFilesFromCoverage(files map[string][]core.LineCoverage, extensions []
 	}
 }
 
-func removeGlobsFromCoverage(files map[string][]core.LineCoverage, globs []string) {
+func removeGlobsFromCoverage[T any](files map[string]T, globs []string) {
 	for filename := range files {
 		for _, glob := range globs {
 			if ok, _ := fs.Match(glob, filename); ok {
@@ -279,6 +299,31 @@ func removeGlobsFromCoverage(files map[string][]core.LineCoverage, globs []strin
 	}
 }
 
+// FilesBelowCoverageThreshold returns the files whose line coverage is below the given percentage,
+// skipping any that match one of the exclude globs. The returned files are sorted.
+func FilesBelowCoverageThreshold(coverage core.TestCoverage, threshold int, excludes []string) []string {
+	var files []string
+	for _, file := range coverage.OrderedFiles() {
+		if matchesAnyGlob(file, excludes) {
+			continue
+		}
+		covered, total := CountCoverage(coverage.Files[file])
+		if total > 0 && 100*covered < threshold*total {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func matchesAnyGlob(filename string, globs []string) bool {
+	for _, glob := range globs {
+		if ok, _ := fs.Match(glob, filename); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // CalculateIncrementalStats works out incremental coverage statistics based on a set of changed lines from files.
 func CalculateIncrementalStats(state *core.BuildState, lines map[string][]int) *IncrementalStats {
 	return calculateIncrementalStats(state, state.Coverage, lines, collectCoverageFiles(state, true))
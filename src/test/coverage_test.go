@@ -1,10 +1,13 @@
 package test
 
 import (
+	"encoding/xml"
+	"strings"
 	"testing"
 
 	"github.com/peterebden/tools/cover"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/thought-machine/please/src/core"
 )
@@ -156,6 +159,17 @@ func TestParseBlocks(t *testing.T) {
 	assertLine(t, lines, 3, core.Covered)
 }
 
+func TestGoBranchCoverage(t *testing.T) {
+	coverage, err := parseTestCoverageFile(target, goCoverageFile, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 0, 0, 0, 0, 0, 0, 0}, coverage.BranchCoverage["src/core/file_label.go"])
+	assert.Equal(t, []int{1, 0, 3}, blockCounts([]cover.ProfileBlock{
+		{StartLine: 2, EndLine: 3, Count: 1},
+		{StartLine: 4, EndLine: 5, Count: 0},
+		{StartLine: 6, EndLine: 7, Count: 3},
+	}))
+}
+
 func assertLine(t *testing.T, lines []core.LineCoverage, i int, expected core.LineCoverage) {
 	t.Helper()
 	i-- // 1-indexed
@@ -244,6 +258,11 @@ func TestIncrementalStats(t *testing.T) {
 	assert.Equal(t, 2, stats.ModifiedLines)
 	assert.Equal(t, 1, stats.CoveredLines)
 	assert.EqualValues(t, 50.0, stats.Percentage)
+	assert.True(t, stats.CheckThreshold(50))
+	assert.False(t, stats.CheckThreshold(60))
+	assert.False(t, *stats.Passed)
+	assert.EqualValues(t, 60.0, stats.MinPercentage)
+	assert.True(t, (&IncrementalStats{}).CheckThreshold(100))
 }
 
 func TestGetDirectoryCoverage(t *testing.T) {
@@ -266,3 +285,67 @@ func TestGetDirectoryCoverage(t *testing.T) {
 	}
 	assert.Equal(t, expectedDirCoverage, dirCoverage)
 }
+
+func TestFilesBelowCoverageThreshold(t *testing.T) {
+	cov := core.TestCoverage{
+		Files: map[string][]core.LineCoverage{
+			"src/core/a.go":            {core.Uncovered, core.Covered, core.Covered, core.Covered},
+			"src/core/b.go":            {core.Uncovered, core.Uncovered, core.Covered, core.NotExecutable},
+			"src/core/c.pb.go":         {core.Uncovered, core.Uncovered},
+			"src/core/testdata/d.go":   {core.Uncovered},
+			"src/core/not_executed.go": {core.NotExecutable},
+		},
+	}
+	assert.Equal(t, []string{"src/core/b.go"}, FilesBelowCoverageThreshold(cov, 75, []string{"**/*.pb.go", "**/testdata/**"}))
+	assert.Equal(t, []string{"src/core/testdata/d.go", "src/core/a.go", "src/core/b.go", "src/core/c.pb.go"}, FilesBelowCoverageThreshold(cov, 80, nil))
+}
+
+func TestCoverageResultToXML(t *testing.T) {
+	cov := core.TestCoverage{
+		Files: map[string][]core.LineCoverage{
+			"src/core/a.go": {core.NotExecutable, core.Covered, core.Uncovered, core.Covered},
+			"src/core/b.go": {core.Covered},
+			"src/fs/c.go":   {core.Uncovered, core.NotExecutable},
+		},
+		BranchCoverage: map[string][]int{
+			"src/core/a.go": {2, 0, 1, 0},
+		},
+	}
+	b := coverageResultToXML(nil, cov)
+	assert.True(t, strings.Contains(string(b), "<!DOCTYPE coverage"))
+	report := coverageType{}
+	require.NoError(t, xml.Unmarshal(b, &report))
+	assert.Equal(t, 3, report.LinesCovered)
+	assert.Equal(t, 5, report.LinesValid)
+	assert.Equal(t, 0.6, report.LineRate)
+	assert.Equal(t, 0, report.BranchesCovered, "block counts aren't reported as branches")
+	assert.Equal(t, 0, report.BranchesValid)
+	assert.Equal(t, 0.0, report.BranchRate)
+	require.Equal(t, 2, len(report.Packages))
+	p := report.Packages[0]
+	assert.Equal(t, "src/core", p.Name)
+	assert.Equal(t, 0.75, p.LineRate)
+	require.Equal(t, 2, len(p.Classes))
+	assert.Equal(t, "a.go", p.Classes[0].Name)
+	assert.Equal(t, "src/core/a.go", p.Classes[0].Filename)
+	assert.Equal(t, []line{{Number: 2, Hits: 1}, {Number: 3, Hits: 0}, {Number: 4, Hits: 1}}, p.Classes[0].Lines)
+	assert.Equal(t, 0.0, p.Classes[0].BranchRate)
+	assert.Equal(t, "src/fs", report.Packages[1].Name)
+	assert.Equal(t, 0.0, report.Packages[1].LineRate)
+}
+
+func TestCoverageBadge(t *testing.T) {
+	thresholds := []int{50, 80}
+	badge := string(coverageBadge(85.9, thresholds))
+	assert.Contains(t, badge, `aria-label="coverage: 85%"`)
+	assert.Contains(t, badge, `fill="#4c1"`)
+	assert.Contains(t, badge, `<svg xmlns="http://www.w3.org/2000/svg" width="92" height="20"`)
+	assert.Contains(t, badge, `<text x="76.5" y="14">85%</text>`)
+	// Should be stable for the same percentage.
+	assert.Equal(t, badge, string(coverageBadge(85.1, thresholds)))
+
+	assert.Contains(t, string(coverageBadge(79.9, thresholds)), `fill="#dfb317"`)
+	assert.Contains(t, string(coverageBadge(49, thresholds)), `fill="#e05d44"`)
+	assert.Contains(t, string(coverageBadge(100, thresholds)), `aria-label="coverage: 100%"`)
+	assert.Contains(t, string(coverageBadge(65, []int{60, 70})), `fill="#dfb317"`)
+}
@@ -0,0 +1,215 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/scm"
+)
+
+// GitHub only accepts this many annotations per request to the checks API; any more have to be
+// sent in subsequent updates to the check run.
+const maxAnnotationsPerRequest = 50
+
+// githubTimeout is the timeout for each request to the GitHub API.
+const githubTimeout = 30 * time.Second
+
+// checkRun is the request body for creating or updating a check run.
+type checkRun struct {
+	Name       string         `json:"name,omitempty"`
+	HeadSHA    string         `json:"head_sha,omitempty"`
+	Status     string         `json:"status,omitempty"`
+	Conclusion string         `json:"conclusion,omitempty"`
+	Output     checkRunOutput `json:"output"`
+}
+
+type checkRunOutput struct {
+	Title       string               `json:"title"`
+	Summary     string               `json:"summary"`
+	Annotations []checkRunAnnotation `json:"annotations,omitempty"`
+}
+
+type checkRunAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title"`
+	Message         string `json:"message"`
+}
+
+// PostGitHubCheck posts the coverage results as a check run on the current commit via the GitHub
+// checks API. Files below the given threshold are annotated as failures.
+// The repo is taken from the origin remote and the token from $GITHUB_TOKEN. $GITHUB_API_URL can be
+// set to use GitHub Enterprise.
+func PostGitHubCheck(coverage core.TestCoverage, threshold int, excludes []string) error {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		return fmt.Errorf("GITHUB_TOKEN must be set to post a GitHub check")
+	}
+	repoScm := scm.New(core.RepoRoot)
+	if repoScm == nil {
+		return fmt.Errorf("can't determine the GitHub repository: unknown SCM")
+	}
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	host, err := githubHost(apiURL)
+	if err != nil {
+		return err
+	}
+	remote, err := repoScm.RemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	repo, err := parseGitHubRemote(remote, host)
+	if err != nil {
+		return err
+	}
+	// In GitHub Actions this is the commit that the workflow is running against, which isn't necessarily HEAD.
+	sha := os.Getenv("GITHUB_SHA")
+	if sha == "" {
+		sha = repoScm.CurrentRevIdentifier(true)
+	}
+	client := &http.Client{Timeout: githubTimeout}
+	return postCheckRun(client, apiURL, repo, token, coverageCheckRun(coverage, sha, threshold, excludes))
+}
+
+// coverageCheckRun creates the check run describing a set of coverage results.
+func coverageCheckRun(coverage core.TestCoverage, sha string, threshold int, excludes []string) *checkRun {
+	totalCoverage := getStats(coverage).TotalCoverage
+	run := &checkRun{
+		Name:       "Coverage",
+		HeadSHA:    sha,
+		Status:     "completed",
+		Conclusion: "success",
+		Output: checkRunOutput{
+			Title: fmt.Sprintf("%.1f%% line coverage", totalCoverage),
+		},
+	}
+	if threshold <= 0 {
+		run.Output.Summary = fmt.Sprintf("Total line coverage is %.1f%% across %d files.", totalCoverage, len(coverage.Files))
+		return run
+	}
+	files := FilesBelowCoverageThreshold(coverage, threshold, excludes)
+	run.Output.Summary = fmt.Sprintf("Total line coverage is %.1f%% across %d files. %d files are below the minimum of %d%%.", totalCoverage, len(coverage.Files), len(files), threshold)
+	if len(files) > 0 {
+		run.Conclusion = "failure"
+	}
+	for _, file := range files {
+		lines := coverage.Files[file]
+		covered, total := CountCoverage(lines)
+		start, end := coverableLineRange(lines)
+		run.Output.Annotations = append(run.Output.Annotations, checkRunAnnotation{
+			Path:            file,
+			StartLine:       start,
+			EndLine:         end,
+			AnnotationLevel: "failure",
+			Title:           "Insufficient coverage",
+			Message:         fmt.Sprintf("%d of %d lines (%.1f%%) are covered, below the minimum of %d%%", covered, total, 100.0*float32(covered)/float32(total), threshold),
+		})
+	}
+	return run
+}
+
+// coverableLineRange returns the first and last coverable lines of a file (1-indexed).
+func coverableLineRange(lines []core.LineCoverage) (int, int) {
+	start, end := 0, 0
+	for i, line := range lines {
+		if line != core.NotExecutable {
+			if start == 0 {
+				start = i + 1
+			}
+			end = i + 1
+		}
+	}
+	return start, end
+}
+
+// postCheckRun creates a check run, then sends any annotations that didn't fit in the initial request.
+func postCheckRun(client *http.Client, apiURL, repo, token string, run *checkRun) error {
+	annotations := run.Output.Annotations
+	if len(annotations) > maxAnnotationsPerRequest {
+		run.Output.Annotations = annotations[:maxAnnotationsPerRequest]
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := githubRequest(client, http.MethodPost, apiURL+"/repos/"+repo+"/check-runs", token, run, &created); err != nil {
+		return err
+	}
+	for i := maxAnnotationsPerRequest; i < len(annotations); i += maxAnnotationsPerRequest {
+		update := &checkRun{Output: run.Output}
+		update.Output.Annotations = annotations[i:min(i+maxAnnotationsPerRequest, len(annotations))]
+		if err := githubRequest(client, http.MethodPatch, fmt.Sprintf("%s/repos/%s/check-runs/%d", apiURL, repo, created.ID), token, update, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// githubRequest sends a single request to the GitHub API, optionally decoding the response into out.
+func githubRequest(client *http.Client, method, url, token string, body, out interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s failed: %s: %s", method, url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// githubHost returns the host that repositories are served from for the given GitHub API URL.
+// That's github.com for api.github.com; GitHub Enterprise serves its API from the same host under /api/v3.
+func githubHost(apiURL string) (string, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid GitHub API URL %s: %w", apiURL, err)
+	} else if u.Host == "" {
+		return "", fmt.Errorf("invalid GitHub API URL %s: no host", apiURL)
+	}
+	return strings.TrimPrefix(u.Host, "api."), nil
+}
+
+// parseGitHubRemote returns the owner/repo for a remote URL on the given GitHub host, which can be
+// either HTTPS or SSH.
+func parseGitHubRemote(remote, host string) (string, error) {
+	repo := remote
+	for _, prefix := range []string{"https://" + host + "/", "http://" + host + "/", "ssh://git@" + host + "/", "git@" + host + ":"} {
+		repo = strings.TrimPrefix(repo, prefix)
+	}
+	if repo == remote {
+		return "", fmt.Errorf("%s doesn't look like a repository on %s", remote, host)
+	}
+	repo = strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	if strings.Count(repo, "/") != 1 || strings.HasPrefix(repo, "/") || strings.HasSuffix(repo, "/") {
+		return "", fmt.Errorf("%s doesn't look like a repository on %s", remote, host)
+	}
+	return repo, nil
+}
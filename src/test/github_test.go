@@ -0,0 +1,95 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+func TestParseGitHubRemote(t *testing.T) {
+	for _, remote := range []string{
+		"https://github.com/thought-machine/please",
+		"https://github.com/thought-machine/please.git",
+		"git@github.com:thought-machine/please.git",
+		"ssh://git@github.com/thought-machine/please.git",
+	} {
+		repo, err := parseGitHubRemote(remote, "github.com")
+		assert.NoError(t, err, remote)
+		assert.Equal(t, "thought-machine/please", repo, remote)
+	}
+	_, err := parseGitHubRemote("https://gitlab.com/thought-machine/please.git", "github.com")
+	assert.Error(t, err)
+	_, err = parseGitHubRemote("https://github.com/thought-machine", "github.com")
+	assert.Error(t, err)
+	repo, err := parseGitHubRemote("git@ghe.example.com:thought-machine/please.git", "ghe.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "thought-machine/please", repo)
+	_, err = parseGitHubRemote("git@github.com:thought-machine/please.git", "ghe.example.com")
+	assert.Error(t, err)
+}
+
+func TestGitHubHost(t *testing.T) {
+	host, err := githubHost("https://api.github.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "github.com", host)
+	host, err = githubHost("https://ghe.example.com/api/v3")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghe.example.com", host)
+	_, err = githubHost("not a url")
+	assert.Error(t, err)
+}
+
+func TestCoverageCheckRun(t *testing.T) {
+	cov := core.TestCoverage{
+		Files: map[string][]core.LineCoverage{
+			"src/core/a.go": {core.Uncovered, core.Covered, core.Covered, core.Covered},
+			"src/core/b.go": {core.NotExecutable, core.Uncovered, core.Covered, core.Uncovered, core.NotExecutable},
+		},
+	}
+	run := coverageCheckRun(cov, "abc123", 75, nil)
+	assert.Equal(t, "abc123", run.HeadSHA)
+	assert.Equal(t, "failure", run.Conclusion)
+	assert.Equal(t, "57.1% line coverage", run.Output.Title)
+	assert.Equal(t, []checkRunAnnotation{{
+		Path:            "src/core/b.go",
+		StartLine:       2,
+		EndLine:         4,
+		AnnotationLevel: "failure",
+		Title:           "Insufficient coverage",
+		Message:         "1 of 3 lines (33.3%) are covered, below the minimum of 75%",
+	}}, run.Output.Annotations)
+
+	run = coverageCheckRun(cov, "abc123", 0, nil)
+	assert.Equal(t, "success", run.Conclusion)
+	assert.Empty(t, run.Output.Annotations)
+}
+
+func TestPostCheckRun(t *testing.T) {
+	var annotations []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		run := &checkRun{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(run))
+		annotations = append(annotations, len(run.Output.Annotations))
+		if r.Method == http.MethodPost {
+			assert.Equal(t, "/repos/thought-machine/please/check-runs", r.URL.Path)
+			fmt.Fprintf(w, `{"id": 42}`)
+		} else {
+			assert.Equal(t, http.MethodPatch, r.Method)
+			assert.Equal(t, "/repos/thought-machine/please/check-runs/42", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	run := &checkRun{Name: "Coverage", Output: checkRunOutput{Annotations: make([]checkRunAnnotation, 120)}}
+	err := postCheckRun(server.Client(), server.URL, "thought-machine/please", "token", run)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{50, 50, 20}, annotations)
+}
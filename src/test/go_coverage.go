@@ -25,6 +25,7 @@ func parseGoCoverageResults(target *core.BuildTarget, coverage *core.TestCoverag
 	}
 	for _, profile := range profiles {
 		coverage.Files[profile.FileName] = parseBlocks(profile.Blocks)
+		coverage.BranchCoverage[profile.FileName] = blockCounts(profile.Blocks)
 	}
 	coverage.Tests[target.Label] = coverage.Files
 	return nil
@@ -47,3 +48,14 @@ func parseBlocks(blocks []cover.ProfileBlock) []core.LineCoverage {
 	}
 	return ret
 }
+
+// blockCounts returns the hit counts of each basic block. These aren't quite branch counts, but are
+// as close as Go's coverage gets. They're only ever 0 or 1 unless the test was built with
+// -covermode=count or atomic.
+func blockCounts(blocks []cover.ProfileBlock) []int {
+	ret := make([]int, len(blocks))
+	for i, block := range blocks {
+		ret[i] = block.Count
+	}
+	return ret
+}
@@ -6,13 +6,16 @@ import (
 	"encoding/xml"
 	"math"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/thought-machine/please/src/cli"
 	"github.com/thought-machine/please/src/core"
 )
 
+// coberturaDoctype is the DTD that Cobertura reports declare, which some consumers look for.
+const coberturaDoctype = "<!DOCTYPE coverage SYSTEM \"http://cobertura.sourceforge.net/xml/coverage-04.dtd\">\n"
+
 func parseXMLCoverageResults(target *core.BuildTarget, coverage *core.TestCoverage, data []byte) error {
 	xcoverage := xmlCoverage{}
 	if err := xml.Unmarshal(data, &xcoverage); err != nil {
@@ -44,67 +47,57 @@ func parseXMLLines(lines []xmlCoverageLine) []core.LineCoverage {
 	return ret
 }
 
-// Covert the Coverage result to XML bytes, ready to be write to file
+// coverageResultToXML converts the coverage results to a Cobertura XML report.
+// Each directory becomes a package and each file within it a class, which is how Please's own
+// packages map onto Cobertura's structure. Branch data is left empty; the only counts we have
+// are of Go's basic blocks, which aren't branches.
 func coverageResultToXML(sources []core.BuildLabel, coverage core.TestCoverage) []byte {
-	linesValid := 0
-	linesCovered := 0
-	validFiles := coverage.OrderedFiles()
-
 	// get the string representative of sources
 	sourcesAsStr := make([]string, len(sources))
 	for i, source := range sources {
 		sourcesAsStr[i] = filepath.Join(core.RepoRoot, source.PackageName)
 	}
 
-	// Get the list of packages for <package> tag in the coverage xml file
-	var packages []pkg
-	for label, coverage := range coverage.Tests {
-		packageName := label.String()
-
-		// Get the list of classes for <class> tag in the coverage xml file
-		var classes []class
-		classLineRateTotal := 0.0
-		for className, lineCover := range coverage {
-			// Do not include files in coverage report if its not valid
-			if !cli.ContainsString(className, validFiles) {
-				continue
-			}
-
-			lines, covered, total := getLineCoverageInfo(lineCover)
-			classLineRate := float64(covered) / float64(total)
-
-			cls := class{Name: className, Filename: className,
-				Lines: lines, LineRate: formatFloatPrecision(classLineRate, 4)}
-
-			classes = append(classes, cls)
-			classLineRateTotal += classLineRate
-			linesValid += total
-			linesCovered += covered
+	coverageObj := coverageType{
+		Timestamp: int(time.Now().UnixNano()) / int(time.Millisecond),
+		Sources:   sourcesAsStr,
+	}
+	pkgs := map[string]*pkg{}
+	for _, filename := range coverage.OrderedFiles() {
+		lines, covered, total := getLineCoverageInfo(coverage.Files[filename])
+		if total == 0 {
+			continue
 		}
-
-		pkgLineRate := classLineRateTotal / float64(len(classes))
-
-		if len(classes) != 0 {
-			pkg := pkg{Name: packageName, Classes: classes, LineRate: formatFloatPrecision(pkgLineRate, 4)}
-			packages = append(packages, pkg)
+		dir := filepath.Dir(filename)
+		p, present := pkgs[dir]
+		if !present {
+			p = &pkg{Name: dir}
+			pkgs[dir] = p
 		}
+		p.Classes = append(p.Classes, class{
+			Name:     filepath.Base(filename),
+			Filename: filename,
+			Lines:    lines,
+			LineRate: rate(covered, total),
+		})
+		p.LineHits += covered
+		p.LineCount += total
 	}
-
-	topLevelLineRate := float64(linesCovered) / float64(linesValid)
-
-	// Create the coverage object based on the data collected
-	coverageObj := coverageType{Packages: packages, LineRate: formatFloatPrecision(topLevelLineRate, 4),
-		LinesCovered: linesCovered,
-		LinesValid:   linesValid,
-		Timestamp:    int(time.Now().UnixNano()) / int(time.Millisecond),
-		Sources:      sourcesAsStr}
+	for _, p := range pkgs {
+		p.LineRate = rate(p.LineHits, p.LineCount)
+		coverageObj.Packages = append(coverageObj.Packages, *p)
+		coverageObj.LinesCovered += p.LineHits
+		coverageObj.LinesValid += p.LineCount
+	}
+	sort.Slice(coverageObj.Packages, func(i, j int) bool { return coverageObj.Packages[i].Name < coverageObj.Packages[j].Name })
+	coverageObj.LineRate = rate(coverageObj.LinesCovered, coverageObj.LinesValid)
 
 	// Serialise struct to xml bytes
 	xmlBytes, err := xml.MarshalIndent(coverageObj, "", "	")
 	if err != nil {
 		log.Fatalf("Failed to parse to xml: %s", err)
 	}
-	covReport := []byte(xml.Header + string(xmlBytes))
+	covReport := []byte(xml.Header + coberturaDoctype + string(xmlBytes))
 	return covReport
 }
 
@@ -116,12 +109,12 @@ func getLineCoverageInfo(lineCover []core.LineCoverage) ([]line, int, int) {
 
 	for index, status := range lineCover {
 		if status == core.Covered {
-			line := line{Hits: 1, Number: index}
+			line := line{Hits: 1, Number: index + 1}
 			lines = append(lines, line)
 			covered++
 			total++
 		} else if status == core.Uncovered {
-			line := line{Hits: 0, Number: index}
+			line := line{Hits: 0, Number: index + 1}
 			lines = append(lines, line)
 			total++
 		}
@@ -130,6 +123,14 @@ func getLineCoverageInfo(lineCover []core.LineCoverage) ([]line, int, int) {
 	return lines, covered, total
 }
 
+// rate returns the ratio of hits to total, to four decimal places, or 0 if there's nothing to hit.
+func rate(hits, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return formatFloatPrecision(float64(hits)/float64(total), 4)
+}
+
 // format the float64 numbers to a specific precision
 func formatFloatPrecision(val float64, precision int) float64 {
 	unit := math.Pow10(precision)
@@ -4,7 +4,10 @@ package watch
 import (
 	"context"
 	"fmt"
+	"math"
+	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,8 +30,9 @@ type CallbackFunc func(*core.BuildState, []core.BuildLabel)
 
 // Watch starts watching the sources of the given labels for changes and triggers
 // rebuilds whenever they change.
+// If execCmd is given it is run in execDir after each successful rebuild.
 // It never returns successfully, it will either watch forever or die.
-func Watch(state *core.BuildState, labels core.BuildLabels, testArgs []string, noTest bool, callback CallbackFunc) {
+func Watch(state *core.BuildState, labels core.BuildLabels, testArgs []string, noTest bool, execCmd, execDir string, callback CallbackFunc) {
 	// This hasn't been set before, do it now.
 	if !noTest {
 		state.NeedTests = anyTests(state, labels)
@@ -51,8 +55,8 @@ func Watch(state *core.BuildState, labels core.BuildLabels, testArgs []string, n
 
 	// The initial setup only builds targets, it doesn't test or run things.
 	// Do one of those now if requested.
-	if state.NeedTests || state.NeedRun {
-		build(ctx, state, labels, testArgs, callback)
+	if state.NeedTests || state.NeedRun || execCmd != "" {
+		build(ctx, state, labels, testArgs, execCmd, execDir, callback)
 	}
 
 	for {
@@ -76,7 +80,7 @@ func Watch(state *core.BuildState, labels core.BuildLabels, testArgs []string, n
 					break outer
 				}
 			}
-			build(ctx, state, labels, testArgs, callback)
+			build(ctx, state, labels, testArgs, execCmd, execDir, callback)
 		case err := <-watcher.Errors:
 			log.Error("Error watching files:", err)
 		}
@@ -161,7 +165,7 @@ func anyTests(state *core.BuildState, labels []core.BuildLabel) bool {
 }
 
 // build invokes a single build while watching.
-func build(ctx context.Context, state *core.BuildState, labels []core.BuildLabel, args []string, callback CallbackFunc) {
+func build(ctx context.Context, state *core.BuildState, labels []core.BuildLabel, args []string, execCmd, execDir string, callback CallbackFunc) {
 	// Set up a new state & copy relevant parts off the existing one.
 	ns := core.NewBuildState(state.Config)
 	ns.Cache = state.Cache
@@ -172,6 +176,8 @@ func build(ctx context.Context, state *core.BuildState, labels []core.BuildLabel
 	ns.NeedRun = state.NeedRun
 	ns.Watch = true
 	ns.CleanWorkdirs = state.CleanWorkdirs
+	ns.KeepSandbox = state.KeepSandbox
+	ns.Reproduce = state.Reproduce
 	ns.DebugFailingTests = state.DebugFailingTests
 	ns.ShowAllOutput = state.ShowAllOutput
 	ns.StartTime = time.Now()
@@ -184,6 +190,37 @@ func build(ctx context.Context, state *core.BuildState, labels []core.BuildLabel
 				BuildLabel: l,
 			}
 		}
-		go run.Parallel(ctx, state, als, nil, state.Config.Please.NumThreads, process.Default, false, false, false, false, "")
+		go run.Parallel(ctx, state, als, nil, state.Config.Please.NumThreads, process.Default, false, false, false, false, nil, "", "")
+	}
+	if execCmd != "" {
+		if failed, _, _ := ns.Failures(); failed {
+			log.Warning("Build failed, not running %s", execCmd)
+			return
+		}
+		// As above, the next change will cancel the context & kill the command.
+		go runExec(ctx, execCmd, execDir, changedTargets(ns))
+	}
+}
+
+// changedTargets returns the labels of all targets that were actually rebuilt in the given build.
+func changedTargets(state *core.BuildState) []string {
+	changed := []string{}
+	for _, target := range state.Graph.AllTargets() {
+		if s := target.State(); s == core.Built || s == core.BuiltRemotely {
+			changed = append(changed, target.Label.String())
+		}
+	}
+	return changed
+}
+
+// runExec runs the user's --exec command after a successful build.
+// If it fails we report that and carry on waiting for the next change.
+func runExec(ctx context.Context, cmd, dir string, changed []string) {
+	env := append(os.Environ(), "PLZ_CHANGED_TARGETS="+strings.Join(changed, " "))
+	log.Notice("Running %s", cmd)
+	// As in plz run, the executor doesn't support not having a timeout so we use the maximum.
+	// Its output is streamed to stderr as it runs, so there's no need to print it again on failure.
+	if _, _, err := process.New().ExecWithTimeout(ctx, nil, dir, env, time.Duration(math.MaxInt64), true, false, false, false, process.NoSandbox, process.BashCommand("bash", cmd, false)); err != nil && ctx.Err() == nil {
+		log.Error("%s failed: %s", cmd, err)
 	}
 }
@@ -0,0 +1,128 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+// plzCommand is the binary we invoke to run commands.
+var plzCommand = "plz"
+
+// commands maps the names of the commands we support via workspace/executeCommand to the plz subcommand they run.
+var commands = map[string]string{
+	"please.build": "build",
+	"please.test":  "test",
+	"please.run":   "run",
+	"please.clean": "clean",
+}
+
+// commandNames returns the names of all the commands we support, in sorted order.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// commandResult is the response to workspace/executeCommand.
+type commandResult struct {
+	PID int `json:"pid"`
+}
+
+// executeCommand implements workspace/executeCommand. The first argument is the build label to
+// run the command on; any further ones are passed to plz as flags.
+// We reply as soon as plz has started; its output is then streamed to the client via
+// window/logMessage and its exit status reported via window/showMessage once it finishes.
+// The process is killed if the given context is cancelled.
+func (h *Handler) executeCommand(ctx context.Context, params *lsp.ExecuteCommandParams) (*commandResult, error) {
+	subcommand, present := commands[params.Command]
+	if !present {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "Unknown command " + params.Command}
+	}
+	args := make([]string, len(params.Arguments))
+	for i, arg := range params.Arguments {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("Invalid argument %v, must be a string", arg)}
+		}
+		args[i] = s
+	}
+	if len(args) == 0 {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "Missing build label argument"}
+	} else if _, err := core.TryParseBuildLabel(args[0], "", ""); err != nil || strings.HasPrefix(args[0], "-") {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "Invalid build label " + args[0]}
+	}
+	// Flags go before the label, since for plz run anything afterwards is passed to the binary.
+	cmd := exec.CommandContext(ctx, plzCommand, append(append([]string{subcommand, "--plain_output"}, args[1:]...), args[0])...)
+	cmd.Dir = h.root
+	log.Info("Running %s", strings.Join(cmd.Args, " "))
+	r, w := io.Pipe()
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	go h.streamCommand(cmd, r, w)
+	return &commandResult{PID: cmd.Process.Pid}, nil
+}
+
+// streamCommand forwards the output of a running command to the client until it exits,
+// then tells the client how it went.
+func (h *Handler) streamCommand(cmd *exec.Cmd, r *io.PipeReader, w *io.PipeWriter) {
+	done := make(chan error)
+	go func() {
+		err := cmd.Wait()
+		w.Close()
+		done <- err
+	}()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		h.logMessage(scanner.Text())
+	}
+	io.Copy(io.Discard, r) // In case the scanner gave up on an overlong line.
+	name := strings.Join(cmd.Args[1:], " ")
+	if err := <-done; err != nil {
+		h.showMessage(lsp.MTError, fmt.Sprintf("plz %s failed: %s", name, err))
+	} else {
+		h.showMessage(lsp.Info, fmt.Sprintf("plz %s succeeded", name))
+	}
+}
+
+// logMessage sends a single line of output to the client.
+func (h *Handler) logMessage(msg string) {
+	if h.Conn == nil {
+		return
+	}
+	if err := h.Conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{
+		Type:    lsp.Log,
+		Message: msg,
+	}); err != nil {
+		log.Warning("Failed to send log message: %s", err)
+	}
+}
+
+// showMessage sends a message to the client to be shown to the user.
+func (h *Handler) showMessage(typ lsp.MessageType, msg string) {
+	if h.Conn == nil {
+		return
+	}
+	if err := h.Conn.Notify(context.Background(), "window/showMessage", &lsp.ShowMessageParams{
+		Type:    typ,
+		Message: msg,
+	}); err != nil {
+		log.Warning("Failed to send message: %s", err)
+	}
+}
@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "plz")
+	err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\"\necho failed >&2\nexit 3\n"), 0755)
+	assert.NoError(t, err)
+	oldCommand := plzCommand
+	plzCommand = script
+	defer func() { plzCommand = oldCommand }()
+
+	r := &rpc{Notifications: make(chan message, 10)}
+	h := NewHandler()
+	h.Conn = r
+	h.root = dir
+	var result commandResult
+	err = h.Request("workspace/executeCommand", &lsp.ExecuteCommandParams{
+		Command:   "please.build",
+		Arguments: []interface{}{"//src/core:core", "--rebuild"},
+	}, &result)
+	assert.NoError(t, err)
+	assert.NotZero(t, result.PID)
+	assert.Equal(t, message{
+		Method:  "window/logMessage",
+		Payload: &lsp.LogMessageParams{Type: lsp.Log, Message: "build --plain_output --rebuild //src/core:core"},
+	}, <-r.Notifications)
+	assert.Equal(t, message{
+		Method:  "window/logMessage",
+		Payload: &lsp.LogMessageParams{Type: lsp.Log, Message: "failed"},
+	}, <-r.Notifications)
+	assert.Equal(t, message{
+		Method:  "window/showMessage",
+		Payload: &lsp.ShowMessageParams{Type: lsp.MTError, Message: "plz build --plain_output --rebuild //src/core:core failed: exit status 3"},
+	}, <-r.Notifications)
+}
+
+func TestExecuteCommandInvalid(t *testing.T) {
+	h := NewHandler()
+	var result commandResult
+	err := h.Request("workspace/executeCommand", &lsp.ExecuteCommandParams{
+		Command:   "please.wibble",
+		Arguments: []interface{}{"//src/core:core"},
+	}, &result)
+	assert.Error(t, err)
+	err = h.Request("workspace/executeCommand", &lsp.ExecuteCommandParams{
+		Command:   "please.build",
+		Arguments: []interface{}{"--rebuild"},
+	}, &result)
+	assert.Error(t, err)
+	err = h.Request("workspace/executeCommand", &lsp.ExecuteCommandParams{
+		Command: "please.build",
+	}, &result)
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/thought-machine/please/src/parse/asp"
+)
+
+// As with document links, go-lsp doesn't define the types for textDocument/inlayHint.
+
+// inlayHintParams is the parameters to a textDocument/inlayHint request.
+type inlayHintParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+	Range        lsp.Range                  `json:"range"`
+}
+
+// inlayHint is a single hint that the client displays inline in the document.
+type inlayHint struct {
+	Position     lsp.Position  `json:"position"`
+	Label        string        `json:"label"`
+	Kind         inlayHintKind `json:"kind,omitempty"`
+	PaddingLeft  bool          `json:"paddingLeft,omitempty"`
+	PaddingRight bool          `json:"paddingRight,omitempty"`
+}
+
+type inlayHintKind int
+
+const (
+	inlayHintType      inlayHintKind = 1
+	inlayHintParameter inlayHintKind = 2
+)
+
+// inlayHintOptions describes the server's inlayHint capability.
+type inlayHintOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
+
+// initializationOptions are the options that clients can pass to configure the server.
+type initializationOptions struct {
+	// InlayHints can be set to false to disable inlay hints.
+	InlayHints *bool `json:"inlayHints"`
+}
+
+// inlayHints implements textDocument/inlayHint, which annotates the arguments to calls of
+// functions we know the signature of with their declared types, for example `deps: list`.
+// Positional arguments are also annotated with the name of the argument they're bound to.
+func (h *Handler) inlayHints(params *inlayHintParams) ([]inlayHint, error) {
+	hints := []inlayHint{}
+	if !h.inlayHintsEnabled {
+		return hints, nil
+	}
+	doc := h.doc(params.TextDocument.URI)
+	ast := h.parseIfNeeded(doc)
+	f := doc.AspFile()
+
+	// Functions defined in this file can be called too, and take precedence over builtins.
+	funcs := map[string]*asp.FuncDef{}
+	for name, b := range h.builtins {
+		funcs[name] = b.Stmt.FuncDef
+	}
+	for _, stmt := range ast {
+		if stmt.FuncDef != nil {
+			funcs[stmt.FuncDef.Name] = stmt.FuncDef
+		}
+	}
+	add := func(at asp.Position, label string, kind inlayHintKind) {
+		if p := f.Pos(at); asp.WithinRange(p, aspPos(params.Range.Start), aspPos(params.Range.End)) {
+			hints = append(hints, inlayHint{
+				Position:     pos(p),
+				Label:        label,
+				Kind:         kind,
+				PaddingLeft:  kind == inlayHintType,
+				PaddingRight: kind == inlayHintParameter,
+			})
+		}
+	}
+	addCall := func(name string, call *asp.Call) {
+		def, present := funcs[name]
+		if !present {
+			return
+		}
+		for i, arg := range call.Arguments {
+			if arg.Name == "" {
+				// Positional arguments are bound in order, as long as they don't run off the end.
+				if i >= len(def.Arguments) || def.KeywordsOnly {
+					continue
+				} else if a := def.Arguments[i]; !a.IsPrivate && a.Name != "name" {
+					add(arg.Value.Pos, argumentHint(a), inlayHintParameter)
+				}
+			} else if a := findArgument(def, arg.Name); a != nil && len(a.Type) > 0 && a.Name != "name" {
+				add(arg.Pos+asp.Position(len(arg.Name)), ": "+strings.Join(a.Type, "|"), inlayHintType)
+			}
+		}
+	}
+	asp.WalkAST(ast, func(stmt *asp.Statement) bool {
+		if stmt.Ident != nil && stmt.Ident.Action != nil && stmt.Ident.Action.Call != nil {
+			addCall(stmt.Ident.Name, stmt.Ident.Action.Call)
+		}
+		return true
+	})
+	asp.WalkAST(ast, func(expr *asp.IdentExpr) bool {
+		if len(expr.Action) > 0 && expr.Action[0].Call != nil {
+			addCall(expr.Name, expr.Action[0].Call)
+		}
+		return true
+	})
+	return hints, nil
+}
+
+// argumentHint returns the hint for a positional argument, which makes it read like a keyword
+// argument, e.g. `srcs: list = `.
+func argumentHint(arg asp.Argument) string {
+	if len(arg.Type) == 0 {
+		return arg.Name + " ="
+	}
+	return arg.Name + ": " + strings.Join(arg.Type, "|") + " ="
+}
+
+// findArgument returns the argument of a function with the given name or alias, or nil if there isn't one.
+func findArgument(def *asp.FuncDef, name string) *asp.Argument {
+	for i, arg := range def.Arguments {
+		if arg.IsPrivate {
+			continue
+		} else if arg.Name == name {
+			return &def.Arguments[i]
+		}
+		for _, alias := range arg.Aliases {
+			if alias == name {
+				return &def.Arguments[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlayHints(t *testing.T) {
+	const content = `genrule(
+    name = "config_test",
+    deps = [":core"],
+    visibility = ["PUBLIC"],
+)
+
+filegroup("files", "tag")`
+	h := initHandler()
+	err := h.Request("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{
+			URI:  testURI,
+			Text: content,
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	hints := []inlayHint{}
+	err = h.Request("textDocument/inlayHint", &inlayHintParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: testURI},
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 0},
+			End:   lsp.Position{Line: 10, Character: 0},
+		},
+	}, &hints)
+	assert.NoError(t, err)
+	assert.Equal(t, []inlayHint{
+		{
+			Position:    lsp.Position{Line: 2, Character: 8},
+			Label:       ": list",
+			Kind:        inlayHintType,
+			PaddingLeft: true,
+		},
+		{
+			Position:    lsp.Position{Line: 3, Character: 14},
+			Label:       ": list",
+			Kind:        inlayHintType,
+			PaddingLeft: true,
+		},
+		{
+			Position:     lsp.Position{Line: 6, Character: 19},
+			Label:        "tag: str =",
+			Kind:         inlayHintParameter,
+			PaddingRight: true,
+		},
+	}, hints)
+}
+
+func TestInlayHintsRange(t *testing.T) {
+	const content = `genrule(
+    name = "config_test",
+    deps = [":core"],
+    visibility = ["PUBLIC"],
+)`
+	h := initHandler()
+	err := h.Request("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{
+			URI:  testURI,
+			Text: content,
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	hints := []inlayHint{}
+	err = h.Request("textDocument/inlayHint", &inlayHintParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: testURI},
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 3, Character: 0},
+			End:   lsp.Position{Line: 4, Character: 0},
+		},
+	}, &hints)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(hints))
+	assert.Equal(t, lsp.Position{Line: 3, Character: 14}, hints[0].Position)
+}
+
+func TestInlayHintsDisabled(t *testing.T) {
+	h := NewHandler()
+	result := &initializeResult{}
+	err := h.Request("initialize", &lsp.InitializeParams{
+		Capabilities:          lsp.ClientCapabilities{},
+		RootURI:               lsp.DocumentURI("file://" + filepath.Join(os.Getenv("TEST_DIR"), "tools/build_langserver/lsp/test_data")),
+		InitializationOptions: map[string]interface{}{"inlayHints": false},
+	}, result)
+	assert.NoError(t, err)
+	assert.Nil(t, result.Capabilities.InlayHintProvider)
+
+	err = h.Request("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{
+			URI:  testURI,
+			Text: `genrule(name = "config_test", deps = [":core"])`,
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	hints := []inlayHint{}
+	err = h.Request("textDocument/inlayHint", &inlayHintParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: testURI},
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 0},
+			End:   lsp.Position{Line: 1, Character: 0},
+		},
+	}, &hints)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(hints))
+}
@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/parse/asp"
+	"github.com/thought-machine/please/tools/build_langserver/lsp/astutils"
+)
+
+// go-lsp doesn't define the types for textDocument/documentLink so we have our own here.
+
+// documentLinkParams is the parameters to a textDocument/documentLink request.
+type documentLinkParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// documentLink is a single link within a document.
+type documentLink struct {
+	Range  lsp.Range       `json:"range"`
+	Target lsp.DocumentURI `json:"target,omitempty"`
+}
+
+// documentLinkOptions describes the server's documentLink capability.
+type documentLinkOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
+
+// serverCapabilities extends lsp.ServerCapabilities with the capabilities that go-lsp doesn't know about.
+type serverCapabilities struct {
+	lsp.ServerCapabilities
+	DocumentLinkProvider   *documentLinkOptions   `json:"documentLinkProvider,omitempty"`
+	SemanticTokensProvider *semanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+	InlayHintProvider      *inlayHintOptions      `json:"inlayHintProvider,omitempty"`
+}
+
+// initializeResult is the equivalent of lsp.InitializeResult using our extended capabilities.
+type initializeResult struct {
+	Capabilities serverCapabilities `json:"capabilities"`
+}
+
+// documentLinks implements textDocument/documentLink, which returns links for any strings in
+// the document that refer to build labels (which link to the BUILD file defining them) or to files
+// in the package (which link to those files). Labels passed to subinclude link to the file they name.
+func (h *Handler) documentLinks(params *documentLinkParams) ([]documentLink, error) {
+	doc := h.doc(params.TextDocument.URI)
+	ast := h.parseIfNeeded(doc)
+	f := doc.AspFile()
+
+	subincludes := map[asp.Position]bool{}
+	asp.WalkAST(ast, func(stmt *asp.Statement) bool {
+		if stmt.Ident != nil && stmt.Ident.Name == "subinclude" && stmt.Ident.Action != nil && stmt.Ident.Action.Call != nil {
+			for _, arg := range stmt.Ident.Action.Call.Arguments {
+				subincludes[arg.Value.Pos] = true
+			}
+			return false
+		}
+		return true
+	})
+
+	links := []documentLink{}
+	asp.WalkAST(ast, func(expr *asp.Expression) bool {
+		if expr.Val != nil && expr.Val.String != "" {
+			s := astutils.TrimStrLit(expr.Val.String)
+			var target lsp.DocumentURI
+			if subincludes[expr.Pos] {
+				target = h.subincludeLinkTarget(doc.PkgName, s)
+			}
+			if target == "" {
+				target = h.linkTarget(doc.PkgName, s)
+			}
+			if target != "" {
+				links = append(links, documentLink{
+					Range:  rng(f.Pos(expr.Pos), f.Pos(expr.EndPos)),
+					Target: target,
+				})
+			}
+			return false
+		}
+		return true
+	})
+	return links, nil
+}
+
+// linkTarget returns the URI that the given string links to, or the empty string if it doesn't
+// refer to anything we can find.
+func (h *Handler) linkTarget(pkgName, s string) lsp.DocumentURI {
+	if s == "" {
+		return ""
+	}
+	if !core.LooksLikeABuildLabel(s) {
+		p := filepath.Join(h.root, pkgName, s)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return lsp.DocumentURI("file://" + p)
+		}
+		return ""
+	}
+	l, err := core.TryParseBuildLabel(s, pkgName, "")
+	if err != nil || l.Subrepo != "" {
+		return ""
+	}
+	for _, name := range h.state.Config.Parse.BuildFileName {
+		p := filepath.Join(h.root, l.PackageName, name)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return lsp.DocumentURI("file://" + p)
+		}
+	}
+	return ""
+}
+
+// subincludeLinkTarget returns the URI of the file that the given subincluded label refers to,
+// or the empty string if its target hasn't been parsed yet or none of its files exist.
+// Source files are preferred to outputs, since they're the ones that can usefully be edited.
+func (h *Handler) subincludeLinkTarget(pkgName, s string) lsp.DocumentURI {
+	l, err := core.TryParseBuildLabel(s, pkgName, "")
+	if err != nil || l.Subrepo != "" {
+		return ""
+	}
+	target := h.state.Graph.Target(l)
+	if target == nil {
+		return ""
+	}
+	var paths []string
+	for _, src := range target.AllSources() {
+		if label, ok := src.(core.FileLabel); ok {
+			paths = append(paths, label.Paths(h.state.Graph)...)
+		}
+	}
+	for _, out := range target.Outputs() {
+		paths = append(paths, filepath.Join(target.OutDir(), out))
+	}
+	for _, p := range paths {
+		p = filepath.Join(h.root, p)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return lsp.DocumentURI("file://" + p)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thought-machine/please/src/core"
+)
+
+func TestDocumentLinks(t *testing.T) {
+	content := `go_test(
+    name = "config_test",
+    srcs = ["config_test.go", "missing.go"],
+    deps = [":core", "//build_defs:go_bindata"],
+)`
+	dir := filepath.Join(os.Getenv("TEST_DIR"), "tools/build_langserver/lsp/test_data")
+	uri := lsp.DocumentURI("file://" + filepath.Join(dir, "src/core/test.build"))
+	h := initHandler()
+	err := h.Request("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{
+			URI:  uri,
+			Text: content,
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	var links []documentLink
+	err = h.Request("textDocument/documentLink", &documentLinkParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	}, &links)
+	assert.NoError(t, err)
+	assert.Equal(t, []documentLink{
+		{
+			Range:  xrng(2, 12, 2, 28),
+			Target: lsp.DocumentURI("file://" + filepath.Join(dir, "src/core/config_test.go")),
+		},
+		{
+			Range:  xrng(3, 12, 3, 19),
+			Target: uri,
+		},
+		{
+			Range:  xrng(3, 21, 3, 46),
+			Target: lsp.DocumentURI("file://" + filepath.Join(dir, "build_defs/test.build")),
+		},
+	}, links)
+}
+
+func TestDocumentLinksSubinclude(t *testing.T) {
+	content := `subinclude("//subinclude:defs", "//subinclude:generated")
+
+go_bindata(
+    name = "bindata",
+    deps = ["//build_defs:go_bindata"],
+)`
+	dir := filepath.Join(os.Getenv("TEST_DIR"), "tools/build_langserver/lsp/test_data")
+	uri := lsp.DocumentURI("file://" + filepath.Join(dir, "src/core/test.build"))
+	h := initHandler()
+	// Add these directly rather than waiting for the repo to be parsed, since that needs the Go plugin.
+	defs := core.NewBuildTarget(core.BuildLabel{PackageName: "subinclude", Name: "defs"})
+	defs.AddSource(core.FileLabel{Package: "build_defs", File: "go_bindata.build_defs"})
+	h.state.Graph.AddTarget(defs)
+	generated := core.NewBuildTarget(core.BuildLabel{PackageName: "subinclude", Name: "generated"})
+	generated.AddOutput("generated.build_defs")
+	h.state.Graph.AddTarget(generated)
+	out := filepath.Join(dir, "plz-out/gen/subinclude/generated.build_defs")
+	require.NoError(t, os.MkdirAll(filepath.Dir(out), os.ModeDir|0755))
+	require.NoError(t, os.WriteFile(out, nil, 0644))
+	defer os.RemoveAll(filepath.Dir(out))
+
+	err := h.Request("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{
+			URI:  uri,
+			Text: content,
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	var links []documentLink
+	err = h.Request("textDocument/documentLink", &documentLinkParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	}, &links)
+	assert.NoError(t, err)
+	assert.Equal(t, []documentLink{
+		{
+			Range:  xrng(0, 11, 0, 30),
+			Target: lsp.DocumentURI("file://" + filepath.Join(dir, "build_defs/go_bindata.build_defs")),
+		},
+		{
+			Range:  xrng(0, 32, 0, 56),
+			Target: lsp.DocumentURI("file://" + out),
+		},
+		{
+			Range:  xrng(4, 12, 4, 37),
+			Target: lsp.DocumentURI("file://" + filepath.Join(dir, "build_defs/test.build")),
+		},
+	}, links)
+}
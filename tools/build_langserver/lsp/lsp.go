@@ -36,6 +36,8 @@ type Handler struct {
 	builtins map[string]builtin
 	pkgs     *pkg
 	root     string
+
+	inlayHintsEnabled bool
 }
 
 type builtin struct {
@@ -61,7 +63,7 @@ func NewHandler() *Handler {
 
 // Handle implements the jsonrpc2.Handler interface
 func (h *Handler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	if resp, err := h.handle(req.Method, req.Params); err != nil {
+	if resp, err := h.handle(ctx, req.Method, req.Params); err != nil {
 		// check if the error is a jsonrpc error
 		jsonerr, ok := err.(*jsonrpc2.Error)
 
@@ -84,7 +86,7 @@ func (h *Handler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2
 }
 
 // handle is the slightly higher-level handler that deals with individual methods.
-func (h *Handler) handle(method string, params *json.RawMessage) (res interface{}, err error) {
+func (h *Handler) handle(ctx context.Context, method string, params *json.RawMessage) (res interface{}, err error) {
 	start := time.Now()
 	log.Debug("Received %s message", method)
 	defer func() {
@@ -173,12 +175,36 @@ func (h *Handler) handle(method string, params *json.RawMessage) (res interface{
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
 		}
 		return h.definition(positionParams)
+	case "textDocument/documentLink":
+		linkParams := &documentLinkParams{}
+		if err := json.Unmarshal(*params, linkParams); err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		return h.documentLinks(linkParams)
+	case "textDocument/semanticTokens/full":
+		tokenParams := &semanticTokensParams{}
+		if err := json.Unmarshal(*params, tokenParams); err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		return h.semanticTokensFull(tokenParams)
+	case "textDocument/inlayHint":
+		hintParams := &inlayHintParams{}
+		if err := json.Unmarshal(*params, hintParams); err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		return h.inlayHints(hintParams)
+	case "workspace/executeCommand":
+		commandParams := &lsp.ExecuteCommandParams{}
+		if err := json.Unmarshal(*params, commandParams); err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		return h.executeCommand(ctx, commandParams)
 	default:
 		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound}
 	}
 }
 
-func (h *Handler) initialize(params *lsp.InitializeParams) (*lsp.InitializeResult, error) {
+func (h *Handler) initialize(params *lsp.InitializeParams) (*initializeResult, error) {
 	// This is a bit yucky and stateful, but we only need to do it once.
 	if err := os.Chdir(fromURI(params.RootURI)); err != nil {
 		return nil, err
@@ -210,20 +236,47 @@ func (h *Handler) initialize(params *lsp.InitializeParams) (*lsp.InitializeResul
 	if err := h.loadBuiltins(); err != nil {
 		return nil, err
 	}
-	return &lsp.InitializeResult{
-		Capabilities: lsp.ServerCapabilities{
-			TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
-				Options: &lsp.TextDocumentSyncOptions{
-					OpenClose: true,
-					Change:    lsp.TDSKFull, // TODO(peterebden): Support incremental updates
+	h.inlayHintsEnabled = true
+	if params.InitializationOptions != nil {
+		// This has come through as a generic map, so round-trip it to get it into our struct.
+		opts := initializationOptions{}
+		if b, err := json.Marshal(params.InitializationOptions); err != nil {
+			log.Warning("Invalid initialization options: %s", err)
+		} else if err := json.Unmarshal(b, &opts); err != nil {
+			log.Warning("Invalid initialization options: %s", err)
+		} else if opts.InlayHints != nil {
+			h.inlayHintsEnabled = *opts.InlayHints
+		}
+	}
+	var inlayHintProvider *inlayHintOptions
+	if h.inlayHintsEnabled {
+		inlayHintProvider = &inlayHintOptions{}
+	}
+	return &initializeResult{
+		Capabilities: serverCapabilities{
+			ServerCapabilities: lsp.ServerCapabilities{
+				TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
+					Options: &lsp.TextDocumentSyncOptions{
+						OpenClose: true,
+						Change:    lsp.TDSKFull, // TODO(peterebden): Support incremental updates
+					},
+				},
+				DocumentFormattingProvider: true,
+				DocumentSymbolProvider:     true,
+				DefinitionProvider:         true,
+				CompletionProvider: &lsp.CompletionOptions{
+					TriggerCharacters: []string{"/", ":"},
+				},
+				ExecuteCommandProvider: &lsp.ExecuteCommandOptions{
+					Commands: commandNames(),
 				},
 			},
-			DocumentFormattingProvider: true,
-			DocumentSymbolProvider:     true,
-			DefinitionProvider:         true,
-			CompletionProvider: &lsp.CompletionOptions{
-				TriggerCharacters: []string{"/", ":"},
+			DocumentLinkProvider: &documentLinkOptions{},
+			SemanticTokensProvider: &semanticTokensOptions{
+				Legend: tokenLegend,
+				Full:   true,
 			},
+			InlayHintProvider: inlayHintProvider,
 		},
 	}, nil
 }
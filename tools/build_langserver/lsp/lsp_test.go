@@ -601,7 +601,7 @@ func (h *Handler) Request(method string, req, resp interface{}) error {
 		log.Fatalf("failed to encode request: %s", err)
 	}
 	msg := json.RawMessage(b)
-	i, e := h.handle(method, &msg)
+	i, e := h.handle(context.Background(), method, &msg)
 	if e != nil || resp == nil {
 		return e
 	}
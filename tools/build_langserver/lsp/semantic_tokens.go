@@ -0,0 +1,164 @@
+package lsp
+
+import (
+	"sort"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/thought-machine/please/src/core"
+	"github.com/thought-machine/please/src/parse/asp"
+	"github.com/thought-machine/please/tools/build_langserver/lsp/astutils"
+)
+
+// As with document links, go-lsp doesn't define the types for textDocument/semanticTokens.
+
+// semanticTokensParams is the parameters to a textDocument/semanticTokens/full request.
+type semanticTokensParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// semanticTokens is the response to a textDocument/semanticTokens/full request.
+// Data is a flat array of five integers per token; see encodeTokens for the details.
+type semanticTokens struct {
+	Data []uint32 `json:"data"`
+}
+
+// semanticTokensLegend describes the token types & modifiers that we use.
+type semanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// semanticTokensOptions describes the server's semanticTokens capability.
+type semanticTokensOptions struct {
+	Legend semanticTokensLegend `json:"legend"`
+	Full   bool                 `json:"full"`
+}
+
+// The token types we send. The order here matters, they're referred to by index.
+const (
+	tokenFunction uint32 = iota
+	tokenParameter
+	tokenVariable
+	tokenString
+	tokenNumber
+	tokenKeyword
+	tokenLabel // Not a standard LSP type; strings that are build labels.
+)
+
+// The token modifiers we send. Again, these are referred to by bit index.
+const (
+	modifierDefaultLibrary uint32 = 1 << iota
+)
+
+var tokenLegend = semanticTokensLegend{
+	TokenTypes:     []string{"function", "parameter", "variable", "string", "number", "keyword", "label"},
+	TokenModifiers: []string{"defaultLibrary"},
+}
+
+// A semanticToken is a single token before encoding.
+type semanticToken struct {
+	Line, Start, Length, Type, Modifiers uint32
+}
+
+// semanticTokensFull implements textDocument/semanticTokens/full, which classifies the tokens in the
+// document for syntax highlighting (for example function calls, argument names and build labels).
+func (h *Handler) semanticTokensFull(params *semanticTokensParams) (*semanticTokens, error) {
+	doc := h.doc(params.TextDocument.URI)
+	ast := h.parseIfNeeded(doc)
+	f := doc.AspFile()
+
+	tokens := []semanticToken{}
+	add := func(start, end asp.Position, typ, modifiers uint32) {
+		s, e := f.Pos(start), f.Pos(end)
+		if s.Line != e.Line || e.Column <= s.Column {
+			return // Multiline tokens aren't supported by all clients.
+		}
+		tokens = append(tokens, semanticToken{
+			Line:      uint32(s.Line - 1),
+			Start:     uint32(s.Column - 1),
+			Length:    uint32(e.Column - s.Column),
+			Type:      typ,
+			Modifiers: modifiers,
+		})
+	}
+	addName := func(pos asp.Position, name string, typ uint32) {
+		var modifiers uint32
+		if _, present := h.builtins[name]; present && typ == tokenFunction {
+			modifiers = modifierDefaultLibrary
+		}
+		add(pos, pos+asp.Position(len(name)), typ, modifiers)
+	}
+	asp.WalkAST(ast, func(stmt *asp.Statement) bool {
+		if stmt.Ident != nil && stmt.Ident.Action != nil && stmt.Ident.Action.Call != nil {
+			addName(stmt.Pos, stmt.Ident.Name, tokenFunction)
+		}
+		return true
+	})
+	asp.WalkAST(ast, func(arg *asp.CallArgument) bool {
+		if arg.Name != "" {
+			addName(arg.Pos, arg.Name, tokenParameter)
+		}
+		return true
+	})
+	asp.WalkAST(ast, func(expr *asp.Expression) bool {
+		v := expr.Val
+		if v == nil {
+			return true
+		} else if v.Ident != nil {
+			if len(v.Ident.Action) > 0 && v.Ident.Action[0].Call != nil {
+				addName(v.Ident.Pos, v.Ident.Name, tokenFunction)
+			} else {
+				addName(v.Ident.Pos, v.Ident.Name, tokenVariable)
+			}
+			return true
+		} else if v.True || v.None {
+			add(expr.Pos, expr.Pos+4, tokenKeyword, 0)
+		} else if v.False {
+			add(expr.Pos, expr.Pos+5, tokenKeyword, 0)
+		}
+		// For anything else we only know where the literal ends if nothing else follows it.
+		if len(expr.Op) != 0 || expr.If != nil || v.Property != nil || v.Call != nil || len(v.Slices) != 0 {
+			return true
+		}
+		if v.String != "" {
+			if core.LooksLikeABuildLabel(astutils.TrimStrLit(v.String)) {
+				add(expr.Pos, expr.EndPos, tokenLabel, 0)
+			} else {
+				add(expr.Pos, expr.EndPos, tokenString, 0)
+			}
+		} else if v.FString != nil {
+			add(expr.Pos, expr.EndPos, tokenString, 0)
+		} else if v.IsInt {
+			add(expr.Pos, expr.EndPos, tokenNumber, 0)
+		}
+		return true
+	})
+	return &semanticTokens{Data: encodeTokens(tokens)}, nil
+}
+
+// encodeTokens encodes a set of tokens into the LSP wire format. Each token is represented by five
+// integers: the line relative to the previous token, the start character (relative to the previous
+// token if they're on the same line), the length, the type and the modifiers.
+func encodeTokens(tokens []semanticToken) []uint32 {
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].Line != tokens[j].Line {
+			return tokens[i].Line < tokens[j].Line
+		}
+		return tokens[i].Start < tokens[j].Start
+	})
+	data := make([]uint32, 0, 5*len(tokens))
+	var line, start uint32
+	for i, token := range tokens {
+		if i > 0 && token.Line == line && token.Start == start {
+			continue // Tokens cannot overlap, so skip any duplicates.
+		}
+		if token.Line != line {
+			start = 0
+		}
+		data = append(data, token.Line-line, token.Start-start, token.Length, token.Type, token.Modifiers)
+		line = token.Line
+		start = token.Start
+	}
+	return data
+}
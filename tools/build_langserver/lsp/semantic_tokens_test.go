@@ -0,0 +1,54 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	const content = `genrule(
+    name = "config_test",
+    deps = [":core"],
+    flaky = True,
+    size = 3,
+)`
+	h := initHandler()
+	err := h.Request("textDocument/didOpen", &lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{
+			URI:  testURI,
+			Text: content,
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	tokens := &semanticTokens{}
+	err = h.Request("textDocument/semanticTokens/full", &semanticTokensParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: testURI},
+	}, tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{
+		0, 0, 7, tokenFunction, modifierDefaultLibrary,
+		1, 4, 4, tokenParameter, 0,
+		0, 7, 13, tokenString, 0,
+		1, 4, 4, tokenParameter, 0,
+		0, 8, 7, tokenLabel, 0,
+		1, 4, 5, tokenParameter, 0,
+		0, 8, 4, tokenKeyword, 0,
+		1, 4, 4, tokenParameter, 0,
+		0, 7, 1, tokenNumber, 0,
+	}, tokens.Data)
+}
+
+func TestEncodeTokens(t *testing.T) {
+	assert.Equal(t, []uint32{
+		1, 2, 3, tokenString, 0,
+		0, 5, 4, tokenFunction, modifierDefaultLibrary,
+		2, 1, 1, tokenNumber, 0,
+	}, encodeTokens([]semanticToken{
+		{Line: 3, Start: 1, Length: 1, Type: tokenNumber},
+		{Line: 1, Start: 7, Length: 4, Type: tokenFunction, Modifiers: modifierDefaultLibrary},
+		{Line: 1, Start: 2, Length: 3, Type: tokenString},
+	}))
+}
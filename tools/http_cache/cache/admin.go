@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AdminServer implements a http handler for administering a cache, for example to remove an
+// artifact that is known to be bad. Every request must carry the admin token as a bearer token.
+type AdminServer struct {
+	cache *Cache
+	token []byte
+	mux   *http.ServeMux
+}
+
+// An ArtifactInfo describes a single artifact in the cache.
+type ArtifactInfo struct {
+	Hash     string    `json:"hash"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Stats describes the overall contents of the cache.
+type Stats struct {
+	Artifacts int   `json:"artifacts"`
+	Files     int   `json:"files"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// NewAdminServer creates a new admin server for the given cache.
+func NewAdminServer(cache *Cache, token string) *AdminServer {
+	a := &AdminServer{
+		cache: cache,
+		token: []byte(token),
+		mux:   http.NewServeMux(),
+	}
+	a.mux.HandleFunc("GET /artifacts/{hash}", a.getArtifact)
+	a.mux.HandleFunc("DELETE /artifacts/{hash}", a.deleteArtifact)
+	a.mux.HandleFunc("GET /stats", a.stats)
+	return a
+}
+
+// ServeHTTP implements the http.Handler interface for the admin server
+func (a *AdminServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), a.token) != 1 {
+		http.Error(resp, "invalid admin token", http.StatusUnauthorized)
+		return
+	}
+	a.mux.ServeHTTP(resp, req)
+}
+
+func (a *AdminServer) getArtifact(resp http.ResponseWriter, req *http.Request) {
+	path, ok := a.artifactPath(resp, req)
+	if !ok {
+		return
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		http.Error(resp, "artifact not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(resp, ArtifactInfo{Hash: req.PathValue("hash"), Size: info.Size(), Modified: info.ModTime()})
+}
+
+func (a *AdminServer) deleteArtifact(resp http.ResponseWriter, req *http.Request) {
+	path, ok := a.artifactPath(resp, req)
+	if !ok {
+		return
+	}
+	if err := os.Remove(path); os.IsNotExist(err) {
+		http.Error(resp, "artifact not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Notice("Deleted artifact %s", req.PathValue("hash"))
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AdminServer) stats(resp http.ResponseWriter, req *http.Request) {
+	stats := Stats{}
+	err := filepath.WalkDir(a.cache.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.Files++
+		stats.Bytes += info.Size()
+		if filepath.Dir(path) == filepath.Clean(a.cache.Dir) {
+			stats.Artifacts++
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(resp, stats)
+}
+
+// artifactPath returns the path to the artifact named in the request, or writes an error
+// response and returns false if it isn't a valid hash.
+func (a *AdminServer) artifactPath(resp http.ResponseWriter, req *http.Request) (string, bool) {
+	hash := req.PathValue("hash")
+	if _, err := hex.DecodeString(hash); err != nil || hash == "" {
+		http.Error(resp, "invalid artifact hash", http.StatusBadRequest)
+		return "", false
+	}
+	return filepath.Join(a.cache.Dir, hash), true
+}
+
+func writeJSON(resp http.ResponseWriter, v interface{}) {
+	resp.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(resp).Encode(v); err != nil {
+		log.Errorf("Failed to write response: %v", err)
+	}
+}
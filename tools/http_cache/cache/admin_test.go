@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testToken = "secret"
+
+func adminRequest(t *testing.T, a *AdminServer, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp := httptest.NewRecorder()
+	a.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestAdminArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "abcd1234"), []byte("hello"), 0644))
+	a := NewAdminServer(New(dir), testToken)
+
+	resp := adminRequest(t, a, http.MethodGet, "/artifacts/abcd1234", testToken)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	info := ArtifactInfo{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &info))
+	assert.Equal(t, "abcd1234", info.Hash)
+	assert.EqualValues(t, 5, info.Size)
+
+	resp = adminRequest(t, a, http.MethodDelete, "/artifacts/abcd1234", testToken)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	assert.NoFileExists(t, filepath.Join(dir, "abcd1234"))
+
+	resp = adminRequest(t, a, http.MethodGet, "/artifacts/abcd1234", testToken)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	resp = adminRequest(t, a, http.MethodDelete, "/artifacts/not-a-hash", testToken)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestAdminStats(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "abcd1234"), []byte("hello"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "cas"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "cas", "ef56"), []byte("world!"), 0644))
+	a := NewAdminServer(New(dir), testToken)
+
+	resp := adminRequest(t, a, http.MethodGet, "/stats", testToken)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	stats := Stats{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &stats))
+	assert.Equal(t, Stats{Artifacts: 1, Files: 2, Bytes: 11}, stats)
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	a := NewAdminServer(New(t.TempDir()), testToken)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, a, http.MethodGet, "/stats", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, a, http.MethodGet, "/stats", "wrong").Code)
+}
@@ -1,11 +1,15 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	logger "github.com/thought-machine/please/src/cli/logging"
 	"github.com/thought-machine/please/src/fs"
@@ -16,8 +20,15 @@ var log = logger.Log
 // Cache implements a http handler for caching files. Effectively a read/write http.FileSystem
 type Cache struct {
 	Dir string
+	// If true, anything stored under /cas/ must be named by the SHA-256 hash of its content.
+	CAS bool
+	// If non-zero, uploads of more than this many bytes are rejected.
+	MaxArtifactSize int64
 }
 
+// casPrefix is the path under which content-addressed artifacts are stored.
+const casPrefix = "/cas/"
+
 // New create a new http cache
 func New(dir string) *Cache {
 	return &Cache{
@@ -29,17 +40,77 @@ func New(dir string) *Cache {
 func (c *Cache) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	uri := req.RequestURI
 	if req.Method == http.MethodPut {
-		err := c.store(uri, req.Body)
-		if err != nil {
+		if c.MaxArtifactSize > 0 {
+			// Reject it up front if we can; otherwise we'll find out when we've read too much of it.
+			if req.ContentLength > c.MaxArtifactSize {
+				c.rejectTooLarge(resp, uri)
+				return
+			}
+			req.Body = http.MaxBytesReader(resp, req.Body, c.MaxArtifactSize)
+		}
+		if c.CAS && strings.HasPrefix(uri, casPrefix) {
+			if err := c.storeCAS(strings.TrimPrefix(uri, casPrefix), req.Body); isTooLarge(err) {
+				c.rejectTooLarge(resp, uri)
+			} else if err != nil {
+				log.Warningf("Rejected upload to %s: %v", uri, err)
+				resp.WriteHeader(http.StatusBadRequest)
+				_, _ = resp.Write([]byte(fmt.Sprintf("failed to store in cache: %v", err)))
+			}
+			return
+		}
+		if err := c.store(uri, req.Body); isTooLarge(err) {
+			c.rejectTooLarge(resp, uri)
+		} else if err != nil {
 			log.Errorf("Failed to store in cache: %v", err)
 			resp.WriteHeader(http.StatusInternalServerError)
 			_, _ = resp.Write([]byte(fmt.Sprintf("failed to store in cache: %v", err)))
 		}
-	} else if req.Method == http.MethodGet {
+	} else if req.Method == http.MethodGet || req.Method == http.MethodHead {
 		http.ServeFile(resp, req, filepath.Join(c.Dir, uri))
 	}
 }
 
+// rejectTooLarge responds to an upload that's over the maximum artifact size.
+func (c *Cache) rejectTooLarge(resp http.ResponseWriter, uri string) {
+	log.Warningf("Rejected upload to %s: larger than the maximum of %d bytes", uri, c.MaxArtifactSize)
+	resp.WriteHeader(http.StatusRequestEntityTooLarge)
+	_, _ = resp.Write([]byte(fmt.Sprintf("artifact is larger than the maximum of %d bytes", c.MaxArtifactSize)))
+}
+
+// isTooLarge returns true if the given error is from reading past the maximum artifact size.
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// storeCAS stores the given data, which must have the given hex-encoded SHA-256 hash.
+// It's written to a temporary file first so nothing is visible under that name until it's verified.
+func (c *Cache) storeCAS(digest string, data io.Reader) error {
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != 2*sha256.Size {
+		return fmt.Errorf("invalid content hash %q", digest)
+	}
+	dir := filepath.Join(c.Dir, casPrefix)
+	if err := os.MkdirAll(dir, os.ModeDir|0775); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, "tmp_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, h), data); err != nil {
+		return err
+	} else if actual := hex.EncodeToString(h.Sum(nil)); actual != digest {
+		return fmt.Errorf("content hash mismatch; was %s", actual)
+	} else if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(dir, digest))
+}
+
 func (c *Cache) store(uri string, data io.Reader) error {
 	path := filepath.Join(c.Dir, uri)
 	if err := fs.RemoveAll(uri); err != nil {
@@ -56,6 +127,10 @@ func (c *Cache) store(uri string, data io.Reader) error {
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, data)
-	return err
+	if _, err := io.Copy(file, data); err != nil {
+		// Don't leave a truncated artifact behind for clients to retrieve.
+		os.Remove(path)
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func put(c *Cache, path string, body io.Reader, contentLength int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, path, body)
+	req.ContentLength = contentLength
+	resp := httptest.NewRecorder()
+	c.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestMaxArtifactSize(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir)
+	c.MaxArtifactSize = 5
+
+	resp := put(c, "/small", strings.NewReader("hello"), 5)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.FileExists(t, filepath.Join(dir, "small"))
+
+	resp = put(c, "/declared", strings.NewReader("hello world"), 11)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	assert.NoFileExists(t, filepath.Join(dir, "declared"))
+
+	// Without a Content-Length we only find out once we've read too much.
+	resp = put(c, "/undeclared", strings.NewReader("hello world"), -1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	assert.NoFileExists(t, filepath.Join(dir, "undeclared"))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,15 +15,31 @@ import (
 var log = logger.Log
 
 var opts = struct {
-	Usage     string
-	Verbosity cli.Verbosity `short:"v" long:"verbosity" default:"notice" description:"Verbosity of output (higher number = more output)"`
-	CacheDir  string        `short:"d" long:"dir" default:"" description:"The directory to store cached artifacts in."`
-	Port      int           `short:"p" long:"port" description:"The port to run the server on" default:"8080"`
+	Usage           string
+	Verbosity       cli.Verbosity `short:"v" long:"verbosity" default:"notice" description:"Verbosity of output (higher number = more output)"`
+	CacheDir        string        `short:"d" long:"dir" default:"" description:"The directory to store cached artifacts in."`
+	Port            int           `short:"p" long:"port" description:"The port to run the server on" default:"8080"`
+	CASMode         bool          `long:"cas_mode" description:"Verify that artifacts stored under /cas/ are named by the SHA-256 hash of their content."`
+	AdminPort       int           `long:"admin_port" description:"Port to serve the admin API on. It's disabled if not set."`
+	AdminTokenFile  string        `long:"admin_token_file" description:"File containing the token that clients of the admin API must present."`
+	MaxArtifactSize cli.ByteSize  `long:"max_artifact_size" default:"1G" description:"Uploads larger than this are rejected. Set to 0 to allow any size."`
 }{
 	Usage: `
 HTTP cache implements a resource based http server that please can use as a cache. The cache supports storing files
 via PUT requests and retrieving them again through GET requests. Really any http server (e.g. nginx) can be used as a
 cache for please however this is a lightweight and easy to configure option.
+
+With --cas_mode, artifacts stored under /cas/ must be named by the SHA-256 hash of their content, which is verified
+before they're stored. This is used by clients with the cache.httpcas config option set.
+
+Uploads larger than --max_artifact_size are rejected with 413 Request Entity Too Large. Those that declare their
+size are rejected before any of it is read.
+
+With --admin_port, an admin API is served on a separate port. Requests to it must have an
+"Authorization: Bearer <token>" header matching the contents of --admin_token_file. It supports:
+  GET /artifacts/{hash}     returns the size and modification time of an artifact
+  DELETE /artifacts/{hash}  removes an artifact
+  GET /stats                returns the number of artifacts and total size of the cache
 `,
 }
 
@@ -39,7 +56,25 @@ func main() {
 	}
 
 	log.Notice("Started please http cache at 127.0.0.1:%v serving out of %v", opts.Port, opts.CacheDir)
-	err := http.ListenAndServe(fmt.Sprint(":", opts.Port), cache.New(opts.CacheDir))
+	c := cache.New(opts.CacheDir)
+	c.CAS = opts.CASMode
+	c.MaxArtifactSize = int64(opts.MaxArtifactSize)
+	if opts.AdminPort != 0 {
+		if opts.AdminTokenFile == "" {
+			log.Fatalf("--admin_token_file must be given with --admin_port")
+		}
+		token, err := os.ReadFile(opts.AdminTokenFile)
+		if err != nil {
+			log.Fatalf("failed to read admin token: %v", err)
+		} else if len(bytes.TrimSpace(token)) == 0 {
+			log.Fatalf("admin token file %s is empty", opts.AdminTokenFile)
+		}
+		log.Notice("Serving admin API at 127.0.0.1:%v", opts.AdminPort)
+		go func() {
+			log.Panic(http.ListenAndServe(fmt.Sprint(":", opts.AdminPort), cache.NewAdminServer(c, string(bytes.TrimSpace(token)))))
+		}()
+	}
+	err := http.ListenAndServe(fmt.Sprint(":", opts.Port), c)
 	if err != nil {
 		log.Panic(err)
 	}